@@ -3,13 +3,14 @@ package app
 import (
 	"context"
 	"embed"
+	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	filecache "github.com/faabiosr/cachego/file"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/securecookie"
 	"github.com/gorilla/websocket"
-	"github.com/matcornic/hermes/v2"
 	envs "github.com/olivercullimore/go-utils/env"
 	"github.com/olivercullimore/hikvision-anpr-alerts/app/models"
 	"github.com/olivercullimore/hikvision-anpr-alerts/app/routes"
@@ -20,7 +21,7 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
-	"sync"
+	"strings"
 	"time"
 )
 
@@ -54,6 +55,21 @@ func Run() {
 	config.SMTPAuth = checkConfig("SMTP_AUTH", "Unknown", "SMTP Auth", "none", logger)
 	config.SMTPFrom = checkConfig("SMTP_FROM", "", "SMTP From", "none", logger)
 	config.DBFile = checkConfig("DB_FILE", "./hikvision-anpr-alerts.db", "Database File", "none", logger)
+	config.ExpiredPlates = checkConfig("EXPIRED_PLATES", "keep", "Expired Plates", "expiredplates", logger)
+	config.PlateExpiryDays = checkConfig("PLATE_EXPIRY_DAYS", "7", "Plate Expiry Days", "numeric", logger)
+	config.VisitTimeoutHours = checkConfig("VISIT_TIMEOUT_HOURS", "24", "Visit Timeout Hours", "numeric", logger)
+	config.DwellMinutes = checkConfig("DWELL_MINUTES", "0", "Dwell Minutes", "numeric", logger)
+	config.DwellUnknownOnly = checkConfig("DWELL_UNKNOWN_ONLY", "false", "Dwell Unknown Only", "boolean", logger)
+	config.AlertCooldownMinutes = checkConfig("ALERT_COOLDOWN_MINUTES", "5", "Alert Cooldown Minutes", "numeric", logger)
+	config.AlertRateLimit = checkConfig("ALERT_RATE_LIMIT", "0", "Alert Rate Limit", "numeric", logger)
+	config.WebhookRetentionDays = checkConfig("WEBHOOK_RETENTION_DAYS", "30", "Webhook Retention Days", "numeric", logger)
+	config.OutboxRetentionDays = checkConfig("OUTBOX_RETENTION_DAYS", "30", "Outbox Retention Days", "numeric", logger)
+	config.MQTTBroker = checkConfig("MQTT_BROKER", "", "MQTT Broker", "none", logger)
+	config.MQTTUser = checkConfig("MQTT_USER", "", "MQTT User", "none", logger)
+	config.MQTTPass = checkConfig("MQTT_PASS", "", "MQTT Pass", "none", logger)
+	config.MQTTClientID = checkConfig("MQTT_CLIENT_ID", "hikvision-anpr-alerts", "MQTT Client ID", "", logger)
+	config.MQTTTopicPrefix = checkConfig("MQTT_TOPIC_PREFIX", "hikvision-anpr-alerts", "MQTT Topic Prefix", "", logger)
+	config.MQTTDiscoveryPrefix = checkConfig("MQTT_DISCOVERY_PREFIX", "homeassistant", "MQTT Discovery Prefix", "", logger)
 
 	// Initialize cache store
 	cache := filecache.New("/cache/")
@@ -86,6 +102,11 @@ func Run() {
 		logger.Println("Initialized validator")
 	}
 
+	// Initialize alert limiter
+	alertCooldownMinutes, _ := strconv.Atoi(config.AlertCooldownMinutes)
+	alertRateLimit, _ := strconv.Atoi(config.AlertRateLimit)
+	alertLimiter := models.NewAlertLimiter(time.Duration(alertCooldownMinutes)*time.Minute, alertRateLimit)
+
 	// Initialise env
 	env := &models.Env{
 		Config:              config,
@@ -96,6 +117,7 @@ func Run() {
 		Validator:           validator,
 		ValidatorTranslator: translator,
 		EmbedFS:             &embedFS,
+		AlertLimiter:        alertLimiter,
 	}
 
 	// Perform database migrations
@@ -106,32 +128,14 @@ func Run() {
 		env.Logger.Println("Database migrations complete")
 	}
 
-	// Get cameras to connect to
-	var camera models.Camera
-	resCameras, resCamerasCount, err := camera.Find(env, "AND", []models.WhereFields{}, 0, 1)
-	if err != nil {
-		env.Logger.Println(err)
-	}
-	if resCamerasCount > 0 {
-		// Create a wait group to wait for all connections to close
-		var wg sync.WaitGroup
-		// Connect to each camera
-		for _, cam := range *resCameras {
-			wg.Add(1)
-			go func(c models.Camera, env *models.Env) {
-				defer wg.Done()
-				connectToCamera(c, env)
-			}(cam, env)
-		}
-		// Listen for interrupts
-		sigChan := make(chan os.Signal, 1)
-		signal.Notify(sigChan, os.Interrupt)
-		signal.Notify(sigChan, os.Kill)
-		sig := <-sigChan
-		env.Logger.Println("Camera connections got signal:", sig)
-		// Close camera connections gracefully
-		wg.Wait()
-	}
+	// Connect to MQTT broker
+	env.MQTT = models.NewMQTT(env)
+
+	// Connect to cameras
+	connectToCameras(env)
+
+	// Start background jobs
+	startJobs(env)
 
 	// Load view templates
 	err = views.Load(env)
@@ -182,6 +186,7 @@ func Run() {
 	} else {
 		env.Logger.Println("Shutdown Server")
 	}
+	env.MQTT.Close()
 }
 
 func checkConfig(envKey, defaultValue, name, validationType string, logger *log.Logger) string {
@@ -206,6 +211,16 @@ func checkConfig(envKey, defaultValue, name, validationType string, logger *log.
 		if len(checkVal) != 16 && len(checkVal) != 24 && len(checkVal) != 32 {
 			valid = false
 		}
+	case "expiredplates":
+		// value must be keep, archive or delete
+		if checkVal != "keep" && checkVal != "archive" && checkVal != "delete" {
+			valid = false
+		}
+	case "boolean":
+		// value must be true or false
+		if checkVal != "true" && checkVal != "false" {
+			valid = false
+		}
 	case "numeric":
 		// value must be numeric
 		_, err := strconv.Atoi(checkVal)
@@ -228,7 +243,69 @@ func checkConfig(envKey, defaultValue, name, validationType string, logger *log.
 	return checkVal
 }
 
+func connectToCameras(env *models.Env) {
+	// Get cameras to connect to
+	var camera models.Camera
+	resCameras, _, err := camera.Find(env, "AND", []models.WhereFields{}, 0, 1)
+	if err != nil {
+		env.Logger.Println(err)
+		return
+	}
+	// Connect to each camera
+	for _, cam := range *resCameras {
+		go connectToCamera(cam, env)
+	}
+}
+
+// cameraReconnectDelays are how long to wait before each attempt to reconnect to a camera that's gone
+// offline, with the last repeated until it's back online.
+var cameraReconnectDelays = []time.Duration{5 * time.Second, 15 * time.Second, 30 * time.Second, time.Minute, 5 * time.Minute}
+
+// connectToCamera reads the camera's events, reconnecting with a backoff whenever the connection drops
+// until the camera is deleted.
 func connectToCamera(cam models.Camera, env *models.Env) {
+	status := ""
+	failures := 0
+	for {
+		err := readCameraEvents(cam, env, func() {
+			failures = 0
+			status = "online"
+			publishCameraHealth(env, cam, status, nil)
+		})
+		if status != "offline" {
+			status = "offline"
+			publishCameraHealth(env, cam, status, err)
+		}
+		delay := cameraReconnectDelay(failures)
+		failures++
+		env.Logger.Printf("Reconnecting to %s in %s\n", cam.IPAddress, delay)
+		time.Sleep(delay)
+		// Reload the camera in case it's been changed or deleted
+		resCamera, err := cam.Get(env)
+		if err != nil {
+			if errors.Is(err, env.DB.ErrRecordNotFound) {
+				env.Logger.Printf("Not reconnecting to %s as it's been deleted\n", cam.IPAddress)
+				return
+			}
+			env.Logger.Println(err)
+			continue
+		}
+		cam = *resCamera
+	}
+}
+
+// cameraReconnectDelay returns how long to wait before reconnecting to a camera after the number of
+// failed attempts since it was last online
+func cameraReconnectDelay(failures int) time.Duration {
+	if failures < len(cameraReconnectDelays) {
+		return cameraReconnectDelays[failures]
+	}
+	return cameraReconnectDelays[len(cameraReconnectDelays)-1]
+}
+
+// readCameraEvents connects to the camera's event stream, calling onConnect once connected, and
+// processes its events until the connection drops, returning the error it dropped with.
+func readCameraEvents(cam models.Camera, env *models.Env, onConnect func()) error {
 	// Create WebSocket URL
 	wsURL := fmt.Sprintf("ws://%s/ISAPI/Event/notification/alertStream", cam.IPAddress)
 
@@ -236,62 +313,99 @@ func connectToCamera(cam models.Camera, env *models.Env) {
 	u, err := url.Parse(wsURL)
 	if err != nil {
 		env.Logger.Printf("Error parsing WebSocket URL for %s: %v\n", cam.IPAddress, err)
-		return
+		return err
 	}
-	u.User = url.UserPassword(cam.Username, cam.Password)
+	// Authenticate with basic auth as websocket URLs can't contain credentials
+	header := http.Header{}
+	header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(cam.Username+":"+cam.Password)))
 
 	// Connect to WebSocket
-	c, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	c, _, err := websocket.DefaultDialer.Dial(u.String(), header)
 	if err != nil {
 		env.Logger.Printf("Error connecting to WebSocket for %s: %v\n", cam.IPAddress, err)
-		return
+		return err
 	}
 	defer c.Close()
+	onConnect()
 
 	// Handle incoming messages
 	for {
 		_, msg, err := c.ReadMessage()
 		if err != nil {
 			env.Logger.Printf("Error reading message from %s: %v\n", cam.IPAddress, err)
-			return
+			return err
 		}
-		// Process the received event (e.g., log it)
+		// Process the received event
 		env.Logger.Printf("[%s] Received event: %s\n", cam.IPAddress, string(msg))
-		// TODO: Send alert email if exists in number plate database
-		// sendAlertEmail(numberPlate, env)
+		processEvent(cam, msg, env)
 	}
 }
 
-func sendAlertEmail(numberplate *models.NumberPlate, env *models.Env) {
-	if env.Config.SMTPFrom != "" {
-		email := models.Email{
-			To:      env.Config.SMTPFrom,
-			Subject: "ANPR Alert",
-			Body: hermes.Body{
-				Name: "ANPR Alert",
-				Intros: []string{
-					"Test intro.",
-				},
-				Actions: []hermes.Action{
-					{
-						Instructions: "High priority customer",
-						Button: hermes.Button{
-							Color:     "#4285f4",
-							TextColor: "#fff",
-							Text:      "Test button",
-							Link:      env.Config.ExternalURL,
-						},
-					},
-				},
-				Outros: []string{
-					"Test outro.",
-				},
-			},
-		}
-		err := email.Send(env)
-		if err != nil {
-			env.Logger.Println(err)
-			return
+// sendAlert records an alert and sends it to everyone subscribed to it.
+func sendAlert(notification models.Notification, env *models.Env) {
+	// Record the alert for operators to acknowledge
+	alertRecord := models.Alert{
+		Title:         notification.Title,
+		Message:       notification.Message,
+		DetectionID:   notification.DetectionID,
+		NumberPlateID: notification.NumberPlateID,
+		Plate:         notification.Plate,
+		CameraID:      notification.CameraID,
+		WatchlistID:   notification.WatchlistID,
+		RuleID:        notification.RuleID,
+	}
+	escalationPolicyID, err := models.EscalationPolicyFor(env, notification.RuleID, notification.WatchlistID)
+	if err != nil {
+		env.Logger.Printf("Error getting escalation policy: %s\n", err)
+	}
+	alertRecord.EscalationPolicyID = escalationPolicyID
+	if _, err := alertRecord.Add(env); err != nil {
+		env.Logger.Printf("Error recording alert: %s\n", err)
+	}
+	notification.AlertID = alertRecord.ID
+	if err := notification.Dispatch(env); err != nil {
+		env.Logger.Println(err)
+	}
+	alert := models.WebhookAlert{
+		ID:          alertRecord.ID,
+		Title:       notification.Title,
+		Message:     notification.Message,
+		Fields:      notification.Fields,
+		Link:        notification.Link,
+		Plate:       notification.Plate,
+		DetectionID: notification.DetectionID,
+		CameraID:    notification.CameraID,
+		WatchlistID: notification.WatchlistID,
+		RuleID:      notification.RuleID,
+	}
+	models.TriggerWebhooks(env, "alert", alert)
+	env.MQTT.PublishAlert(alert)
+}
+
+// publishCameraHealth records any outage and sends the camera's status to webhooks and MQTT.
+func publishCameraHealth(env *models.Env, cam models.Camera, status string, err error) {
+	health := models.WebhookCameraHealth{Camera: webhookCamera(cam), Status: status}
+	if err != nil {
+		health.Error = err.Error()
+	}
+	var cameraOutage models.CameraOutage
+	if status == "offline" {
+		if err := cameraOutage.Start(env, cam.ID, health.Error); err != nil {
+			env.Logger.Printf("Error recording outage for %s: %s\n", cam.IPAddress, err)
 		}
+	} else if err := cameraOutage.End(env, cam.ID); err != nil {
+		env.Logger.Printf("Error recording outage for %s: %s\n", cam.IPAddress, err)
 	}
+	models.TriggerWebhooks(env, "camera", health)
+	env.MQTT.PublishCameraHealth(health)
+}
+
+// webhookCamera returns the camera as included in webhook payloads.
+func webhookCamera(cam models.Camera) models.WebhookCamera {
+	return models.WebhookCamera{ID: cam.ID, Name: cam.DisplayName(), IPAddress: cam.IPAddress}
+}
+
+// externalLink returns the link to the path provided in the web interface.
+func externalLink(env *models.Env, path string) string {
+	return strings.TrimRight(env.Config.ExternalURL, "/") + path
 }
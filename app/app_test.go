@@ -0,0 +1,26 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCameraReconnectDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 5 * time.Second},
+		{1, 15 * time.Second},
+		{2, 30 * time.Second},
+		{3, time.Minute},
+		{4, 5 * time.Minute},
+		{5, 5 * time.Minute},
+		{100, 5 * time.Minute},
+	}
+	for _, test := range tests {
+		if got := cameraReconnectDelay(test.failures); got != test.want {
+			t.Errorf("cameraReconnectDelay(%d) = %s, want %s", test.failures, got, test.want)
+		}
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/olivercullimore/hikvision-anpr-alerts/app/models"
 	"github.com/olivercullimore/hikvision-anpr-alerts/app/views"
+	"html/template"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 func AdminNumberPlates(env *models.Env, w http.ResponseWriter, r *http.Request) {
@@ -20,9 +22,31 @@ func AdminNumberPlates(env *models.Env, w http.ResponseWriter, r *http.Request)
 	// Get page number
 	pageNumber := getPageNumber(r)
 
+	// Get filter
+	filter := r.URL.Query().Get("filter")
+	now := time.Now()
+	expiryDays := getPlateExpiryDays(env)
+	fields := []models.WhereFields{{"archived", "=", 0}}
+	switch filter {
+	case "expiring":
+		fields = append(fields, models.WhereFields{"valid_until", ">=", now.Format("2006-01-02")})
+		fields = append(fields, models.WhereFields{"valid_until", "<=", now.AddDate(0, 0, expiryDays).Format("2006-01-02")})
+		page.Title = "Number Plates Expiring Soon"
+	case "archived":
+		fields = []models.WhereFields{{"archived", "=", 1}}
+		page.Title = "Archived Number Plates"
+	default:
+		filter = ""
+	}
+	listRowFields = append(listRowFields, filterLink("All", "/", filter == ""))
+	listRowFields = append(listRowFields, filterLink("Expiring Soon", "/?filter=expiring", filter == "expiring"))
+	listRowFields = append(listRowFields, filterLink("Archived", "/?filter=archived", filter == "archived"))
+	list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	listRowFields = []models.ListRowField{}
+
 	// Get all number plates
 	var numberPlate models.NumberPlate
-	resNumberPlates, resCount, err := numberPlate.Find(env, "AND", []models.WhereFields{}, getPerPage(env), pageNumber)
+	resNumberPlates, resCount, err := numberPlate.Find(env, "AND", fields, getPerPage(env), pageNumber)
 	if err != nil {
 		env.Logger.Println(err)
 		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
@@ -34,6 +58,7 @@ func AdminNumberPlates(env *models.Env, w http.ResponseWriter, r *http.Request)
 	if resCount > 0 {
 		listRowFields = append(listRowFields, models.ListRowField{Value: "Number Plate"})
 		listRowFields = append(listRowFields, models.ListRowField{Value: "Name"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Valid"})
 		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "Actions"})
 		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: ""})
 		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
@@ -41,12 +66,16 @@ func AdminNumberPlates(env *models.Env, w http.ResponseWriter, r *http.Request)
 			var listRowFields []models.ListRowField
 			listRowFields = append(listRowFields, models.ListRowField{Value: resNumberPlate.Plate})
 			listRowFields = append(listRowFields, models.ListRowField{Value: resNumberPlate.Name})
+			listRowFields = append(listRowFields, numberPlateValidity(resNumberPlate, now, expiryDays))
 			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-red", Link: fmt.Sprintf("/%v/delete", resNumberPlate.ID), Confirm: "Are you sure you want to delete this number plate?", Icon: "delete", Value: "Delete"})
 			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-yellow", Link: fmt.Sprintf("/%v", resNumberPlate.ID), Icon: "pencil", Value: "Edit"})
 			list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
 		}
 		// Get pagination
 		list.Pagination = getPagination(env, pageNumber, resCount)
+		if filter != "" {
+			list.Pagination.Query = template.URL("&filter=" + filter)
+		}
 	} else {
 		listRowFields = []models.ListRowField{}
 		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "No number plates found"})
@@ -61,11 +90,51 @@ func AdminNumberPlates(env *models.Env, w http.ResponseWriter, r *http.Request)
 	views.Render(w, env, "list", http.StatusOK, page)
 }
 
+// numberPlateValidity will accept a number plate, the current time and number of days to warn of expiry
+// and will return a list field describing the number plate's validity window.
+func numberPlateValidity(numberPlate models.NumberPlate, now time.Time, expiryDays int) models.ListRowField {
+	field := models.ListRowField{Value: "Always"}
+	switch {
+	case numberPlate.ValidFrom != "" && numberPlate.ValidUntil != "":
+		field.Value = fmt.Sprintf("%s to %s", numberPlate.ValidFrom, numberPlate.ValidUntil)
+	case numberPlate.ValidFrom != "":
+		field.Value = fmt.Sprintf("From %s", numberPlate.ValidFrom)
+	case numberPlate.ValidUntil != "":
+		field.Value = fmt.Sprintf("Until %s", numberPlate.ValidUntil)
+	}
+	if numberPlate.ExpiredAt(now) {
+		field.Value += " (expired)"
+		field.FieldClass = " red"
+	} else if numberPlate.ExpiresWithin(now, expiryDays) {
+		field.Value += " (expiring soon)"
+		field.FieldClass = " yellow"
+	}
+	return field
+}
+
+// findDuplicateNumberPlate will accept a number plate and will return another number plate with the same plate, or nil if there isn't one.
+func findDuplicateNumberPlate(env *models.Env, numberPlate models.NumberPlate) (*models.NumberPlate, error) {
+	if numberPlate.Plate == "" {
+		return nil, nil
+	}
+	resNumberPlates, _, err := numberPlate.FindByPlate(env, numberPlate.Plate)
+	if err != nil {
+		return nil, err
+	}
+	for _, resNumberPlate := range *resNumberPlates {
+		if resNumberPlate.ID != numberPlate.ID {
+			return &resNumberPlate, nil
+		}
+	}
+	return nil, nil
+}
+
 func AdminAddNumberPlate(env *models.Env, w http.ResponseWriter, r *http.Request) {
 	var page = models.Page{Title: "Add Number Plate", RequestURL: r.URL.String(), Theme: getTheme(r)}
 
+	numberPlate := models.NumberPlate{}
+
 	if r.Method == http.MethodPost {
-		numberPlate := models.NumberPlate{}
 
 		// Parse form data ready for use
 		err := r.ParseForm()
@@ -80,6 +149,14 @@ func AdminAddNumberPlate(env *models.Env, w http.ResponseWriter, r *http.Request
 		// Set values
 		numberPlate.Plate = fmt.Sprint(r.Form["plate"][0])
 		numberPlate.Name = fmt.Sprint(r.Form["name"][0])
+		numberPlate.WatchlistID, _ = strconv.Atoi(r.PostFormValue("watchlistid"))
+		numberPlate.ScheduleID, _ = strconv.Atoi(r.PostFormValue("scheduleid"))
+		numberPlate.ValidFrom = r.PostFormValue("validfrom")
+		numberPlate.ValidUntil = r.PostFormValue("validuntil")
+		numberPlate.Archived = numberPlate.Archived && numberPlate.ExpiredAt(time.Now())
+		setVehicleAttributes(&numberPlate.VehicleAttributes, r)
+		numberPlate.AlertOnMismatch = r.PostFormValue("alertonmismatch") != ""
+		numberPlate.AlertDirection = r.PostFormValue("alertdirection")
 		// Validate values
 		err = env.Validator.Struct(numberPlate)
 		if err != nil {
@@ -87,13 +164,39 @@ func AdminAddNumberPlate(env *models.Env, w http.ResponseWriter, r *http.Request
 				page.ErrorMessages = append(page.ErrorMessages, e.Translate(env.ValidatorTranslator))
 			}
 		}
+		if numberPlate.AlertOnMismatch && numberPlate.VehicleAttributes.IsEmpty() {
+			page.ErrorMessages = append(page.ErrorMessages, "Alerting on mismatch requires at least one registered vehicle attribute")
+		}
+		// Check the number plate hasn't already been added, restoring it if it was archived
+		duplicateNumberPlate, err := findDuplicateNumberPlate(env, numberPlate)
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+		if duplicateNumberPlate != nil && !duplicateNumberPlate.Archived {
+			page.ErrorMessages = append(page.ErrorMessages, fmt.Sprintf("Number plate %s already exists", numberPlate.Plate))
+		}
 
 		// Check for errors
 		if len(page.ErrorMessages) == 0 {
 			// Check required fields
 			if numberPlate.Plate != "" {
-				// Add number plate to database
-				_, err = numberPlate.Add(env)
+				if duplicateNumberPlate != nil {
+					// Restore the archived number plate with the new details
+					numberPlate.ID = duplicateNumberPlate.ID
+					_, err = numberPlate.Update(env)
+				} else {
+					// Add number plate to database
+					_, err = numberPlate.Add(env)
+				}
+				if err == nil {
+					// Save camera scopes
+					err = saveCameraScopes(env, "numberplate", numberPlate.ID, r)
+				}
 				if err != nil {
 					env.Logger.Println(err)
 					err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
@@ -117,10 +220,15 @@ func AdminAddNumberPlate(env *models.Env, w http.ResponseWriter, r *http.Request
 
 	}
 
-	form := models.Form{CancelLink: "/"}
-	form.Fields = append(form.Fields, models.FormField{Name: "plate", Title: "Number Plate *", Type: "text", Required: true, Placeholder: "Number Plate"})
-	form.Fields = append(form.Fields, models.FormField{Name: "name", Title: "Name", Type: "text", Required: false, Placeholder: "Name"})
-	form.SubmitName = "Save Changes"
+	form, err := numberPlateForm(env, numberPlate)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
 
 	page.View = form
 
@@ -173,6 +281,14 @@ func AdminEditNumberPlate(env *models.Env, w http.ResponseWriter, r *http.Reques
 		// Set values
 		numberPlate.Plate = fmt.Sprint(r.Form["plate"][0])
 		numberPlate.Name = fmt.Sprint(r.Form["name"][0])
+		numberPlate.WatchlistID, _ = strconv.Atoi(r.PostFormValue("watchlistid"))
+		numberPlate.ScheduleID, _ = strconv.Atoi(r.PostFormValue("scheduleid"))
+		numberPlate.ValidFrom = r.PostFormValue("validfrom")
+		numberPlate.ValidUntil = r.PostFormValue("validuntil")
+		numberPlate.Archived = numberPlate.Archived && numberPlate.ExpiredAt(time.Now())
+		setVehicleAttributes(&numberPlate.VehicleAttributes, r)
+		numberPlate.AlertOnMismatch = r.PostFormValue("alertonmismatch") != ""
+		numberPlate.AlertDirection = r.PostFormValue("alertdirection")
 		// Validate values
 		err = env.Validator.Struct(numberPlate)
 		if err != nil {
@@ -180,6 +296,26 @@ func AdminEditNumberPlate(env *models.Env, w http.ResponseWriter, r *http.Reques
 				page.ErrorMessages = append(page.ErrorMessages, e.Translate(env.ValidatorTranslator))
 			}
 		}
+		if numberPlate.AlertOnMismatch && numberPlate.VehicleAttributes.IsEmpty() {
+			page.ErrorMessages = append(page.ErrorMessages, "Alerting on mismatch requires at least one registered vehicle attribute")
+		}
+		// Check the number plate hasn't already been added
+		duplicateNumberPlate, err := findDuplicateNumberPlate(env, numberPlate)
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+		if duplicateNumberPlate != nil {
+			if duplicateNumberPlate.Archived {
+				page.ErrorMessages = append(page.ErrorMessages, fmt.Sprintf("Number plate %s already exists in the archived number plates", numberPlate.Plate))
+			} else {
+				page.ErrorMessages = append(page.ErrorMessages, fmt.Sprintf("Number plate %s already exists", numberPlate.Plate))
+			}
+		}
 
 		// Check for errors
 		if len(page.ErrorMessages) == 0 {
@@ -187,6 +323,10 @@ func AdminEditNumberPlate(env *models.Env, w http.ResponseWriter, r *http.Reques
 			if numberPlate.Plate != "" {
 				// Update number plate in database
 				_, err = numberPlate.Update(env)
+				if err == nil {
+					// Save camera scopes
+					err = saveCameraScopes(env, "numberplate", numberPlate.ID, r)
+				}
 				if err != nil {
 					env.Logger.Println(err)
 					err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
@@ -210,10 +350,15 @@ func AdminEditNumberPlate(env *models.Env, w http.ResponseWriter, r *http.Reques
 
 	}
 
-	form := models.Form{CancelLink: "/"}
-	form.Fields = append(form.Fields, models.FormField{Name: "plate", Title: "Number Plate *", Type: "text", Required: true, Placeholder: "Number Plate", Value: numberPlate.Plate})
-	form.Fields = append(form.Fields, models.FormField{Name: "name", Title: "Name", Type: "text", Required: false, Placeholder: "Name", Value: numberPlate.Name})
-	form.SubmitName = "Save Changes"
+	form, err := numberPlateForm(env, numberPlate)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
 
 	page.View = form
 
@@ -261,6 +406,35 @@ func AdminDeleteNumberPlate(env *models.Env, w http.ResponseWriter, r *http.Requ
 	http.Redirect(w, r, "/", 302)
 }
 
+// numberPlateForm will accept a number plate and will return the form to add or edit it.
+func numberPlateForm(env *models.Env, numberPlate models.NumberPlate) (models.Form, error) {
+	form := models.Form{CancelLink: "/"}
+	watchlistOpts, err := watchlistOptions(env, numberPlate.WatchlistID)
+	if err != nil {
+		return form, err
+	}
+	scheduleOpts, err := scheduleOptions(env, numberPlate.ScheduleID)
+	if err != nil {
+		return form, err
+	}
+	cameraOpts, err := cameraScopeOptions(env, "numberplate", numberPlate.ID)
+	if err != nil {
+		return form, err
+	}
+	form.Fields = append(form.Fields, models.FormField{Name: "plate", Title: "Number Plate *", Type: "text", Required: true, Placeholder: "Number Plate", Value: numberPlate.Plate})
+	form.Fields = append(form.Fields, models.FormField{Name: "name", Title: "Name", Type: "text", Required: false, Placeholder: "Name", Value: numberPlate.Name})
+	form.Fields = append(form.Fields, models.FormField{Name: "watchlistid", Title: "Watchlist", Type: "select", Options: watchlistOpts})
+	form.Fields = append(form.Fields, models.FormField{Name: "scheduleid", Title: "Alert Schedule", Type: "select", Options: scheduleOpts})
+	form.Fields = append(form.Fields, models.FormField{Name: "validfrom", Title: "Valid From", Type: "date", Required: false, Value: numberPlate.ValidFrom})
+	form.Fields = append(form.Fields, models.FormField{Name: "validuntil", Title: "Valid Until", Type: "date", Required: false, Value: numberPlate.ValidUntil})
+	form.Fields = append(form.Fields, models.FormField{Name: "cameras", Title: "Alert Cameras (none selected alerts at all cameras)", Type: "select", Multiple: true, Options: cameraOpts})
+	form.Fields = append(form.Fields, models.FormField{Name: "alertdirection", Title: "Alert When", Type: "select", Options: directionOptions(numberPlate.AlertDirection, "Entering or leaving")})
+	form.Fields = append(form.Fields, vehicleAttributeFields(numberPlate.VehicleAttributes, "Registered ")...)
+	form.Fields = append(form.Fields, models.FormField{Name: "alertonmismatch", Title: "Only alert when the vehicle doesn't match its registered attributes (e.g. cloned plates)", Type: "checkbox", Value: "1", Checked: numberPlate.AlertOnMismatch})
+	form.SubmitName = "Save Changes"
+	return form, nil
+}
+
 func AdminCameras(env *models.Env, w http.ResponseWriter, r *http.Request) {
 	var page = models.Page{Title: "Cameras", RequestURL: r.URL.String(), Theme: getTheme(r)}
 
@@ -303,7 +477,8 @@ func AdminCameras(env *models.Env, w http.ResponseWriter, r *http.Request) {
 		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
 	}
 	listRowFields = []models.ListRowField{}
-	listRowFields = append(listRowFields, models.ListRowField{Type: "link", Class: "btn btn-icon btn-primary", Link: "/cameras/add", Icon: "plus", Value: "Add"})
+	listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-primary", Link: "/cameras/add", Icon: "plus", Value: "Add"})
+	listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Type: "link", Class: "btn btn-icon", Link: "/cameras/groups", Icon: "group", Value: "Camera Groups"})
 	list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
 
 	page.View = list
@@ -314,8 +489,9 @@ func AdminCameras(env *models.Env, w http.ResponseWriter, r *http.Request) {
 func AdminAddCamera(env *models.Env, w http.ResponseWriter, r *http.Request) {
 	var page = models.Page{Title: "Add Camera", RequestURL: r.URL.String(), Theme: getTheme(r)}
 
+	camera := models.Camera{}
+
 	if r.Method == http.MethodPost {
-		camera := models.Camera{}
 
 		// Parse form data ready for use
 		err := r.ParseForm()
@@ -332,6 +508,14 @@ func AdminAddCamera(env *models.Env, w http.ResponseWriter, r *http.Request) {
 		camera.IPAddress = fmt.Sprint(r.Form["ipaddress"][0])
 		camera.Username = fmt.Sprint(r.Form["username"][0])
 		camera.Password = fmt.Sprint(r.Form["password"][0])
+		camera.ScheduleID, _ = strconv.Atoi(r.PostFormValue("scheduleid"))
+		camera.CameraGroupID, _ = strconv.Atoi(r.PostFormValue("cameragroupid"))
+		camera.MinConfidence, _ = strconv.Atoi(r.PostFormValue("minconfidence"))
+		camera.ForwardIs = r.PostFormValue("forwardis")
+		camera.LoiterCount, _ = strconv.Atoi(r.PostFormValue("loitercount"))
+		camera.LoiterMinutes, _ = strconv.Atoi(r.PostFormValue("loiterminutes"))
+		camera.TailgateSeconds, _ = strconv.Atoi(r.PostFormValue("tailgateseconds"))
+		camera.CooldownMinutes, _ = strconv.Atoi(r.PostFormValue("cooldownminutes"))
 		// Validate values
 		err = env.Validator.Struct(camera)
 		if err != nil {
@@ -339,6 +523,7 @@ func AdminAddCamera(env *models.Env, w http.ResponseWriter, r *http.Request) {
 				page.ErrorMessages = append(page.ErrorMessages, e.Translate(env.ValidatorTranslator))
 			}
 		}
+		page.ErrorMessages = append(page.ErrorMessages, validateLoitering(camera.LoiterCount, camera.LoiterMinutes)...)
 
 		// Check for errors
 		if len(page.ErrorMessages) == 0 {
@@ -354,6 +539,8 @@ func AdminAddCamera(env *models.Env, w http.ResponseWriter, r *http.Request) {
 					}
 					return
 				} else {
+					// Publish the camera's Home Assistant discovery config
+					env.MQTT.PublishCamera(camera)
 					// Add admin log to database
 					err = adminLog(env, r, "camera", fmt.Sprintf("Add camera %s", camera.IPAddress))
 					if err != nil {
@@ -369,12 +556,15 @@ func AdminAddCamera(env *models.Env, w http.ResponseWriter, r *http.Request) {
 
 	}
 
-	form := models.Form{CancelLink: "/cameras"}
-	form.Fields = append(form.Fields, models.FormField{Name: "name", Title: "Name", Type: "text", Required: false, Placeholder: "Name"})
-	form.Fields = append(form.Fields, models.FormField{Name: "ipaddress", Title: "IP Address *", Type: "text", Required: true, Placeholder: "IP Address"})
-	form.Fields = append(form.Fields, models.FormField{Name: "username", Title: "Username *", Type: "text", Required: true, Placeholder: "Username"})
-	form.Fields = append(form.Fields, models.FormField{Name: "password", Title: "Password *", Type: "text", Required: true, Placeholder: "Password"})
-	form.SubmitName = "Save Changes"
+	form, err := cameraForm(env, camera)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
 
 	page.View = form
 
@@ -429,6 +619,14 @@ func AdminEditCamera(env *models.Env, w http.ResponseWriter, r *http.Request) {
 		camera.IPAddress = fmt.Sprint(r.Form["ipaddress"][0])
 		camera.Username = fmt.Sprint(r.Form["username"][0])
 		camera.Password = fmt.Sprint(r.Form["password"][0])
+		camera.ScheduleID, _ = strconv.Atoi(r.PostFormValue("scheduleid"))
+		camera.CameraGroupID, _ = strconv.Atoi(r.PostFormValue("cameragroupid"))
+		camera.MinConfidence, _ = strconv.Atoi(r.PostFormValue("minconfidence"))
+		camera.ForwardIs = r.PostFormValue("forwardis")
+		camera.LoiterCount, _ = strconv.Atoi(r.PostFormValue("loitercount"))
+		camera.LoiterMinutes, _ = strconv.Atoi(r.PostFormValue("loiterminutes"))
+		camera.TailgateSeconds, _ = strconv.Atoi(r.PostFormValue("tailgateseconds"))
+		camera.CooldownMinutes, _ = strconv.Atoi(r.PostFormValue("cooldownminutes"))
 		// Validate values
 		err = env.Validator.Struct(camera)
 		if err != nil {
@@ -436,6 +634,7 @@ func AdminEditCamera(env *models.Env, w http.ResponseWriter, r *http.Request) {
 				page.ErrorMessages = append(page.ErrorMessages, e.Translate(env.ValidatorTranslator))
 			}
 		}
+		page.ErrorMessages = append(page.ErrorMessages, validateLoitering(camera.LoiterCount, camera.LoiterMinutes)...)
 
 		// Check for errors
 		if len(page.ErrorMessages) == 0 {
@@ -451,6 +650,8 @@ func AdminEditCamera(env *models.Env, w http.ResponseWriter, r *http.Request) {
 					}
 					return
 				} else {
+					// Publish the camera's Home Assistant discovery config
+					env.MQTT.PublishCamera(camera)
 					// Add admin log to database
 					err = adminLog(env, r, "camera", fmt.Sprintf("Update camera id %d", camera.ID))
 					if err != nil {
@@ -466,12 +667,15 @@ func AdminEditCamera(env *models.Env, w http.ResponseWriter, r *http.Request) {
 
 	}
 
-	form := models.Form{CancelLink: "/cameras"}
-	form.Fields = append(form.Fields, models.FormField{Name: "name", Title: "Name", Type: "text", Required: false, Placeholder: "Name", Value: camera.Name})
-	form.Fields = append(form.Fields, models.FormField{Name: "ipaddress", Title: "IP Address *", Type: "text", Required: true, Placeholder: "IP Address", Value: camera.IPAddress})
-	form.Fields = append(form.Fields, models.FormField{Name: "username", Title: "Username *", Type: "text", Required: true, Placeholder: "Username", Value: camera.Username})
-	form.Fields = append(form.Fields, models.FormField{Name: "password", Title: "Password *", Type: "text", Required: true, Placeholder: "Password", Value: camera.Password})
-	form.SubmitName = "Save Changes"
+	form, err := cameraForm(env, camera)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
 
 	page.View = form
 
@@ -507,6 +711,8 @@ func AdminDeleteCamera(env *models.Env, w http.ResponseWriter, r *http.Request)
 			}
 			return
 		}
+		// Remove the camera's Home Assistant discovery config
+		env.MQTT.RemoveCamera(camera.ID)
 
 		// Add admin log to database
 		err = adminLog(env, r, "camera", fmt.Sprintf("Delete camera id %d", camera.ID))
@@ -519,6 +725,63 @@ func AdminDeleteCamera(env *models.Env, w http.ResponseWriter, r *http.Request)
 	http.Redirect(w, r, "/cameras", 302)
 }
 
+// cameraForm will accept a camera and will return the form to add or edit it.
+func cameraForm(env *models.Env, camera models.Camera) (models.Form, error) {
+	form := models.Form{CancelLink: "/cameras"}
+	scheduleOpts, err := scheduleOptions(env, camera.ScheduleID)
+	if err != nil {
+		return form, err
+	}
+	cameraGroupOpts, err := cameraGroupOptions(env, camera.CameraGroupID)
+	if err != nil {
+		return form, err
+	}
+	form.Fields = append(form.Fields, models.FormField{Name: "name", Title: "Name", Type: "text", Required: false, Placeholder: "Name", Value: camera.Name})
+	form.Fields = append(form.Fields, models.FormField{Name: "ipaddress", Title: "IP Address *", Type: "text", Required: true, Placeholder: "IP Address", Value: camera.IPAddress})
+	form.Fields = append(form.Fields, models.FormField{Name: "username", Title: "Username *", Type: "text", Required: true, Placeholder: "Username", Value: camera.Username})
+	form.Fields = append(form.Fields, models.FormField{Name: "password", Title: "Password *", Type: "text", Required: true, Placeholder: "Password", Value: camera.Password})
+	form.Fields = append(form.Fields, models.FormField{Name: "cameragroupid", Title: "Camera Group", Type: "select", Options: cameraGroupOpts})
+	form.Fields = append(form.Fields, models.FormField{Name: "scheduleid", Title: "Alert Schedule", Type: "select", Options: scheduleOpts})
+	form.Fields = append(form.Fields, models.FormField{Name: "minconfidence", Title: "Minimum Confidence (0-100, reads below this are flagged and not alerted on)", Type: "number", Required: false, Placeholder: "0", Value: strconv.Itoa(camera.MinConfidence)})
+	form.Fields = append(form.Fields, models.FormField{Name: "forwardis", Title: "Vehicles Travelling Forward Are", Type: "select", Options: directionOptions(camera.ForwardIs, "Not used for entry or exit")})
+	form.Fields = append(form.Fields, loiterFields(camera.LoiterCount, camera.LoiterMinutes, "by this camera")...)
+	form.Fields = append(form.Fields, models.FormField{Name: "tailgateseconds", Title: "Tailgating Alert When An Unauthorised Vehicle Follows An Authorised One Within (seconds, 0 to disable)", Type: "number", Required: false, Placeholder: "0", Value: strconv.Itoa(camera.TailgateSeconds)})
+	form.Fields = append(form.Fields, models.FormField{Name: "cooldownminutes", Title: "Alert Cooldown (minutes to suppress repeat alerts for the same number plate, 0 uses the global cooldown)", Type: "number", Required: false, Placeholder: "0", Value: strconv.Itoa(camera.CooldownMinutes)})
+	form.SubmitName = "Save Changes"
+	return form, nil
+}
+
+// getCameraNames will return a map of camera names, or IP addresses for cameras without a name, by ID.
+func getCameraNames(env *models.Env) (map[int]string, error) {
+	names := map[int]string{}
+	var camera models.Camera
+	resCameras, _, err := camera.Find(env, "AND", []models.WhereFields{}, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, resCamera := range *resCameras {
+		names[resCamera.ID] = resCamera.Name
+		if resCamera.Name == "" {
+			names[resCamera.ID] = resCamera.IPAddress
+		}
+	}
+	return names, nil
+}
+
+// getUserEmails will return a map of user email addresses by ID.
+func getUserEmails(env *models.Env) (map[int]string, error) {
+	emails := map[int]string{}
+	var user models.User
+	resUsers, _, err := user.Find(env, "AND", []models.WhereFields{}, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, resUser := range *resUsers {
+		emails[resUser.ID] = resUser.Email
+	}
+	return emails, nil
+}
+
 func AdminUsers(env *models.Env, w http.ResponseWriter, r *http.Request) {
 	var page = models.Page{Title: "Users", RequestURL: r.URL.String(), Theme: getTheme(r)}
 
@@ -543,10 +806,14 @@ func AdminUsers(env *models.Env, w http.ResponseWriter, r *http.Request) {
 		listRowFields = append(listRowFields, models.ListRowField{Value: "Name"})
 		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "Actions"})
 		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: ""})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: ""})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: ""})
 		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
 		for _, resUser := range *resUsers {
 			var listRowFields []models.ListRowField
 			listRowFields = append(listRowFields, models.ListRowField{Value: resUser.Email})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon", Link: fmt.Sprintf("/users/%v/subscriptions", resUser.ID), Icon: "bell", Value: "Subscriptions"})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon", Link: fmt.Sprintf("/users/%v/quiet", resUser.ID), Icon: "bell-sleep", Value: "Quiet Hours"})
 			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-red", Link: fmt.Sprintf("/users/%v/delete", resUser.ID), Confirm: "Are you sure you want to delete this user?", Icon: "delete", Value: "Delete"})
 			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-yellow", Link: fmt.Sprintf("/users/%v", resUser.ID), Icon: "pencil", Value: "Edit"})
 			list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
@@ -0,0 +1,532 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/models"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/views"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func AdminAlerts(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Alerts", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	list := models.List{}
+	var listRowFields []models.ListRowField
+
+	// Get page number
+	pageNumber := getPageNumber(r)
+
+	// Get filter
+	filter := r.URL.Query().Get("filter")
+	var fields []models.WhereFields
+	switch filter {
+	case "new", "acknowledged", "resolved":
+		fields = append(fields, models.WhereFields{"state", "=", filter})
+	default:
+		filter = ""
+	}
+	listRowFields = append(listRowFields, filterLink("All", "/alerts", filter == ""))
+	listRowFields = append(listRowFields, filterLink("New", "/alerts?filter=new", filter == "new"))
+	listRowFields = append(listRowFields, filterLink("Acknowledged", "/alerts?filter=acknowledged", filter == "acknowledged"))
+	listRowFields = append(listRowFields, filterLink("Resolved", "/alerts?filter=resolved", filter == "resolved"))
+	list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	listRowFields = []models.ListRowField{}
+
+	// Get alerts
+	var alert models.Alert
+	resAlerts, resCount, err := alert.Find(env, "AND", fields, getPerPage(env), pageNumber)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	if resCount > 0 {
+		// Get camera names and user emails
+		cameraNames, err := getCameraNames(env)
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+		userEmails, err := getUserEmails(env)
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Time"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Alert"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Camera"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "State"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Handled By"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Note"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "Actions"})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		for _, resAlert := range *resAlerts {
+			var listRowFields []models.ListRowField
+			listRowFields = append(listRowFields, models.ListRowField{Value: resAlert.CreatedAt})
+			listRowFields = append(listRowFields, models.ListRowField{Type: "link", Link: fmt.Sprintf("/alerts/%v", resAlert.ID), Value: resAlert.Message})
+			listRowFields = append(listRowFields, models.ListRowField{Value: cameraNames[resAlert.CameraID]})
+			listRowFields = append(listRowFields, models.ListRowField{Value: models.AlertStates[resAlert.State]})
+			listRowFields = append(listRowFields, models.ListRowField{Value: alertHandledBy(resAlert, userEmails)})
+			listRowFields = append(listRowFields, models.ListRowField{Value: resAlert.Note})
+			if resAlert.State == "new" {
+				listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-primary", Link: fmt.Sprintf("/alerts/%v/acknowledge", resAlert.ID), Icon: "check", Value: "Acknowledge"})
+			} else if resAlert.State == "acknowledged" {
+				listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-primary", Link: fmt.Sprintf("/alerts/%v/resolve", resAlert.ID), Icon: "check-all", Value: "Resolve"})
+			} else {
+				listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Value: ""})
+			}
+			list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		}
+		// Get pagination
+		list.Pagination = getPagination(env, pageNumber, resCount)
+		if filter != "" {
+			list.Pagination.Query = template.URL("&filter=" + filter)
+		}
+	} else {
+		listRowFields = []models.ListRowField{}
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "No alerts found"})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	}
+	listRowFields = []models.ListRowField{}
+	listRowFields = append(listRowFields, models.ListRowField{Type: "link", Class: "btn btn-icon", Link: "/alerts/deliveries", Icon: "send", Value: "Deliveries"})
+	list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+
+	page.View = list
+
+	views.Render(w, env, "list", http.StatusOK, page)
+}
+
+func AdminAlert(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Alert", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	list := models.List{}
+
+	alert, ok := getAlert(env, w, r)
+	if !ok {
+		return
+	}
+	page.Title = alert.Title
+
+	// Get camera names, user emails and notification channel names
+	cameraNames, err := getCameraNames(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	userEmails, err := getUserEmails(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	channelNames, err := getNotificationChannelNames(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	escalationPolicyNames, err := getEscalationPolicyNames(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	// Get the alert's deliveries
+	var outboxMessage models.OutboxMessage
+	resOutboxMessages, _, err := outboxMessage.Find(env, "AND", []models.WhereFields{{"alert_id", "=", alert.ID}}, 0, 1)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	plate := models.ListRowField{Value: alert.Plate}
+	if alert.DetectionID != 0 {
+		plate = models.ListRowField{Type: "link", Link: fmt.Sprintf("/detections/%v", alert.DetectionID), Value: alert.Plate}
+	}
+	details := []models.ListRowField{
+		{Value: "Time"}, {Value: alert.CreatedAt},
+		{Value: "Alert"}, {Value: alert.Message},
+		{Value: "Number Plate"}, plate,
+		{Value: "Camera"}, {Value: cameraNames[alert.CameraID]},
+		{Value: "State"}, {Value: models.AlertStates[alert.State]},
+		{Value: "Escalation"}, {Value: alertEscalation(alert, escalationPolicyNames)},
+		{Value: "Acknowledged"}, {Value: alertUserTime(alert.AcknowledgedBy, alert.AcknowledgedAt, userEmails)},
+		{Value: "Resolved"}, {Value: alertUserTime(alert.ResolvedBy, alert.ResolvedAt, userEmails)},
+		{Value: "Note"}, {Value: alert.Note},
+	}
+	for i := 0; i < len(details); i += 2 {
+		details[i].FieldClass = " field-width-auto field-padding-right"
+		list.Rows = append(list.Rows, models.ListRow{Fields: details[i : i+2]})
+	}
+	var listRowFields []models.ListRowField
+	if alert.State == "new" {
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-primary", Link: fmt.Sprintf("/alerts/%v/acknowledge", alert.ID), Icon: "check", Value: "Acknowledge"})
+	}
+	if alert.State != "resolved" {
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-primary", Link: fmt.Sprintf("/alerts/%v/resolve", alert.ID), Icon: "check-all", Value: "Resolve"})
+	}
+	listRowFields = append(listRowFields, models.ListRowField{Type: "link", Class: "btn btn-icon", Link: "/alerts", Icon: "arrow-left", Value: "Back"})
+	list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	if len(*resOutboxMessages) > 0 {
+		list.Rows = append(list.Rows, outboxHeaderRow())
+		for _, resOutboxMessage := range *resOutboxMessages {
+			list.Rows = append(list.Rows, outboxRow(resOutboxMessage, channelNames))
+		}
+	}
+
+	page.View = list
+
+	views.Render(w, env, "list", http.StatusOK, page)
+}
+
+func AdminAcknowledgeAlert(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	handleAlert(env, w, r, "acknowledge")
+}
+
+func AdminResolveAlert(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	handleAlert(env, w, r, "resolve")
+}
+
+// handleAlert will show the form to acknowledge or resolve an alert with a note and will save it.
+func handleAlert(env *models.Env, w http.ResponseWriter, r *http.Request, action string) {
+	alert, ok := getAlert(env, w, r)
+	if !ok {
+		return
+	}
+
+	title := "Acknowledge"
+	if action == "resolve" {
+		title = "Resolve"
+	}
+	var page = models.Page{Title: fmt.Sprintf("%s %s", title, alert.Title), RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	// Only new alerts can be acknowledged and resolved alerts can't be resolved again
+	if (action == "acknowledge" && alert.State != "new") || (action == "resolve" && alert.State == "resolved") {
+		page.ErrorMessages = append(page.ErrorMessages, fmt.Sprintf("Alert has already been %s", alert.State))
+	}
+
+	if r.Method == http.MethodPost && len(page.ErrorMessages) == 0 {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		userID, err := getSessionUserID(env, r)
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Update alert in database
+		note := strings.TrimSpace(r.PostFormValue("note"))
+		if action == "resolve" {
+			_, err = alert.Resolve(env, userID, note)
+		} else {
+			_, err = alert.Acknowledge(env, userID, note)
+		}
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+		// Add admin log to database
+		logDetails := fmt.Sprintf("%s alert id %d", title, alert.ID)
+		if note != "" {
+			logDetails += ": " + note
+		}
+		err = adminLog(env, r, "alert", logDetails)
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		// Redirect
+		http.Redirect(w, r, fmt.Sprintf("/alerts/%d", alert.ID), 302)
+		return
+	}
+
+	form := models.Form{CancelLink: fmt.Sprintf("/alerts/%d", alert.ID)}
+	form.Fields = append(form.Fields, models.FormField{Name: "note", Title: fmt.Sprintf("Note (%s)", alert.Message), Type: "textarea", Placeholder: "What was done about the alert", Value: alert.Note})
+	form.SubmitName = title
+	page.View = form
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminAlertDeliveries(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Alert Deliveries", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	list := models.List{}
+	var listRowFields []models.ListRowField
+
+	// Get page number
+	pageNumber := getPageNumber(r)
+
+	// Get filter
+	filter := r.URL.Query().Get("filter")
+	var fields []models.WhereFields
+	switch filter {
+	case "pending", "sent", "failed":
+		fields = append(fields, models.WhereFields{"status", "=", filter})
+	default:
+		filter = ""
+	}
+	listRowFields = append(listRowFields, filterLink("All", "/alerts/deliveries", filter == ""))
+	listRowFields = append(listRowFields, filterLink("Pending", "/alerts/deliveries?filter=pending", filter == "pending"))
+	listRowFields = append(listRowFields, filterLink("Sent", "/alerts/deliveries?filter=sent", filter == "sent"))
+	listRowFields = append(listRowFields, filterLink("Failed", "/alerts/deliveries?filter=failed", filter == "failed"))
+	list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	listRowFields = []models.ListRowField{}
+
+	// Get outbox messages
+	var outboxMessage models.OutboxMessage
+	resOutboxMessages, resCount, err := outboxMessage.Find(env, "AND", fields, getPerPage(env), pageNumber)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	if resCount > 0 {
+		channelNames, err := getNotificationChannelNames(env)
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+		list.Rows = append(list.Rows, outboxHeaderRow())
+		for _, resOutboxMessage := range *resOutboxMessages {
+			list.Rows = append(list.Rows, outboxRow(resOutboxMessage, channelNames))
+		}
+		// Get pagination
+		list.Pagination = getPagination(env, pageNumber, resCount)
+		if filter != "" {
+			list.Pagination.Query = template.URL("&filter=" + filter)
+		}
+	} else {
+		listRowFields = []models.ListRowField{}
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "No deliveries found"})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	}
+	listRowFields = []models.ListRowField{}
+	listRowFields = append(listRowFields, models.ListRowField{Type: "link", Class: "btn btn-icon", Link: "/alerts", Icon: "arrow-left", Value: "Back"})
+	list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+
+	page.View = list
+
+	views.Render(w, env, "list", http.StatusOK, page)
+}
+
+func AdminResendAlertDelivery(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		// Parse GET parameters ready for use
+		vars := mux.Vars(r)
+
+		outboxMessageID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		outboxMessage := models.OutboxMessage{ID: outboxMessageID}
+		resOutboxMessage, err := outboxMessage.Get(env)
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "404", "Oops! Page Not Found", "404 Page Not Found")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Queue the alert to be delivered again
+		if err := resOutboxMessage.Resend(env); err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Add admin log to database
+		err = adminLog(env, r, "alert", fmt.Sprintf("Resend alert id %d", resOutboxMessage.ID))
+		if err != nil {
+			env.Logger.Println(err)
+		}
+	}
+
+	// Redirect
+	http.Redirect(w, r, "/alerts/deliveries?filter=pending", 302)
+}
+
+// getAlert will get the alert from the request's ID, displaying an error and returning false if it isn't found.
+func getAlert(env *models.Env, w http.ResponseWriter, r *http.Request) (models.Alert, bool) {
+	// Parse GET parameters ready for use
+	vars := mux.Vars(r)
+
+	alertID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return models.Alert{}, false
+	}
+
+	alert := models.Alert{ID: alertID}
+	resAlert, err := alert.Get(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "404", "Oops! Page Not Found", "404 Page Not Found")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return models.Alert{}, false
+	}
+	return *resAlert, true
+}
+
+// alertHandledBy will accept an alert and the user emails and will return who acknowledged or resolved it.
+func alertHandledBy(alert models.Alert, userEmails map[int]string) string {
+	if alert.ResolvedBy != 0 {
+		return alertUserTime(alert.ResolvedBy, alert.ResolvedAt, userEmails)
+	}
+	return alertUserTime(alert.AcknowledgedBy, alert.AcknowledgedAt, userEmails)
+}
+
+// alertUserTime will accept a user ID, time and the user emails and will return who handled an alert and when.
+func alertUserTime(userID int, at string, userEmails map[int]string) string {
+	if userID == 0 {
+		return ""
+	}
+	email, ok := userEmails[userID]
+	if !ok {
+		email = "Deleted user"
+	}
+	return fmt.Sprintf("%s at %s", email, at)
+}
+
+// outboxHeaderRow will return the header row of a list of outbox messages.
+func outboxHeaderRow() models.ListRow {
+	var listRowFields []models.ListRowField
+	listRowFields = append(listRowFields, models.ListRowField{Value: "Time"})
+	listRowFields = append(listRowFields, models.ListRowField{Value: "Alert"})
+	listRowFields = append(listRowFields, models.ListRowField{Value: "Recipient"})
+	listRowFields = append(listRowFields, models.ListRowField{Value: "Status"})
+	listRowFields = append(listRowFields, models.ListRowField{Value: "Attempts"})
+	listRowFields = append(listRowFields, models.ListRowField{Value: "Error"})
+	listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "Actions"})
+	return models.ListRow{Fields: listRowFields}
+}
+
+// outboxRow will accept an outbox message and the notification channel names and will return its row in a list.
+func outboxRow(outboxMessage models.OutboxMessage, channelNames map[int]string) models.ListRow {
+	var listRowFields []models.ListRowField
+	listRowFields = append(listRowFields, models.ListRowField{Value: outboxMessage.CreatedAt})
+	if outboxMessage.AlertID != 0 {
+		listRowFields = append(listRowFields, models.ListRowField{Type: "link", Link: fmt.Sprintf("/alerts/%v", outboxMessage.AlertID), Value: outboxMessage.Title})
+	} else {
+		listRowFields = append(listRowFields, models.ListRowField{Value: outboxMessage.Title})
+	}
+	listRowFields = append(listRowFields, models.ListRowField{Value: outboxRecipient(outboxMessage, channelNames)})
+	listRowFields = append(listRowFields, models.ListRowField{Value: outboxStatus(outboxMessage)})
+	listRowFields = append(listRowFields, models.ListRowField{Value: strconv.Itoa(outboxMessage.Attempts)})
+	listRowFields = append(listRowFields, models.ListRowField{Value: outboxMessage.Error})
+	if outboxMessage.Status == "failed" {
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-primary", Link: fmt.Sprintf("/alerts/deliveries/%v/resend", outboxMessage.ID), Icon: "send", Value: "Resend"})
+	} else {
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Value: ""})
+	}
+	return models.ListRow{Fields: listRowFields}
+}
+
+// outboxRecipient will accept an outbox message and the notification channel names and will return who it's sent to.
+func outboxRecipient(outboxMessage models.OutboxMessage, channelNames map[int]string) string {
+	if outboxMessage.Email != "" {
+		return outboxMessage.Email
+	}
+	if name, ok := channelNames[outboxMessage.ChannelID]; ok {
+		return name
+	}
+	return fmt.Sprintf("Deleted channel %d", outboxMessage.ChannelID)
+}
+
+// outboxStatus will accept an outbox message and will return its delivery status.
+func outboxStatus(outboxMessage models.OutboxMessage) string {
+	switch outboxMessage.Status {
+	case "sent":
+		return fmt.Sprintf("Sent %s", outboxMessage.SentAt)
+	case "failed":
+		return "Failed"
+	}
+	if outboxMessage.Attempts > 0 {
+		return fmt.Sprintf("Retrying at %s", outboxMessage.NextAttemptAt)
+	}
+	return "Pending"
+}
+
+// alertEscalation returns the alert's escalation policy and how many of its steps have been sent.
+func alertEscalation(alert models.Alert, escalationPolicyNames map[int]string) string {
+	if alert.EscalationPolicyID == 0 {
+		return "None"
+	}
+	name, ok := escalationPolicyNames[alert.EscalationPolicyID]
+	if !ok {
+		name = "Deleted policy"
+	}
+	return fmt.Sprintf("%s (steps sent: %d)", name, alert.EscalationLevel)
+}
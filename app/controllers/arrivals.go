@@ -0,0 +1,313 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/models"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/views"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateTimeInputFormat is the format used by datetime-local form inputs
+const dateTimeInputFormat = "2006-01-02T15:04"
+
+// expectedArrivalStatuses are the display names of expected arrival statuses
+var expectedArrivalStatuses = map[string]string{"pending": "Pending", "arrived": "Arrived", "missed": "Missed"}
+
+func AdminExpectedArrivals(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Expected Arrivals", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	list := models.List{}
+	var listRowFields []models.ListRowField
+
+	// Get page number
+	pageNumber := getPageNumber(r)
+
+	// Get filter
+	filter := r.URL.Query().Get("filter")
+	var fields []models.WhereFields
+	switch filter {
+	case "pending", "arrived", "missed":
+		fields = append(fields, models.WhereFields{"status", "=", filter})
+	default:
+		filter = ""
+	}
+	listRowFields = append(listRowFields, filterLink("All", "/arrivals", filter == ""))
+	listRowFields = append(listRowFields, filterLink("Pending", "/arrivals?filter=pending", filter == "pending"))
+	listRowFields = append(listRowFields, filterLink("Arrived", "/arrivals?filter=arrived", filter == "arrived"))
+	listRowFields = append(listRowFields, filterLink("Missed", "/arrivals?filter=missed", filter == "missed"))
+	list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	listRowFields = []models.ListRowField{}
+
+	// Get expected arrivals
+	var expectedArrival models.ExpectedArrival
+	resExpectedArrivals, resCount, err := expectedArrival.Find(env, "AND", fields, getPerPage(env), pageNumber)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	if resCount > 0 {
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Number Plate"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Description"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Expected From"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Expected By"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Status"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "Actions"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: ""})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		for _, resExpectedArrival := range *resExpectedArrivals {
+			var listRowFields []models.ListRowField
+			listRowFields = append(listRowFields, models.ListRowField{Value: resExpectedArrival.Plate})
+			listRowFields = append(listRowFields, models.ListRowField{Value: resExpectedArrival.Description})
+			listRowFields = append(listRowFields, models.ListRowField{Value: resExpectedArrival.ExpectedFrom})
+			listRowFields = append(listRowFields, models.ListRowField{Value: resExpectedArrival.ExpectedBy})
+			listRowFields = append(listRowFields, models.ListRowField{Value: expectedArrivalStatuses[resExpectedArrival.Status]})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-red", Link: fmt.Sprintf("/arrivals/%v/delete", resExpectedArrival.ID), Confirm: "Are you sure you want to delete this expected arrival?", Icon: "delete", Value: "Delete"})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-yellow", Link: fmt.Sprintf("/arrivals/%v", resExpectedArrival.ID), Icon: "pencil", Value: "Edit"})
+			list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		}
+		// Get pagination
+		list.Pagination = getPagination(env, pageNumber, resCount)
+		if filter != "" {
+			list.Pagination.Query = template.URL("&filter=" + filter)
+		}
+	} else {
+		listRowFields = []models.ListRowField{}
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "No expected arrivals found"})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	}
+	listRowFields = []models.ListRowField{}
+	listRowFields = append(listRowFields, models.ListRowField{Type: "link", Class: "btn btn-icon btn-primary", Link: "/arrivals/add", Icon: "plus", Value: "Add"})
+	list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+
+	page.View = list
+
+	views.Render(w, env, "list", http.StatusOK, page)
+}
+
+func AdminAddExpectedArrival(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Add Expected Arrival", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	expectedArrival := models.ExpectedArrival{}
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Set values
+		setExpectedArrivalValues(&expectedArrival, r)
+		// Validate values
+		page.ErrorMessages = validateExpectedArrival(env, expectedArrival)
+
+		// Check for errors
+		if len(page.ErrorMessages) == 0 {
+			// Add expected arrival to database
+			_, err = expectedArrival.Add(env)
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "expectedarrival", fmt.Sprintf("Add expected arrival of %s by %s", expectedArrival.Plate, expectedArrival.ExpectedBy))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			// Redirect
+			http.Redirect(w, r, "/arrivals", 302)
+			return
+		}
+	}
+
+	page.View = expectedArrivalForm(expectedArrival)
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminEditExpectedArrival(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Edit Expected Arrival", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	// Parse GET parameters ready for use
+	vars := mux.Vars(r)
+
+	expectedArrivalID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	expectedArrival := models.ExpectedArrival{ID: expectedArrivalID}
+	resExpectedArrival, err := expectedArrival.Get(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "404", "Oops! Page Not Found", "404 Page Not Found")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	expectedArrival = *resExpectedArrival
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Set values
+		setExpectedArrivalValues(&expectedArrival, r)
+		// Validate values
+		page.ErrorMessages = validateExpectedArrival(env, expectedArrival)
+
+		// Check for errors
+		if len(page.ErrorMessages) == 0 {
+			// Update expected arrival in database
+			_, err = expectedArrival.Update(env)
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "expectedarrival", fmt.Sprintf("Update expected arrival id %d", expectedArrival.ID))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			// Redirect
+			http.Redirect(w, r, "/arrivals", 302)
+			return
+		}
+	}
+
+	page.View = expectedArrivalForm(expectedArrival)
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminDeleteExpectedArrival(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		expectedArrival := models.ExpectedArrival{}
+
+		// Parse GET parameters ready for use
+		vars := mux.Vars(r)
+
+		// Set values
+		expectedArrivalID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Delete expected arrival from database
+		expectedArrival.ID = expectedArrivalID
+		_, err = expectedArrival.Delete(env)
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Add admin log to database
+		err = adminLog(env, r, "expectedarrival", fmt.Sprintf("Delete expected arrival id %d", expectedArrival.ID))
+		if err != nil {
+			env.Logger.Println(err)
+		}
+	}
+
+	// Redirect
+	http.Redirect(w, r, "/arrivals", 302)
+}
+
+// setExpectedArrivalValues will accept an expected arrival and Request and will set the expected arrival's values from the posted form.
+// Saving an expected arrival sets it back to pending so it's checked again against the new times.
+func setExpectedArrivalValues(expectedArrival *models.ExpectedArrival, r *http.Request) {
+	expectedArrival.Plate = models.NormalisePlate(r.PostFormValue("plate"))
+	expectedArrival.Description = strings.TrimSpace(r.PostFormValue("description"))
+	expectedArrival.ExpectedFrom = parseDateTimeInput(r.PostFormValue("expectedfrom"))
+	expectedArrival.ExpectedBy = parseDateTimeInput(r.PostFormValue("expectedby"))
+	expectedArrival.Status = "pending"
+	expectedArrival.ArrivedDetectionID = 0
+}
+
+// validateExpectedArrival will accept an expected arrival and will return any validation error messages.
+func validateExpectedArrival(env *models.Env, expectedArrival models.ExpectedArrival) []string {
+	var errorMessages []string
+	err := env.Validator.Struct(expectedArrival)
+	if err != nil {
+		for _, e := range err.(validator.ValidationErrors) {
+			errorMessages = append(errorMessages, e.Translate(env.ValidatorTranslator))
+		}
+	}
+	if expectedArrival.ExpectedFrom != "" && expectedArrival.ExpectedFrom >= expectedArrival.ExpectedBy {
+		errorMessages = append(errorMessages, "Expected from must be before expected by")
+	}
+	return errorMessages
+}
+
+// expectedArrivalForm will accept an expected arrival and will return the form to add or edit it.
+func expectedArrivalForm(expectedArrival models.ExpectedArrival) models.Form {
+	form := models.Form{CancelLink: "/arrivals"}
+	form.Fields = append(form.Fields, models.FormField{Name: "plate", Title: "Number Plate *", Type: "text", Required: true, Placeholder: "Number Plate", Value: expectedArrival.Plate})
+	form.Fields = append(form.Fields, models.FormField{Name: "description", Title: "Description (e.g. delivery or shift start)", Type: "text", Placeholder: "Description", Value: expectedArrival.Description})
+	form.Fields = append(form.Fields, models.FormField{Name: "expectedfrom", Title: "Expected From (reads before this time don't count, defaults to when added)", Type: "datetime-local", Value: formatDateTimeInput(expectedArrival.ExpectedFrom)})
+	form.Fields = append(form.Fields, models.FormField{Name: "expectedby", Title: "Expected By *", Type: "datetime-local", Required: true, Value: formatDateTimeInput(expectedArrival.ExpectedBy)})
+	form.SubmitName = "Save Changes"
+	return form
+}
+
+// parseDateTimeInput will accept a datetime-local form value and will return it in the database date time format,
+// returning the value unchanged if it can't be parsed so validation reports it.
+func parseDateTimeInput(value string) string {
+	t, err := time.ParseInLocation(dateTimeInputFormat, value, time.Local)
+	if err != nil {
+		return value
+	}
+	return t.Format(models.DateTimeFormat)
+}
+
+// formatDateTimeInput will accept a database date time and will return it as a datetime-local form value.
+func formatDateTimeInput(value string) string {
+	t, err := time.ParseInLocation(models.DateTimeFormat, value, time.Local)
+	if err != nil {
+		return value
+	}
+	return t.Format(dateTimeInputFormat)
+}
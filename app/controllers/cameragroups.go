@@ -0,0 +1,347 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/models"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/views"
+	"net/http"
+	"strconv"
+)
+
+func AdminCameraGroups(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Camera Groups", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	list := models.List{}
+	var listRowFields []models.ListRowField
+
+	// Get page number
+	pageNumber := getPageNumber(r)
+
+	// Get all camera groups
+	var cameraGroup models.CameraGroup
+	resCameraGroups, resCount, err := cameraGroup.Find(env, "AND", []models.WhereFields{}, getPerPage(env), pageNumber)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	if resCount > 0 {
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Name"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "Actions"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: ""})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		for _, resCameraGroup := range *resCameraGroups {
+			var listRowFields []models.ListRowField
+			listRowFields = append(listRowFields, models.ListRowField{Value: resCameraGroup.Name})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-red", Link: fmt.Sprintf("/cameras/groups/%v/delete", resCameraGroup.ID), Confirm: "Are you sure you want to delete this camera group?", Icon: "delete", Value: "Delete"})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-yellow", Link: fmt.Sprintf("/cameras/groups/%v", resCameraGroup.ID), Icon: "pencil", Value: "Edit"})
+			list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		}
+		// Get pagination
+		list.Pagination = getPagination(env, pageNumber, resCount)
+	} else {
+		listRowFields = []models.ListRowField{}
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "No camera groups found"})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	}
+	listRowFields = []models.ListRowField{}
+	listRowFields = append(listRowFields, models.ListRowField{Type: "link", Class: "btn btn-icon btn-primary", Link: "/cameras/groups/add", Icon: "plus", Value: "Add"})
+	list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+
+	page.View = list
+
+	views.Render(w, env, "list", http.StatusOK, page)
+}
+
+func AdminAddCameraGroup(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Add Camera Group", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	cameraGroup := models.CameraGroup{}
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Set values
+		setCameraGroupValues(&cameraGroup, r)
+		// Validate values
+		page.ErrorMessages = validateCameraGroup(env, cameraGroup)
+
+		// Check for errors
+		if len(page.ErrorMessages) == 0 {
+			// Add camera group to database
+			_, err = cameraGroup.Add(env)
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "cameragroup", fmt.Sprintf("Add camera group %s", cameraGroup.Name))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			// Redirect
+			http.Redirect(w, r, "/cameras/groups", 302)
+			return
+		}
+	}
+
+	form, err := cameraGroupForm(env, cameraGroup)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	page.View = form
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminEditCameraGroup(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Edit Camera Group", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	// Parse GET parameters ready for use
+	vars := mux.Vars(r)
+
+	cameraGroupID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	cameraGroup := models.CameraGroup{ID: cameraGroupID}
+	resCameraGroup, err := cameraGroup.Get(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "404", "Oops! Page Not Found", "404 Page Not Found")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	cameraGroup = *resCameraGroup
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Set values
+		setCameraGroupValues(&cameraGroup, r)
+		// Validate values
+		page.ErrorMessages = validateCameraGroup(env, cameraGroup)
+
+		// Check for errors
+		if len(page.ErrorMessages) == 0 {
+			// Update camera group in database
+			_, err = cameraGroup.Update(env)
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "cameragroup", fmt.Sprintf("Update camera group id %d", cameraGroup.ID))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			// Redirect
+			http.Redirect(w, r, "/cameras/groups", 302)
+			return
+		}
+	}
+
+	form, err := cameraGroupForm(env, cameraGroup)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	page.View = form
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminDeleteCameraGroup(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		cameraGroup := models.CameraGroup{}
+
+		// Parse GET parameters ready for use
+		vars := mux.Vars(r)
+
+		// Set values
+		cameraGroupID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Delete camera group from database
+		cameraGroup.ID = cameraGroupID
+		_, err = cameraGroup.Delete(env)
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Add admin log to database
+		err = adminLog(env, r, "cameragroup", fmt.Sprintf("Delete camera group id %d", cameraGroup.ID))
+		if err != nil {
+			env.Logger.Println(err)
+		}
+	}
+
+	// Redirect
+	http.Redirect(w, r, "/cameras/groups", 302)
+}
+
+// setCameraGroupValues will accept a camera group and Request and will set the camera group's values from the posted form.
+func setCameraGroupValues(cameraGroup *models.CameraGroup, r *http.Request) {
+	cameraGroup.Name = r.PostFormValue("name")
+	cameraGroup.LoiterCount, _ = strconv.Atoi(r.PostFormValue("loitercount"))
+	cameraGroup.LoiterMinutes, _ = strconv.Atoi(r.PostFormValue("loiterminutes"))
+}
+
+// validateCameraGroup will accept a camera group and will return any validation error messages.
+func validateCameraGroup(env *models.Env, cameraGroup models.CameraGroup) []string {
+	var errorMessages []string
+	err := env.Validator.Struct(cameraGroup)
+	if err != nil {
+		for _, e := range err.(validator.ValidationErrors) {
+			errorMessages = append(errorMessages, e.Translate(env.ValidatorTranslator))
+		}
+	}
+	errorMessages = append(errorMessages, validateLoitering(cameraGroup.LoiterCount, cameraGroup.LoiterMinutes)...)
+	return errorMessages
+}
+
+// cameraGroupForm will accept a camera group and will return the form to add or edit it.
+func cameraGroupForm(env *models.Env, cameraGroup models.CameraGroup) (models.Form, error) {
+	form := models.Form{CancelLink: "/cameras/groups"}
+	form.Fields = append(form.Fields, models.FormField{Name: "name", Title: "Name *", Type: "text", Required: true, Placeholder: "Name", Value: cameraGroup.Name})
+	form.Fields = append(form.Fields, loiterFields(cameraGroup.LoiterCount, cameraGroup.LoiterMinutes, "by the cameras in this group")...)
+	form.SubmitName = "Save Changes"
+	return form, nil
+}
+
+// loiterFields will accept a loitering count and minutes and will return the form fields to edit them.
+func loiterFields(count, minutes int, where string) []models.FormField {
+	return []models.FormField{
+		{Name: "loitercount", Title: fmt.Sprintf("Loitering Alert When A Number Plate Is Read More Than (times %s, 0 to disable)", where), Type: "number", Placeholder: "0", Value: strconv.Itoa(count)},
+		{Name: "loiterminutes", Title: "Loitering Window (minutes)", Type: "number", Placeholder: "0", Value: strconv.Itoa(minutes)},
+	}
+}
+
+// validateLoitering will accept a loitering count and minutes and will return any validation error messages.
+func validateLoitering(count, minutes int) []string {
+	if (count > 0) != (minutes > 0) {
+		return []string{"Both the loitering count and window are required for loitering alerts"}
+	}
+	return nil
+}
+
+// cameraGroupOptions will return the camera groups as form field options with the camera group ID provided selected.
+func cameraGroupOptions(env *models.Env, cameraGroupID int) ([]models.FormFieldOption, error) {
+	options := []models.FormFieldOption{{Value: "0", Title: "None", Selected: cameraGroupID == 0}}
+	var cameraGroup models.CameraGroup
+	resCameraGroups, _, err := cameraGroup.Find(env, "AND", []models.WhereFields{}, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, resCameraGroup := range *resCameraGroups {
+		options = append(options, models.FormFieldOption{Value: strconv.Itoa(resCameraGroup.ID), Title: resCameraGroup.Name, Selected: resCameraGroup.ID == cameraGroupID})
+	}
+	return options, nil
+}
+
+// cameraScopeOptions will return the cameras and camera groups as form field options with those in the
+// camera scopes for the owner type and ID provided selected.
+func cameraScopeOptions(env *models.Env, ownerType string, ownerID int) ([]models.FormFieldOption, error) {
+	var options []models.FormFieldOption
+	selected := map[string]bool{}
+	if ownerID != 0 {
+		var cameraScope models.CameraScope
+		cameraScopes, err := cameraScope.Find(env, ownerType, ownerID)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range cameraScopes {
+			selected[s.Key()] = true
+		}
+	}
+	var cameraGroup models.CameraGroup
+	resCameraGroups, _, err := cameraGroup.Find(env, "AND", []models.WhereFields{}, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, resCameraGroup := range *resCameraGroups {
+		key := (&models.CameraScope{CameraGroupID: resCameraGroup.ID}).Key()
+		options = append(options, models.FormFieldOption{Value: key, Title: "Group: " + resCameraGroup.Name, Selected: selected[key]})
+	}
+	var camera models.Camera
+	resCameras, _, err := camera.Find(env, "AND", []models.WhereFields{}, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, resCamera := range *resCameras {
+		key := (&models.CameraScope{CameraID: resCamera.ID}).Key()
+		title := resCamera.Name
+		if title == "" {
+			title = resCamera.IPAddress
+		}
+		options = append(options, models.FormFieldOption{Value: key, Title: "Camera: " + title, Selected: selected[key]})
+	}
+	return options, nil
+}
+
+// saveCameraScopes will accept an owner type, owner ID and Request and will save the camera scopes selected
+// in the posted form.
+func saveCameraScopes(env *models.Env, ownerType string, ownerID int, r *http.Request) error {
+	var cameraScope models.CameraScope
+	return cameraScope.Set(env, ownerType, ownerID, models.ParseCameraScopes(r.PostForm["cameras"]))
+}
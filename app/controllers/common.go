@@ -63,13 +63,8 @@ func displayError(env *models.Env, w http.ResponseWriter, r *http.Request, code,
 // adminLog will accept an Environment, Request, logType and logDetails and will add
 // an admin log to the database with the current session user id against it.
 func adminLog(env *models.Env, r *http.Request, logType, logDetails string) error {
-	// Get session
-	session, err := env.SessionStore.Get(r, env.Config.SessionCookieName)
-	if err != nil {
-		return err
-	}
 	// Get user id
-	userID, err := strconv.Atoi(fmt.Sprint(session.Values["userid"]))
+	userID, err := getSessionUserID(env, r)
 	if err != nil {
 		return err
 	}
@@ -82,6 +77,16 @@ func adminLog(env *models.Env, r *http.Request, logType, logDetails string) erro
 	return nil
 }
 
+// getSessionUserID will accept a Request and will return the logged in user's ID.
+func getSessionUserID(env *models.Env, r *http.Request) (int, error) {
+	// Get session
+	session, err := env.SessionStore.Get(r, env.Config.SessionCookieName)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(fmt.Sprint(session.Values["userid"]))
+}
+
 // getTheme will accept a Request and will return use light/dark theme.
 func getTheme(r *http.Request) string {
 	theme, err := r.Cookie("theme")
@@ -110,6 +115,31 @@ func getPerPage(env *models.Env) int {
 	return perPage
 }
 
+// getPlateExpiryDays will return the number of days before a number plate expires to warn of it.
+func getPlateExpiryDays(env *models.Env) int {
+	expiryDays, _ := strconv.Atoi(fmt.Sprint(env.Config.PlateExpiryDays))
+	return expiryDays
+}
+
+// directionOptions will return the entry and exit directions as form field options with the direction provided
+// selected, using the title provided for no direction.
+func directionOptions(direction string, noneTitle string) []models.FormFieldOption {
+	return []models.FormFieldOption{
+		{Value: "", Title: noneTitle, Selected: direction == ""},
+		{Value: "entry", Title: "Entering", Selected: direction == "entry"},
+		{Value: "exit", Title: "Leaving", Selected: direction == "exit"},
+	}
+}
+
+// filterLink will accept a title, link and whether it is the current filter and will return a list filter link.
+func filterLink(title, link string, current bool) models.ListRowField {
+	field := models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn", Link: link, Value: title}
+	if current {
+		field.Class = "btn btn-primary"
+	}
+	return field
+}
+
 // getPagination will accept a pageNumber and resCount and will return a pagination.
 func getPagination(env *models.Env, pageNumber, resCount int) models.ListPagination {
 	// Get per page
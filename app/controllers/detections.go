@@ -0,0 +1,271 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/models"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/views"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func AdminDetections(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Detections", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	list := models.List{}
+	var listRowFields []models.ListRowField
+
+	// Get page number
+	pageNumber := getPageNumber(r)
+
+	// Get filter
+	filter := r.URL.Query().Get("filter")
+	var fields []models.WhereFields
+	switch filter {
+	case "lowconfidence":
+		fields = append(fields, models.WhereFields{"low_confidence", "=", 1}, models.WhereFields{"reviewed", "=", 0})
+		page.Title = "Low Confidence Detections To Review"
+	case "alerted":
+		fields = append(fields, models.WhereFields{"alerted", "=", 1})
+		page.Title = "Alerted Detections"
+	default:
+		filter = ""
+	}
+	listRowFields = append(listRowFields, filterLink("All", "/detections", filter == ""))
+	listRowFields = append(listRowFields, filterLink("Low Confidence", "/detections?filter=lowconfidence", filter == "lowconfidence"))
+	listRowFields = append(listRowFields, filterLink("Alerted", "/detections?filter=alerted", filter == "alerted"))
+	list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	listRowFields = []models.ListRowField{}
+
+	// Get camera names
+	cameraNames, err := getCameraNames(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	// Get all detections
+	var detection models.Detection
+	resDetections, resCount, err := detection.Find(env, "AND", fields, getPerPage(env), pageNumber)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	if resCount > 0 {
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Time"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Number Plate"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Vehicle"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Camera"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Direction"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Confidence"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Status"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Tags"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "Actions"})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		for _, resDetection := range *resDetections {
+			var listRowFields []models.ListRowField
+			listRowFields = append(listRowFields, models.ListRowField{Value: resDetection.EventTime})
+			listRowFields = append(listRowFields, models.ListRowField{Type: "link", Link: fmt.Sprintf("/detections/%v", resDetection.ID), Value: resDetection.Plate})
+			listRowFields = append(listRowFields, models.ListRowField{Value: resDetection.VehicleAttributes.String()})
+			listRowFields = append(listRowFields, models.ListRowField{Value: cameraNames[resDetection.CameraID]})
+			listRowFields = append(listRowFields, models.ListRowField{Value: detectionDirection(resDetection.Direction)})
+			listRowFields = append(listRowFields, models.ListRowField{Value: fmt.Sprintf("%d%%", resDetection.Confidence)})
+			listRowFields = append(listRowFields, detectionStatus(resDetection))
+			listRowFields = append(listRowFields, models.ListRowField{Value: strings.Join(resDetection.TagList(), ", ")})
+			if resDetection.LowConfidence && !resDetection.Reviewed {
+				listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-primary", Link: fmt.Sprintf("/detections/%v/review", resDetection.ID), Icon: "check", Value: "Mark Reviewed"})
+			}
+			list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		}
+		// Get pagination
+		list.Pagination = getPagination(env, pageNumber, resCount)
+		if filter != "" {
+			list.Pagination.Query = template.URL("&filter=" + filter)
+		}
+	} else {
+		listRowFields = []models.ListRowField{}
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "No detections found"})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	}
+
+	page.View = list
+
+	views.Render(w, env, "list", http.StatusOK, page)
+}
+
+func AdminDetection(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Detection", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	list := models.List{}
+
+	// Parse GET parameters ready for use
+	vars := mux.Vars(r)
+
+	detectionID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	detection := models.Detection{ID: detectionID}
+	resDetection, err := detection.Get(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "404", "Oops! Page Not Found", "404 Page Not Found")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	detection = *resDetection
+	page.Title = fmt.Sprintf("Detection of %s", detection.Plate)
+
+	// Get camera names
+	cameraNames, err := getCameraNames(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	// Get the registered number plate and its watchlist
+	registeredName, watchlistName := "", ""
+	if detection.NumberPlateID != 0 {
+		numberPlate := models.NumberPlate{ID: detection.NumberPlateID}
+		if resNumberPlate, err := numberPlate.Get(env); err == nil {
+			registeredName = resNumberPlate.Name
+			watchlist := models.Watchlist{ID: resNumberPlate.WatchlistID}
+			if resWatchlist, err := watchlist.Get(env); err == nil {
+				watchlistName = resWatchlist.Name
+			}
+		}
+	}
+
+	// Get the rules that matched
+	var ruleHit models.RuleHit
+	resRuleHits, _, err := ruleHit.Find(env, "AND", []models.WhereFields{{"detection_id", "=", detection.ID}}, 0, 1)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	details := []models.ListRowField{
+		{Value: "Time"}, {Value: detection.EventTime},
+		{Value: "Number Plate"}, {Value: detection.Plate},
+		{Value: "Registered To"}, {Value: registeredName},
+		{Value: "Watchlist"}, {Value: watchlistName},
+		{Value: "Camera"}, {Value: cameraNames[detection.CameraID]},
+		{Value: "Direction"}, {Value: detectionDirection(detection.Direction)},
+		{Value: "Confidence"}, {Value: fmt.Sprintf("%d%%", detection.Confidence)},
+		{Value: "Vehicle"}, {Value: detection.VehicleAttributes.String()},
+		{Value: "Status"}, detectionStatus(detection),
+		{Value: "Tags"}, {Value: strings.Join(detection.TagList(), ", ")},
+	}
+	for i := 0; i < len(details); i += 2 {
+		details[i].FieldClass = " field-width-auto field-padding-right"
+		list.Rows = append(list.Rows, models.ListRow{Fields: details[i : i+2]})
+	}
+	for _, resRuleHit := range *resRuleHits {
+		var listRowFields []models.ListRowField
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Link: fmt.Sprintf("/rules/%v/hits", resRuleHit.RuleID), Value: "Rule Matched"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: strings.ReplaceAll(resRuleHit.Explanation, "\n", ". ")})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	}
+	if detection.LowConfidence && !detection.Reviewed {
+		var listRowFields []models.ListRowField
+		listRowFields = append(listRowFields, models.ListRowField{Type: "link", Class: "btn btn-icon btn-primary", Link: fmt.Sprintf("/detections/%v/review", detection.ID), Icon: "check", Value: "Mark Reviewed"})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	}
+
+	page.View = list
+
+	views.Render(w, env, "list", http.StatusOK, page)
+}
+
+func AdminReviewDetection(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		detection := models.Detection{}
+
+		// Parse GET parameters ready for use
+		vars := mux.Vars(r)
+
+		// Set values
+		detectionID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Mark detection as reviewed in database
+		detection.ID = detectionID
+		_, err = detection.Review(env)
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Add admin log to database
+		err = adminLog(env, r, "detection", fmt.Sprintf("Review detection id %d", detection.ID))
+		if err != nil {
+			env.Logger.Println(err)
+		}
+	}
+
+	// Redirect
+	http.Redirect(w, r, "/detections?filter=lowconfidence", 302)
+}
+
+// detectionDirection will accept a detection's direction and will return it for display.
+func detectionDirection(direction string) string {
+	switch direction {
+	case "entry":
+		return "Entering"
+	case "exit":
+		return "Leaving"
+	}
+	return ""
+}
+
+// detectionStatus will accept a detection and will return a list field describing its status.
+func detectionStatus(detection models.Detection) models.ListRowField {
+	switch {
+	case detection.LowConfidence && !detection.Reviewed:
+		return models.ListRowField{FieldClass: " yellow", Value: "Low confidence"}
+	case detection.LowConfidence:
+		return models.ListRowField{Value: "Low confidence (reviewed)"}
+	case detection.Alerted:
+		return models.ListRowField{FieldClass: " red", Value: "Alerted"}
+	case detection.NumberPlateID != 0:
+		return models.ListRowField{Value: "Registered"}
+	}
+	return models.ListRowField{Value: "Unregistered"}
+}
@@ -0,0 +1,629 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/models"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/views"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func AdminEscalationPolicies(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Escalation Policies", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	list := models.List{}
+	var listRowFields []models.ListRowField
+
+	// Get page number
+	pageNumber := getPageNumber(r)
+
+	// Get all escalation policies
+	var escalationPolicy models.EscalationPolicy
+	resEscalationPolicies, resCount, err := escalationPolicy.Find(env, "AND", []models.WhereFields{}, getPerPage(env), pageNumber)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	if resCount > 0 {
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Name"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Steps"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "Actions"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: ""})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: ""})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		for _, resEscalationPolicy := range *resEscalationPolicies {
+			var listRowFields []models.ListRowField
+			steps, err := resEscalationPolicy.Steps(env)
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			var delays []string
+			for _, step := range steps {
+				delays = append(delays, fmt.Sprintf("%d min", step.DelayMinutes))
+			}
+			listRowFields = append(listRowFields, models.ListRowField{Value: resEscalationPolicy.Name})
+			listRowFields = append(listRowFields, models.ListRowField{Value: strings.Join(delays, ", ")})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-red", Link: fmt.Sprintf("/escalations/%v/delete", resEscalationPolicy.ID), Confirm: "Are you sure you want to delete this escalation policy?", Icon: "delete", Value: "Delete"})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon", Link: fmt.Sprintf("/escalations/%v/steps", resEscalationPolicy.ID), Icon: "stairs-up", Value: "Steps"})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-yellow", Link: fmt.Sprintf("/escalations/%v", resEscalationPolicy.ID), Icon: "pencil", Value: "Edit"})
+			list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		}
+		// Get pagination
+		list.Pagination = getPagination(env, pageNumber, resCount)
+	} else {
+		listRowFields = []models.ListRowField{}
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "No escalation policies found"})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	}
+	listRowFields = []models.ListRowField{}
+	listRowFields = append(listRowFields, models.ListRowField{Type: "link", Class: "btn btn-icon btn-primary", Link: "/escalations/add", Icon: "plus", Value: "Add"})
+	list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+
+	page.View = list
+
+	views.Render(w, env, "list", http.StatusOK, page)
+}
+
+func AdminAddEscalationPolicy(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Add Escalation Policy", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	escalationPolicy := models.EscalationPolicy{}
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Set values
+		escalationPolicy.Name = r.PostFormValue("name")
+		// Validate values
+		page.ErrorMessages = validateEscalation(env, escalationPolicy)
+
+		// Check for errors
+		if len(page.ErrorMessages) == 0 {
+			// Add escalation policy to database
+			_, err = escalationPolicy.Add(env)
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "escalation", fmt.Sprintf("Add escalation policy %s", escalationPolicy.Name))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			// Redirect to add the first step
+			http.Redirect(w, r, fmt.Sprintf("/escalations/%d/steps/add", escalationPolicy.ID), 302)
+			return
+		}
+	}
+
+	page.View = escalationPolicyForm(escalationPolicy)
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminEditEscalationPolicy(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	escalationPolicy, ok := getEscalationPolicy(env, w, r)
+	if !ok {
+		return
+	}
+	var page = models.Page{Title: "Edit Escalation Policy", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Set values
+		escalationPolicy.Name = r.PostFormValue("name")
+		// Validate values
+		page.ErrorMessages = validateEscalation(env, escalationPolicy)
+
+		// Check for errors
+		if len(page.ErrorMessages) == 0 {
+			// Update escalation policy in database
+			_, err = escalationPolicy.Update(env)
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "escalation", fmt.Sprintf("Update escalation policy id %d", escalationPolicy.ID))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			// Redirect
+			http.Redirect(w, r, "/escalations", 302)
+			return
+		}
+	}
+
+	page.View = escalationPolicyForm(*escalationPolicy)
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminDeleteEscalationPolicy(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		escalationPolicy := models.EscalationPolicy{}
+
+		// Parse GET parameters ready for use
+		vars := mux.Vars(r)
+
+		// Set values
+		escalationPolicyID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Delete escalation policy from database
+		escalationPolicy.ID = escalationPolicyID
+		_, err = escalationPolicy.Delete(env)
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Add admin log to database
+		err = adminLog(env, r, "escalation", fmt.Sprintf("Delete escalation policy id %d", escalationPolicy.ID))
+		if err != nil {
+			env.Logger.Println(err)
+		}
+	}
+
+	// Redirect
+	http.Redirect(w, r, "/escalations", 302)
+}
+
+func AdminEscalationSteps(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	escalationPolicy, ok := getEscalationPolicy(env, w, r)
+	if !ok {
+		return
+	}
+	var page = models.Page{Title: fmt.Sprintf("Escalation Steps for %s", escalationPolicy.Name), RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	list := models.List{}
+	var listRowFields []models.ListRowField
+
+	// Get the escalation policy's steps
+	steps, err := escalationPolicy.Steps(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	// Get user emails and notification channel names
+	userEmails, err := getUserEmails(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	channelNames, err := getNotificationChannelNames(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	if len(steps) > 0 {
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Not Acknowledged After"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Send To"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "Actions"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: ""})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		for _, step := range steps {
+			var listRowFields []models.ListRowField
+			var sendTo []string
+			for _, userID := range step.UserIDs {
+				if email, ok := userEmails[userID]; ok {
+					sendTo = append(sendTo, email)
+				}
+			}
+			var notificationTarget models.NotificationTarget
+			channelIDs, err := notificationTarget.Find(env, "escalationstep", step.ID)
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			for _, channelID := range channelIDs {
+				sendTo = append(sendTo, channelNames[channelID])
+			}
+			listRowFields = append(listRowFields, models.ListRowField{Value: fmt.Sprintf("%d min", step.DelayMinutes)})
+			listRowFields = append(listRowFields, models.ListRowField{Value: strings.Join(sendTo, ", ")})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-red", Link: fmt.Sprintf("/escalations/%v/steps/%v/delete", escalationPolicy.ID, step.ID), Confirm: "Are you sure you want to delete this escalation step?", Icon: "delete", Value: "Delete"})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-yellow", Link: fmt.Sprintf("/escalations/%v/steps/%v", escalationPolicy.ID, step.ID), Icon: "pencil", Value: "Edit"})
+			list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		}
+	} else {
+		listRowFields = []models.ListRowField{}
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "No escalation steps found"})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	}
+	listRowFields = []models.ListRowField{}
+	listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-primary", Link: fmt.Sprintf("/escalations/%v/steps/add", escalationPolicy.ID), Icon: "plus", Value: "Add"})
+	listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn", Link: "/escalations", Value: "Back to Escalation Policies"})
+	list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+
+	page.View = list
+
+	views.Render(w, env, "list", http.StatusOK, page)
+}
+
+func AdminAddEscalationStep(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	escalationPolicy, ok := getEscalationPolicy(env, w, r)
+	if !ok {
+		return
+	}
+	var page = models.Page{Title: "Add Escalation Step", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	step := models.EscalationStep{EscalationPolicyID: escalationPolicy.ID}
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Set values
+		setEscalationStepValues(&step, r)
+		// Validate values
+		page.ErrorMessages = validateEscalationStep(env, step, r)
+
+		// Check for errors
+		if len(page.ErrorMessages) == 0 {
+			// Add escalation step to database
+			_, err = step.Add(env)
+			if err == nil {
+				// Save notification channels
+				err = saveNotificationTargets(env, "escalationstep", step.ID, r)
+			}
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "escalation", fmt.Sprintf("Add escalation step after %d minutes to escalation policy id %d", step.DelayMinutes, escalationPolicy.ID))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			// Redirect
+			http.Redirect(w, r, fmt.Sprintf("/escalations/%d/steps", escalationPolicy.ID), 302)
+			return
+		}
+	}
+
+	form, err := escalationStepForm(env, step)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	page.View = form
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminEditEscalationStep(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	escalationPolicy, ok := getEscalationPolicy(env, w, r)
+	if !ok {
+		return
+	}
+	var page = models.Page{Title: "Edit Escalation Step", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	// Parse GET parameters ready for use
+	vars := mux.Vars(r)
+
+	stepID, err := strconv.Atoi(fmt.Sprint(vars["stepid"]))
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	step := models.EscalationStep{ID: stepID}
+	resStep, err := step.Get(env)
+	if err != nil || resStep.EscalationPolicyID != escalationPolicy.ID {
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		err := displayError(env, w, r, "404", "Oops! Page Not Found", "404 Page Not Found")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	step = *resStep
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Set values
+		setEscalationStepValues(&step, r)
+		// Validate values
+		page.ErrorMessages = validateEscalationStep(env, step, r)
+
+		// Check for errors
+		if len(page.ErrorMessages) == 0 {
+			// Update escalation step in database
+			_, err = step.Update(env)
+			if err == nil {
+				// Save notification channels
+				err = saveNotificationTargets(env, "escalationstep", step.ID, r)
+			}
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "escalation", fmt.Sprintf("Update escalation step id %d", step.ID))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			// Redirect
+			http.Redirect(w, r, fmt.Sprintf("/escalations/%d/steps", escalationPolicy.ID), 302)
+			return
+		}
+	}
+
+	form, err := escalationStepForm(env, step)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	page.View = form
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminDeleteEscalationStep(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	// Parse GET parameters ready for use
+	vars := mux.Vars(r)
+
+	escalationPolicyID, _ := strconv.Atoi(fmt.Sprint(vars["id"]))
+
+	if r.Method == http.MethodGet {
+		// Set values
+		stepID, err := strconv.Atoi(fmt.Sprint(vars["stepid"]))
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Delete escalation step from database if it belongs to the escalation policy
+		step := models.EscalationStep{ID: stepID}
+		resStep, err := step.Get(env)
+		if err == nil && resStep.EscalationPolicyID == escalationPolicyID {
+			_, err = resStep.Delete(env)
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+
+			// Add admin log to database
+			err = adminLog(env, r, "escalation", fmt.Sprintf("Delete escalation step id %d", step.ID))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+		}
+	}
+
+	// Redirect
+	http.Redirect(w, r, fmt.Sprintf("/escalations/%d/steps", escalationPolicyID), 302)
+}
+
+// getEscalationPolicy will get the escalation policy from the Request, displaying an error and returning
+// false if it doesn't exist.
+func getEscalationPolicy(env *models.Env, w http.ResponseWriter, r *http.Request) (*models.EscalationPolicy, bool) {
+	// Parse GET parameters ready for use
+	vars := mux.Vars(r)
+
+	escalationPolicyID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+	if err == nil {
+		escalationPolicy := models.EscalationPolicy{ID: escalationPolicyID}
+		resEscalationPolicy, err := escalationPolicy.Get(env)
+		if err == nil {
+			return resEscalationPolicy, true
+		}
+	}
+	env.Logger.Println(err)
+	err = displayError(env, w, r, "404", "Oops! Page Not Found", "404 Page Not Found")
+	if err != nil {
+		env.Logger.Println(err)
+	}
+	return nil, false
+}
+
+// validateEscalation will accept an escalation policy or step and will return any validation error messages.
+func validateEscalation(env *models.Env, escalation interface{}) []string {
+	var errorMessages []string
+	err := env.Validator.Struct(escalation)
+	if err != nil {
+		for _, e := range err.(validator.ValidationErrors) {
+			errorMessages = append(errorMessages, e.Translate(env.ValidatorTranslator))
+		}
+	}
+	return errorMessages
+}
+
+// escalationPolicyForm will accept an escalation policy and will return the form to add or edit it.
+func escalationPolicyForm(escalationPolicy models.EscalationPolicy) models.Form {
+	form := models.Form{CancelLink: "/escalations"}
+	form.Fields = append(form.Fields, models.FormField{Name: "name", Title: "Name *", Type: "text", Required: true, Placeholder: "Name", Value: escalationPolicy.Name})
+	form.SubmitName = "Save Changes"
+	return form
+}
+
+// setEscalationStepValues will accept an escalation step and Request and will set the step's values from the posted form.
+func setEscalationStepValues(step *models.EscalationStep, r *http.Request) {
+	step.DelayMinutes, _ = strconv.Atoi(r.PostFormValue("delayminutes"))
+	step.UserIDs = nil
+	for _, value := range r.PostForm["users"] {
+		if userID, err := strconv.Atoi(value); err == nil && userID > 0 {
+			step.UserIDs = append(step.UserIDs, userID)
+		}
+	}
+}
+
+// validateEscalationStep will accept an escalation step and Request and will return any validation error messages.
+func validateEscalationStep(env *models.Env, step models.EscalationStep, r *http.Request) []string {
+	errorMessages := validateEscalation(env, step)
+	if len(step.UserIDs) == 0 && len(models.ParseChannelIDs(r.PostForm["channels"])) == 0 {
+		errorMessages = append(errorMessages, "Choose at least one user or notification channel to escalate alerts to")
+	}
+	return errorMessages
+}
+
+// escalationStepForm will accept an escalation step and will return the form to add or edit it.
+func escalationStepForm(env *models.Env, step models.EscalationStep) (models.Form, error) {
+	form := models.Form{CancelLink: fmt.Sprintf("/escalations/%d/steps", step.EscalationPolicyID)}
+	userOpts, err := userOptions(env, step.UserIDs)
+	if err != nil {
+		return form, err
+	}
+	channelOpts, err := notificationChannelOptions(env, "escalationstep", step.ID)
+	if err != nil {
+		return form, err
+	}
+	delay := ""
+	if step.DelayMinutes > 0 {
+		delay = strconv.Itoa(step.DelayMinutes)
+	}
+	form.Fields = append(form.Fields, models.FormField{Name: "delayminutes", Title: "When not acknowledged this many minutes after the alert *", Type: "number", Required: true, Placeholder: "15", Value: delay})
+	form.Fields = append(form.Fields, models.FormField{Name: "users", Title: "Send to users' email addresses", Type: "select", Multiple: true, Options: userOpts})
+	form.Fields = append(form.Fields, models.FormField{Name: "channels", Title: "Send to notification channels", Type: "select", Multiple: true, Options: channelOpts})
+	form.SubmitName = "Save Changes"
+	return form, nil
+}
+
+// escalationPolicyOptions will return the escalation policies as form field options with the escalation
+// policy ID provided selected.
+func escalationPolicyOptions(env *models.Env, escalationPolicyID int) ([]models.FormFieldOption, error) {
+	options := []models.FormFieldOption{{Value: "0", Title: "None", Selected: escalationPolicyID == 0}}
+	var escalationPolicy models.EscalationPolicy
+	resEscalationPolicies, _, err := escalationPolicy.Find(env, "AND", []models.WhereFields{}, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, resEscalationPolicy := range *resEscalationPolicies {
+		options = append(options, models.FormFieldOption{Value: strconv.Itoa(resEscalationPolicy.ID), Title: resEscalationPolicy.Name, Selected: resEscalationPolicy.ID == escalationPolicyID})
+	}
+	return options, nil
+}
+
+// getEscalationPolicyNames will return a map of escalation policy names by ID.
+func getEscalationPolicyNames(env *models.Env) (map[int]string, error) {
+	names := map[int]string{0: "None"}
+	var escalationPolicy models.EscalationPolicy
+	resEscalationPolicies, _, err := escalationPolicy.Find(env, "AND", []models.WhereFields{}, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, resEscalationPolicy := range *resEscalationPolicies {
+		names[resEscalationPolicy.ID] = resEscalationPolicy.Name
+	}
+	return names, nil
+}
+
+// userOptions will return the users as form field options with the user IDs provided selected.
+func userOptions(env *models.Env, userIDs []int) ([]models.FormFieldOption, error) {
+	var options []models.FormFieldOption
+	selected := map[int]bool{}
+	for _, userID := range userIDs {
+		selected[userID] = true
+	}
+	var user models.User
+	resUsers, _, err := user.Find(env, "AND", []models.WhereFields{}, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, resUser := range *resUsers {
+		options = append(options, models.FormFieldOption{Value: strconv.Itoa(resUser.ID), Title: resUser.Email, Selected: selected[resUser.ID]})
+	}
+	return options, nil
+}
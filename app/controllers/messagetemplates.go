@@ -0,0 +1,325 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/models"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/views"
+	"net/http"
+	"strconv"
+)
+
+func AdminMessageTemplates(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Message Templates", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	list := models.List{}
+	var listRowFields []models.ListRowField
+
+	// Get page number
+	pageNumber := getPageNumber(r)
+
+	// Get all message templates
+	var messageTemplate models.MessageTemplate
+	resMessageTemplates, resCount, err := messageTemplate.Find(env, "AND", []models.WhereFields{}, getPerPage(env), pageNumber)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	if resCount > 0 {
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Name"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Format"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "Actions"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: ""})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		for _, resMessageTemplate := range *resMessageTemplates {
+			var listRowFields []models.ListRowField
+			listRowFields = append(listRowFields, models.ListRowField{Value: resMessageTemplate.Name})
+			listRowFields = append(listRowFields, models.ListRowField{Value: models.MessageTemplateFormats[resMessageTemplate.Format]})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-red", Link: fmt.Sprintf("/templates/%v/delete", resMessageTemplate.ID), Confirm: "Are you sure you want to delete this message template? Channels and watchlists using it will go back to the default message.", Icon: "delete", Value: "Delete"})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-yellow", Link: fmt.Sprintf("/templates/%v", resMessageTemplate.ID), Icon: "pencil", Value: "Edit"})
+			list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		}
+		// Get pagination
+		list.Pagination = getPagination(env, pageNumber, resCount)
+	} else {
+		listRowFields = []models.ListRowField{}
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "No message templates found"})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	}
+	listRowFields = []models.ListRowField{}
+	listRowFields = append(listRowFields, models.ListRowField{Type: "link", Class: "btn btn-icon btn-primary", Link: "/templates/add", Icon: "plus", Value: "Add"})
+	list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+
+	page.View = list
+
+	views.Render(w, env, "list", http.StatusOK, page)
+}
+
+func AdminAddMessageTemplate(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Add Message Template", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	messageTemplate := models.MessageTemplate{Format: "text", Body: defaultMessageTemplateBody}
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Set values
+		setMessageTemplateValues(&messageTemplate, r)
+		// Validate values
+		page.ErrorMessages = validateMessageTemplate(env, messageTemplate)
+
+		// Check for errors
+		if len(page.ErrorMessages) == 0 {
+			// Add message template to database
+			_, err = messageTemplate.Add(env)
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "messagetemplate", fmt.Sprintf("Add message template %s", messageTemplate.Name))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			// Redirect
+			http.Redirect(w, r, "/templates", 302)
+			return
+		}
+	}
+
+	page.View = messageTemplateForm(messageTemplate)
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminEditMessageTemplate(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Edit Message Template", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	// Parse GET parameters ready for use
+	vars := mux.Vars(r)
+
+	messageTemplateID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	messageTemplate := models.MessageTemplate{ID: messageTemplateID}
+	resMessageTemplate, err := messageTemplate.Get(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "404", "Oops! Page Not Found", "404 Page Not Found")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	messageTemplate = *resMessageTemplate
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Set values
+		setMessageTemplateValues(&messageTemplate, r)
+		// Validate values
+		page.ErrorMessages = validateMessageTemplate(env, messageTemplate)
+
+		// Check for errors
+		if len(page.ErrorMessages) == 0 {
+			// Update message template in database
+			_, err = messageTemplate.Update(env)
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "messagetemplate", fmt.Sprintf("Update message template id %d", messageTemplate.ID))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			// Redirect
+			http.Redirect(w, r, "/templates", 302)
+			return
+		}
+	}
+
+	page.View = messageTemplateForm(messageTemplate)
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminDeleteMessageTemplate(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		messageTemplate := models.MessageTemplate{}
+
+		// Parse GET parameters ready for use
+		vars := mux.Vars(r)
+
+		// Set values
+		messageTemplateID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Delete message template from database
+		messageTemplate.ID = messageTemplateID
+		_, err = messageTemplate.Delete(env)
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Add admin log to database
+		err = adminLog(env, r, "messagetemplate", fmt.Sprintf("Delete message template id %d", messageTemplate.ID))
+		if err != nil {
+			env.Logger.Println(err)
+		}
+	}
+
+	// Redirect
+	http.Redirect(w, r, "/templates", 302)
+}
+
+// messageTemplatePreview is the JSON response of a message template preview
+type messageTemplatePreview struct {
+	Format string `json:"format"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	Error  string `json:"error"`
+}
+
+// AdminPreviewMessageTemplate renders the posted message template against a sample alert and returns it as JSON
+func AdminPreviewMessageTemplate(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// Parse form data ready for use
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	messageTemplate := models.MessageTemplate{}
+	setMessageTemplateValues(&messageTemplate, r)
+	preview := messageTemplatePreview{Format: messageTemplate.Format}
+	if err := messageTemplate.Check(); err != nil {
+		preview.Error = err.Error()
+	} else {
+		notification := models.SampleNotification(env)
+		preview.Title, preview.Body, err = messageTemplate.Render(notification)
+		if err != nil {
+			preview.Error = err.Error()
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(preview)
+	if err != nil {
+		env.Logger.Println(err)
+	}
+}
+
+// defaultMessageTemplateBody is the body new message templates start with, matching the default message
+const defaultMessageTemplateBody = `{{.Message}}
+{{range .Fields}}{{.Name}}: {{.Value}}
+{{end}}{{.Link}}`
+
+// setMessageTemplateValues will accept a message template and Request and will set the message template's values from the posted form.
+func setMessageTemplateValues(messageTemplate *models.MessageTemplate, r *http.Request) {
+	messageTemplate.Name = r.PostFormValue("name")
+	messageTemplate.Format = r.PostFormValue("format")
+	messageTemplate.Title = r.PostFormValue("title")
+	messageTemplate.Body = r.PostFormValue("body")
+}
+
+// validateMessageTemplate will accept a message template and will return any validation error messages.
+func validateMessageTemplate(env *models.Env, messageTemplate models.MessageTemplate) []string {
+	var errorMessages []string
+	err := env.Validator.Struct(messageTemplate)
+	if err != nil {
+		for _, e := range err.(validator.ValidationErrors) {
+			errorMessages = append(errorMessages, e.Translate(env.ValidatorTranslator))
+		}
+	}
+	if err := messageTemplate.Check(); err != nil {
+		errorMessages = append(errorMessages, err.Error())
+	} else if _, _, err := messageTemplate.Render(models.SampleNotification(env)); err != nil {
+		errorMessages = append(errorMessages, fmt.Sprintf("Template can't be rendered: %s", err))
+	}
+	return errorMessages
+}
+
+// messageTemplateForm will accept a message template and will return the form to add or edit it, with a live preview.
+func messageTemplateForm(messageTemplate models.MessageTemplate) models.Form {
+	form := models.Form{CancelLink: "/templates", Preview: "/templates/preview"}
+	var formatOpts []models.FormFieldOption
+	for _, format := range []string{"text", "html"} {
+		formatOpts = append(formatOpts, models.FormFieldOption{Value: format, Title: models.MessageTemplateFormats[format], Selected: messageTemplate.Format == format})
+	}
+	form.Fields = append(form.Fields, models.FormField{Name: "name", Title: "Name *", Type: "text", Required: true, Placeholder: "Name", Value: messageTemplate.Name})
+	form.Fields = append(form.Fields, models.FormField{Name: "format", Title: "Format (text for SMS and chat apps, which may use markdown, or HTML for emails)", Type: "select", Options: formatOpts})
+	form.Fields = append(form.Fields, models.FormField{Name: "title", Title: "Title (email subject or message title, blank uses the alert's title, e.g. Alert: {{.Plate}})", Type: "text", Placeholder: "{{.Title}}", Value: messageTemplate.Title})
+	form.Fields = append(form.Fields, models.FormField{Name: "body", Title: "Body * (Go template using .Title, .Message, .Plate, .Link, .Fields and .Field, e.g. {{index .Field \"Camera\"}})", Type: "textarea", Class: "code", Required: true, Value: messageTemplate.Body})
+	form.SubmitName = "Save Changes"
+	return form
+}
+
+// messageTemplateOptions will return the message templates as form field options with the message
+// template ID provided selected, and the title provided for not using a template.
+func messageTemplateOptions(env *models.Env, messageTemplateID int, noneTitle string) ([]models.FormFieldOption, error) {
+	options := []models.FormFieldOption{{Value: "0", Title: noneTitle, Selected: messageTemplateID == 0}}
+	var messageTemplate models.MessageTemplate
+	resMessageTemplates, _, err := messageTemplate.Find(env, "AND", []models.WhereFields{}, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, resMessageTemplate := range *resMessageTemplates {
+		options = append(options, models.FormFieldOption{Value: strconv.Itoa(resMessageTemplate.ID), Title: resMessageTemplate.Name, Selected: resMessageTemplate.ID == messageTemplateID})
+	}
+	return options, nil
+}
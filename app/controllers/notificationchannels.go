@@ -0,0 +1,436 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/models"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/views"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func AdminNotificationChannels(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Notification Channels", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	list := models.List{}
+	var listRowFields []models.ListRowField
+
+	// Get page number
+	pageNumber := getPageNumber(r)
+
+	// Get all notification channels
+	var notificationChannel models.NotificationChannel
+	resNotificationChannels, resCount, err := notificationChannel.Find(env, "AND", []models.WhereFields{}, getPerPage(env), pageNumber)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	if resCount > 0 {
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Name"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Service"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Enabled"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "Actions"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: ""})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: ""})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		for _, resNotificationChannel := range *resNotificationChannels {
+			var listRowFields []models.ListRowField
+			enabled := "No"
+			if resNotificationChannel.Enabled {
+				enabled = "Yes"
+			}
+			// Only show the service so tokens in the URL aren't displayed
+			service, _, _ := strings.Cut(resNotificationChannel.URL, "://")
+			listRowFields = append(listRowFields, models.ListRowField{Value: resNotificationChannel.Name})
+			listRowFields = append(listRowFields, models.ListRowField{Value: service})
+			listRowFields = append(listRowFields, models.ListRowField{Value: enabled})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-primary", Link: fmt.Sprintf("/channels/%v/test", resNotificationChannel.ID), Icon: "send", Value: "Test"})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-red", Link: fmt.Sprintf("/channels/%v/delete", resNotificationChannel.ID), Confirm: "Are you sure you want to delete this notification channel?", Icon: "delete", Value: "Delete"})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-yellow", Link: fmt.Sprintf("/channels/%v", resNotificationChannel.ID), Icon: "pencil", Value: "Edit"})
+			list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		}
+		// Get pagination
+		list.Pagination = getPagination(env, pageNumber, resCount)
+	} else {
+		listRowFields = []models.ListRowField{}
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "No notification channels found"})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	}
+	listRowFields = []models.ListRowField{}
+	listRowFields = append(listRowFields, models.ListRowField{Type: "link", Class: "btn btn-icon btn-primary", Link: "/channels/add", Icon: "plus", Value: "Add"})
+	listRowFields = append(listRowFields, models.ListRowField{Type: "link", Class: "btn btn-icon btn-primary", Link: "/channels/email/test", Icon: "email-fast", Value: "Test Email"})
+	list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+
+	page.View = list
+
+	views.Render(w, env, "list", http.StatusOK, page)
+}
+
+func AdminAddNotificationChannel(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Add Notification Channel", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	notificationChannel := models.NotificationChannel{Enabled: true}
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Set values
+		setNotificationChannelValues(&notificationChannel, r)
+		// Validate values
+		page.ErrorMessages = validateNotificationChannel(env, notificationChannel)
+
+		// Check for errors
+		if len(page.ErrorMessages) == 0 {
+			// Add notification channel to database
+			_, err = notificationChannel.Add(env)
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "notificationchannel", fmt.Sprintf("Add notification channel %s", notificationChannel.Name))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			// Redirect
+			http.Redirect(w, r, "/channels", 302)
+			return
+		}
+	}
+
+	form, err := notificationChannelForm(env, notificationChannel)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	page.View = form
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminEditNotificationChannel(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Edit Notification Channel", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	// Parse GET parameters ready for use
+	vars := mux.Vars(r)
+
+	notificationChannelID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	notificationChannel := models.NotificationChannel{ID: notificationChannelID}
+	resNotificationChannel, err := notificationChannel.Get(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "404", "Oops! Page Not Found", "404 Page Not Found")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	notificationChannel = *resNotificationChannel
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Set values
+		setNotificationChannelValues(&notificationChannel, r)
+		// Validate values
+		page.ErrorMessages = validateNotificationChannel(env, notificationChannel)
+
+		// Check for errors
+		if len(page.ErrorMessages) == 0 {
+			// Update notification channel in database
+			_, err = notificationChannel.Update(env)
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "notificationchannel", fmt.Sprintf("Update notification channel id %d", notificationChannel.ID))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			// Redirect
+			http.Redirect(w, r, "/channels", 302)
+			return
+		}
+	}
+
+	form, err := notificationChannelForm(env, notificationChannel)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	page.View = form
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminDeleteNotificationChannel(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		notificationChannel := models.NotificationChannel{}
+
+		// Parse GET parameters ready for use
+		vars := mux.Vars(r)
+
+		// Set values
+		notificationChannelID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Delete notification channel from database
+		notificationChannel.ID = notificationChannelID
+		_, err = notificationChannel.Delete(env)
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Add admin log to database
+		err = adminLog(env, r, "notificationchannel", fmt.Sprintf("Delete notification channel id %d", notificationChannel.ID))
+		if err != nil {
+			env.Logger.Println(err)
+		}
+	}
+
+	// Redirect
+	http.Redirect(w, r, "/channels", 302)
+}
+
+func AdminTestNotificationChannel(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Test Notification Channel", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	// Parse GET parameters ready for use
+	vars := mux.Vars(r)
+
+	notificationChannelID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	notificationChannel := models.NotificationChannel{ID: notificationChannelID}
+	resNotificationChannel, err := notificationChannel.Get(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "404", "Oops! Page Not Found", "404 Page Not Found")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	notificationChannel = *resNotificationChannel
+	page.Title = fmt.Sprintf("Test Notification Channel %s", notificationChannel.Name)
+
+	if r.Method == http.MethodPost {
+		// Send a sample alert straight to the channel so any error can be shown
+		err := notificationChannel.SendTest(env)
+		if err != nil {
+			page.ErrorMessages = append(page.ErrorMessages, fmt.Sprintf("Test alert failed: %s", err))
+		} else {
+			page.OkMessage = fmt.Sprintf("Test alert sent to %s.", notificationChannel.Name)
+		}
+		// Add admin log to database
+		err = adminLog(env, r, "notificationchannel", fmt.Sprintf("Send test alert to notification channel id %d", notificationChannel.ID))
+		if err != nil {
+			env.Logger.Println(err)
+		}
+	}
+
+	page.View = models.Form{CancelLink: "/channels", SubmitName: "Send Test Alert"}
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminTestEmail(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Test Email", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	// Default to the logged in user's email address
+	to := ""
+	userID, err := getSessionUserID(env, r)
+	if err == nil {
+		user := models.User{ID: userID}
+		resUser, err := user.Get(env)
+		if err == nil {
+			to = resUser.Email
+		}
+	}
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		to = strings.TrimSpace(r.PostFormValue("to"))
+		if err := env.Validator.Var(to, "required,email"); err != nil {
+			page.ErrorMessages = append(page.ErrorMessages, "Enter a valid email address")
+		} else {
+			// Send a sample alert straight away so any error from the SMTP server can be shown
+			notification := models.TestNotification(env)
+			email := notification.Email(to)
+			err := email.Send(env)
+			if err != nil {
+				page.ErrorMessages = append(page.ErrorMessages, fmt.Sprintf("Test email failed: %s", err))
+			} else {
+				page.OkMessage = fmt.Sprintf("Test email sent to %s.", to)
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "notificationchannel", fmt.Sprintf("Send test email to %s", to))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+		}
+	}
+
+	form := models.Form{CancelLink: "/channels", SubmitName: "Send Test Email"}
+	smtpServer := "not configured"
+	if env.Config.SMTPHost != "" {
+		smtpServer = fmt.Sprintf("%s:%s", env.Config.SMTPHost, env.Config.SMTPPort)
+	}
+	form.Fields = append(form.Fields, models.FormField{Name: "to", Title: fmt.Sprintf("Email Address * (sent using the SMTP server in the config, %s)", smtpServer), Type: "email", Required: true, Placeholder: "Email Address", Value: to})
+	page.View = form
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+// setNotificationChannelValues will accept a notification channel and Request and will set the notification channel's values from the posted form.
+func setNotificationChannelValues(notificationChannel *models.NotificationChannel, r *http.Request) {
+	notificationChannel.Name = r.PostFormValue("name")
+	notificationChannel.URL = strings.TrimSpace(r.PostFormValue("url"))
+	notificationChannel.Enabled = r.PostFormValue("enabled") == "1"
+	notificationChannel.TemplateID, _ = strconv.Atoi(r.PostFormValue("templateid"))
+	notificationChannel.CooldownMinutes, _ = strconv.Atoi(r.PostFormValue("cooldownminutes"))
+}
+
+// validateNotificationChannel will accept a notification channel and will return any validation error messages.
+func validateNotificationChannel(env *models.Env, notificationChannel models.NotificationChannel) []string {
+	var errorMessages []string
+	err := env.Validator.Struct(notificationChannel)
+	if err != nil {
+		for _, e := range err.(validator.ValidationErrors) {
+			errorMessages = append(errorMessages, e.Translate(env.ValidatorTranslator))
+		}
+	}
+	if notificationChannel.URL != "" {
+		if err := notificationChannel.Check(); err != nil {
+			errorMessages = append(errorMessages, fmt.Sprintf("Invalid notification URL: %s", err))
+		}
+	}
+	return errorMessages
+}
+
+// notificationChannelForm will accept a notification channel and will return the form to add or edit it.
+func notificationChannelForm(env *models.Env, notificationChannel models.NotificationChannel) (models.Form, error) {
+	form := models.Form{CancelLink: "/channels"}
+	messageTemplateOpts, err := messageTemplateOptions(env, notificationChannel.TemplateID, "Watchlist's template or default")
+	if err != nil {
+		return form, err
+	}
+	form.Fields = append(form.Fields, models.FormField{Name: "name", Title: "Name *", Type: "text", Required: true, Placeholder: "Name", Value: notificationChannel.Name})
+	form.Fields = append(form.Fields, models.FormField{Name: "url", Title: "Shoutrrr URL * (e.g. telegram://token@telegram?chats=@channel, see containrrr.dev/shoutrrr/services/overview)", Type: "text", Required: true, Placeholder: "slack://token-a/token-b/token-c", Value: notificationChannel.URL})
+	form.Fields = append(form.Fields, models.FormField{Name: "templateid", Title: "Message Template", Type: "select", Options: messageTemplateOpts})
+	form.Fields = append(form.Fields, models.FormField{Name: "cooldownminutes", Title: "Alert Cooldown (minutes to suppress repeat alerts for the same number plate, 0 uses the watchlist's cooldown)", Type: "number", Required: false, Placeholder: "0", Value: strconv.Itoa(notificationChannel.CooldownMinutes)})
+	form.Fields = append(form.Fields, models.FormField{Name: "enabled", Title: "Enabled", Type: "checkbox", Value: "1", Checked: notificationChannel.Enabled})
+	form.SubmitName = "Save Changes"
+	return form, nil
+}
+
+// notificationChannelOptions will return the notification channels as form field options with those
+// targeted by the owner type and ID provided selected.
+func notificationChannelOptions(env *models.Env, ownerType string, ownerID int) ([]models.FormFieldOption, error) {
+	var options []models.FormFieldOption
+	selected := map[int]bool{}
+	if ownerID != 0 {
+		var notificationTarget models.NotificationTarget
+		channelIDs, err := notificationTarget.Find(env, ownerType, ownerID)
+		if err != nil {
+			return nil, err
+		}
+		for _, channelID := range channelIDs {
+			selected[channelID] = true
+		}
+	}
+	var notificationChannel models.NotificationChannel
+	resNotificationChannels, _, err := notificationChannel.Find(env, "AND", []models.WhereFields{}, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, resNotificationChannel := range *resNotificationChannels {
+		options = append(options, models.FormFieldOption{Value: strconv.Itoa(resNotificationChannel.ID), Title: resNotificationChannel.Name, Selected: selected[resNotificationChannel.ID]})
+	}
+	return options, nil
+}
+
+// saveNotificationTargets will accept an owner type, owner ID and Request and will save the notification
+// channels selected in the posted form.
+func saveNotificationTargets(env *models.Env, ownerType string, ownerID int, r *http.Request) error {
+	var notificationTarget models.NotificationTarget
+	return notificationTarget.Set(env, ownerType, ownerID, models.ParseChannelIDs(r.PostForm["channels"]))
+}
@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/models"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/views"
+	"net/http"
+	"time"
+)
+
+// onSiteVehicle struct
+type onSiteVehicle struct {
+	Plate     string `json:"plate"`
+	Name      string `json:"name"`
+	EntryTime string `json:"entryTime"`
+	Camera    string `json:"camera"`
+	Duration  string `json:"duration"`
+}
+
+func AdminOnSite(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "On Site", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	list := models.List{}
+	var listRowFields []models.ListRowField
+
+	// Get vehicles on site
+	vehicles, err := getOnSiteVehicles(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	// Return JSON if requested
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(vehicles)
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	page.Title = fmt.Sprintf("On Site (%d)", len(vehicles))
+	if len(vehicles) > 0 {
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Number Plate"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Name"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Arrived"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Camera"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "On Site For"})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		for _, vehicle := range vehicles {
+			var listRowFields []models.ListRowField
+			listRowFields = append(listRowFields, models.ListRowField{Value: vehicle.Plate})
+			listRowFields = append(listRowFields, models.ListRowField{Value: vehicle.Name})
+			listRowFields = append(listRowFields, models.ListRowField{Value: vehicle.EntryTime})
+			listRowFields = append(listRowFields, models.ListRowField{Value: vehicle.Camera})
+			listRowFields = append(listRowFields, models.ListRowField{Value: vehicle.Duration})
+			list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		}
+	} else {
+		listRowFields = []models.ListRowField{}
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "No vehicles on site"})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	}
+	listRowFields = []models.ListRowField{}
+	listRowFields = append(listRowFields, models.ListRowField{Type: "link", Class: "btn btn-icon", Link: "/onsite?format=json", Icon: "code-json", Value: "JSON"})
+	list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+
+	page.View = list
+
+	views.Render(w, env, "list", http.StatusOK, page)
+}
+
+// getOnSiteVehicles will return the vehicles currently on site, most recent arrival first.
+func getOnSiteVehicles(env *models.Env) ([]onSiteVehicle, error) {
+	var visit models.Visit
+	resVisits, _, err := visit.Find(env, "AND", []models.WhereFields{{"closed_reason", "=", ""}}, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	cameraNames, err := getCameraNames(env)
+	if err != nil {
+		return nil, err
+	}
+	numberPlateNames, err := getNumberPlateNames(env)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	vehicles := []onSiteVehicle{}
+	for _, resVisit := range *resVisits {
+		vehicle := onSiteVehicle{
+			Plate:     resVisit.Plate,
+			Name:      numberPlateNames[resVisit.NumberPlateID],
+			EntryTime: resVisit.EntryTime,
+			Camera:    cameraNames[resVisit.EntryCameraID],
+		}
+		if entryTime, err := time.ParseInLocation(models.DateTimeFormat, resVisit.EntryTime, time.Local); err == nil {
+			vehicle.Duration = formatDuration(now.Sub(entryTime))
+		}
+		vehicles = append(vehicles, vehicle)
+	}
+	return vehicles, nil
+}
+
+// getNumberPlateNames will return a map of number plate names by ID.
+func getNumberPlateNames(env *models.Env) (map[int]string, error) {
+	names := map[int]string{}
+	var numberPlate models.NumberPlate
+	resNumberPlates, _, err := numberPlate.Find(env, "AND", []models.WhereFields{}, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, resNumberPlate := range *resNumberPlates {
+		names[resNumberPlate.ID] = resNumberPlate.Name
+	}
+	return names, nil
+}
+
+// formatDuration will accept a duration and will return it rounded to minutes in hours and minutes.
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	hours := int(d.Hours())
+	minutes := int(d.Minutes()) % 60
+	if hours > 0 {
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
@@ -0,0 +1,374 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/models"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/views"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func AdminQuietPeriods(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	user, ok := getSubscriptionUser(env, w, r)
+	if !ok {
+		return
+	}
+	var page = models.Page{Title: fmt.Sprintf("Quiet Hours for %s", user.Email), RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	list := models.List{}
+	var listRowFields []models.ListRowField
+
+	// Get page number
+	pageNumber := getPageNumber(r)
+
+	// Get the user's quiet periods
+	var quietPeriod models.QuietPeriod
+	resQuietPeriods, resCount, err := quietPeriod.Find(env, "AND", []models.WhereFields{{"user_id", "=", user.ID}}, getPerPage(env), pageNumber)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	// Get notification channel names
+	channelNames, err := getNotificationChannelNames(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	if resCount > 0 {
+		listRowFields = append(listRowFields, models.ListRowField{Value: "For"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Quiet Hours"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Do Not Disturb"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Alerts"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "Actions"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: ""})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		for _, resQuietPeriod := range *resQuietPeriods {
+			var listRowFields []models.ListRowField
+			doNotDisturb := ""
+			if resQuietPeriod.StartsAt != "" {
+				doNotDisturb = fmt.Sprintf("%s to %s", resQuietPeriod.StartsAt, resQuietPeriod.EndsAt)
+			}
+			listRowFields = append(listRowFields, models.ListRowField{Value: quietPeriodChannelName(resQuietPeriod, channelNames)})
+			listRowFields = append(listRowFields, models.ListRowField{Value: strings.ReplaceAll(strings.ReplaceAll(resQuietPeriod.Windows, "\r", ""), "\n", ", ")})
+			listRowFields = append(listRowFields, models.ListRowField{Value: doNotDisturb})
+			listRowFields = append(listRowFields, models.ListRowField{Value: quietPeriodActions[resQuietPeriod.Action]})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-red", Link: fmt.Sprintf("/users/%v/quiet/%v/delete", user.ID, resQuietPeriod.ID), Confirm: "Are you sure you want to delete these quiet hours?", Icon: "delete", Value: "Delete"})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-yellow", Link: fmt.Sprintf("/users/%v/quiet/%v", user.ID, resQuietPeriod.ID), Icon: "pencil", Value: "Edit"})
+			list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		}
+		// Get pagination
+		list.Pagination = getPagination(env, pageNumber, resCount)
+	} else {
+		listRowFields = []models.ListRowField{}
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "No quiet hours found"})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	}
+	listRowFields = []models.ListRowField{}
+	listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-primary", Link: fmt.Sprintf("/users/%v/quiet/add", user.ID), Icon: "plus", Value: "Add"})
+	listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn", Link: "/users", Value: "Back to Users"})
+	list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+
+	page.View = list
+
+	views.Render(w, env, "list", http.StatusOK, page)
+}
+
+// AdminMyQuietPeriods redirects to the logged in user's quiet hours.
+func AdminMyQuietPeriods(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	userID, err := getSessionUserID(env, r)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/users/%d/quiet", userID), 302)
+}
+
+func AdminAddQuietPeriod(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	user, ok := getSubscriptionUser(env, w, r)
+	if !ok {
+		return
+	}
+	var page = models.Page{Title: "Add Quiet Hours", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	quietPeriod := models.QuietPeriod{UserID: user.ID, ChannelType: "all", Action: "suppress"}
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Set values
+		setQuietPeriodValues(&quietPeriod, r)
+		// Validate values
+		page.ErrorMessages = validateQuietPeriod(env, quietPeriod)
+
+		// Check for errors
+		if len(page.ErrorMessages) == 0 {
+			// Add quiet period to database
+			_, err = quietPeriod.Add(env)
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "quiet", fmt.Sprintf("Add quiet hours for user id %d", user.ID))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			// Redirect
+			http.Redirect(w, r, fmt.Sprintf("/users/%d/quiet", user.ID), 302)
+			return
+		}
+	}
+
+	form, err := quietPeriodForm(env, quietPeriod)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	page.View = form
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminEditQuietPeriod(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	user, ok := getSubscriptionUser(env, w, r)
+	if !ok {
+		return
+	}
+	var page = models.Page{Title: "Edit Quiet Hours", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	// Parse GET parameters ready for use
+	vars := mux.Vars(r)
+
+	quietPeriodID, err := strconv.Atoi(fmt.Sprint(vars["quietid"]))
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	quietPeriod := models.QuietPeriod{ID: quietPeriodID}
+	resQuietPeriod, err := quietPeriod.Get(env)
+	if err != nil || resQuietPeriod.UserID != user.ID {
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		err := displayError(env, w, r, "404", "Oops! Page Not Found", "404 Page Not Found")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	quietPeriod = *resQuietPeriod
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Set values
+		setQuietPeriodValues(&quietPeriod, r)
+		// Validate values
+		page.ErrorMessages = validateQuietPeriod(env, quietPeriod)
+
+		// Check for errors
+		if len(page.ErrorMessages) == 0 {
+			// Update quiet period in database
+			_, err = quietPeriod.Update(env)
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "quiet", fmt.Sprintf("Update quiet hours id %d", quietPeriod.ID))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			// Redirect
+			http.Redirect(w, r, fmt.Sprintf("/users/%d/quiet", user.ID), 302)
+			return
+		}
+	}
+
+	form, err := quietPeriodForm(env, quietPeriod)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	page.View = form
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminDeleteQuietPeriod(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	// Parse GET parameters ready for use
+	vars := mux.Vars(r)
+
+	userID, _ := strconv.Atoi(fmt.Sprint(vars["id"]))
+
+	if r.Method == http.MethodGet {
+		// Set values
+		quietPeriodID, err := strconv.Atoi(fmt.Sprint(vars["quietid"]))
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Delete quiet period from database if it belongs to the user
+		quietPeriod := models.QuietPeriod{ID: quietPeriodID}
+		resQuietPeriod, err := quietPeriod.Get(env)
+		if err == nil && resQuietPeriod.UserID == userID {
+			_, err = resQuietPeriod.Delete(env)
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+
+			// Add admin log to database
+			err = adminLog(env, r, "quiet", fmt.Sprintf("Delete quiet hours id %d", quietPeriod.ID))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+		}
+	}
+
+	// Redirect
+	http.Redirect(w, r, fmt.Sprintf("/users/%d/quiet", userID), 302)
+}
+
+// quietPeriodActions are the display names of what happens to alerts during quiet periods.
+var quietPeriodActions = map[string]string{"suppress": "Not sent", "defer": "Sent when they end"}
+
+// setQuietPeriodValues will accept a quiet period and Request and will set the quiet period's values from the posted form.
+func setQuietPeriodValues(quietPeriod *models.QuietPeriod, r *http.Request) {
+	channelType, channelID, _ := strings.Cut(r.PostFormValue("channel"), ":")
+	quietPeriod.ChannelType = channelType
+	quietPeriod.ChannelID, _ = strconv.Atoi(channelID)
+	quietPeriod.Windows = strings.TrimSpace(r.PostFormValue("windows"))
+	quietPeriod.StartsAt = parseDateTimeInput(r.PostFormValue("startsat"))
+	quietPeriod.EndsAt = parseDateTimeInput(r.PostFormValue("endsat"))
+	quietPeriod.Action = r.PostFormValue("action")
+}
+
+// validateQuietPeriod will accept a quiet period and will return any validation error messages.
+func validateQuietPeriod(env *models.Env, quietPeriod models.QuietPeriod) []string {
+	var errorMessages []string
+	err := env.Validator.Struct(quietPeriod)
+	if err != nil {
+		for _, e := range err.(validator.ValidationErrors) {
+			errorMessages = append(errorMessages, e.Translate(env.ValidatorTranslator))
+		}
+		return errorMessages
+	}
+	if err := quietPeriod.Check(); err != nil {
+		errorMessages = append(errorMessages, err.Error())
+	}
+	return errorMessages
+}
+
+// quietPeriodForm will accept a quiet period and will return the form to add or edit it.
+func quietPeriodForm(env *models.Env, quietPeriod models.QuietPeriod) (models.Form, error) {
+	form := models.Form{CancelLink: fmt.Sprintf("/users/%d/quiet", quietPeriod.UserID)}
+	selected := quietPeriodChannelKey(quietPeriod)
+	channelOpts := []models.FormFieldOption{
+		{Value: "all", Title: "Email and all notification channels", Selected: selected == "all"},
+		{Value: "email", Title: "Email", Selected: selected == "email"},
+	}
+	var notificationChannel models.NotificationChannel
+	resNotificationChannels, _, err := notificationChannel.Find(env, "AND", []models.WhereFields{}, 0, 1)
+	if err != nil {
+		return form, err
+	}
+	for _, resNotificationChannel := range *resNotificationChannels {
+		key := fmt.Sprintf("channel:%d", resNotificationChannel.ID)
+		channelOpts = append(channelOpts, models.FormFieldOption{Value: key, Title: "Channel: " + resNotificationChannel.Name, Selected: selected == key})
+	}
+	form.Fields = append(form.Fields, models.FormField{Name: "channel", Title: "For", Type: "select", Options: channelOpts})
+	form.Fields = append(form.Fields, models.FormField{Name: "windows", Title: "Quiet Hours (one per line, e.g. mon-fri 22:00-07:00 or sat,sun 00:00-24:00)", Type: "textarea", Required: false, Placeholder: "daily 22:00-07:00", Value: quietPeriod.Windows})
+	form.Fields = append(form.Fields, models.FormField{Name: "startsat", Title: "Do Not Disturb From", Type: "datetime-local", Value: formatDateTimeInput(quietPeriod.StartsAt)})
+	form.Fields = append(form.Fields, models.FormField{Name: "endsat", Title: "Do Not Disturb Until", Type: "datetime-local", Value: formatDateTimeInput(quietPeriod.EndsAt)})
+	form.Fields = append(form.Fields, models.FormField{Name: "action", Title: "Non-critical Alerts", Type: "select", Options: []models.FormFieldOption{
+		{Value: "suppress", Title: quietPeriodActions["suppress"], Selected: quietPeriod.Action == "suppress"},
+		{Value: "defer", Title: quietPeriodActions["defer"], Selected: quietPeriod.Action == "defer"},
+	}})
+	form.SubmitName = "Save Changes"
+	return form, nil
+}
+
+// quietPeriodChannelKey will accept a quiet period and will return what it's for in the format "all",
+// "email" or "channel:1".
+func quietPeriodChannelKey(quietPeriod models.QuietPeriod) string {
+	if quietPeriod.ChannelType == "channel" {
+		return fmt.Sprintf("channel:%d", quietPeriod.ChannelID)
+	}
+	return quietPeriod.ChannelType
+}
+
+// quietPeriodChannelName will accept a quiet period and notification channel names and will return the
+// name of what it's for.
+func quietPeriodChannelName(quietPeriod models.QuietPeriod, channelNames map[int]string) string {
+	switch quietPeriod.ChannelType {
+	case "all":
+		return "Email and all notification channels"
+	case "email":
+		return "Email"
+	}
+	return channelNames[quietPeriod.ChannelID]
+}
@@ -0,0 +1,472 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/models"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/views"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func AdminRules(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Rules", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	list := models.List{}
+	var listRowFields []models.ListRowField
+
+	// Get page number
+	pageNumber := getPageNumber(r)
+
+	// Get all rules
+	var rule models.Rule
+	resRules, resCount, err := rule.Find(env, "AND", []models.WhereFields{}, getPerPage(env), pageNumber)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	// Get schedule names
+	scheduleNames, err := getScheduleNames(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	if resCount > 0 {
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Name"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Status"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Schedule"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Then"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "Actions"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: ""})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: ""})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		for _, resRule := range *resRules {
+			var listRowFields []models.ListRowField
+			listRowFields = append(listRowFields, models.ListRowField{Value: resRule.Name})
+			if resRule.Enabled {
+				listRowFields = append(listRowFields, models.ListRowField{Value: "Enabled"})
+			} else {
+				listRowFields = append(listRowFields, models.ListRowField{FieldClass: " yellow", Value: "Disabled"})
+			}
+			listRowFields = append(listRowFields, models.ListRowField{Value: scheduleNames[resRule.ScheduleID]})
+			listRowFields = append(listRowFields, models.ListRowField{Value: ruleActions(resRule)})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon", Link: fmt.Sprintf("/rules/%v/hits", resRule.ID), Icon: "history", Value: "Hits"})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-red", Link: fmt.Sprintf("/rules/%v/delete", resRule.ID), Confirm: "Are you sure you want to delete this rule?", Icon: "delete", Value: "Delete"})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-yellow", Link: fmt.Sprintf("/rules/%v", resRule.ID), Icon: "pencil", Value: "Edit"})
+			list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		}
+		// Get pagination
+		list.Pagination = getPagination(env, pageNumber, resCount)
+	} else {
+		listRowFields = []models.ListRowField{}
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "No rules found"})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	}
+	listRowFields = []models.ListRowField{}
+	listRowFields = append(listRowFields, models.ListRowField{Type: "link", Class: "btn btn-icon btn-primary", Link: "/rules/add", Icon: "plus", Value: "Add"})
+	list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+
+	page.View = list
+
+	views.Render(w, env, "list", http.StatusOK, page)
+}
+
+func AdminAddRule(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Add Rule", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	rule := models.Rule{Enabled: true, Notify: true}
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Set values
+		setRuleValues(&rule, r)
+		// Validate values
+		page.ErrorMessages = validateRule(env, rule)
+
+		// Check for errors
+		if len(page.ErrorMessages) == 0 {
+			// Add rule to database
+			_, err = rule.Add(env)
+			if err == nil {
+				// Save camera scopes
+				err = saveCameraScopes(env, "rule", rule.ID, r)
+			}
+			if err == nil {
+				// Save notification channels
+				err = saveNotificationTargets(env, "rule", rule.ID, r)
+			}
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "rule", fmt.Sprintf("Add rule %s", rule.Name))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			// Redirect
+			http.Redirect(w, r, "/rules", 302)
+			return
+		}
+	}
+
+	form, err := ruleForm(env, rule)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	page.View = form
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminEditRule(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Edit Rule", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	// Parse GET parameters ready for use
+	vars := mux.Vars(r)
+
+	ruleID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	rule := models.Rule{ID: ruleID}
+	resRule, err := rule.Get(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "404", "Oops! Page Not Found", "404 Page Not Found")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	rule = *resRule
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Set values
+		setRuleValues(&rule, r)
+		// Validate values
+		page.ErrorMessages = validateRule(env, rule)
+
+		// Check for errors
+		if len(page.ErrorMessages) == 0 {
+			// Update rule in database
+			_, err = rule.Update(env)
+			if err == nil {
+				// Save camera scopes
+				err = saveCameraScopes(env, "rule", rule.ID, r)
+			}
+			if err == nil {
+				// Save notification channels
+				err = saveNotificationTargets(env, "rule", rule.ID, r)
+			}
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "rule", fmt.Sprintf("Update rule id %d", rule.ID))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			// Redirect
+			http.Redirect(w, r, "/rules", 302)
+			return
+		}
+	}
+
+	form, err := ruleForm(env, rule)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	page.View = form
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminDeleteRule(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		rule := models.Rule{}
+
+		// Parse GET parameters ready for use
+		vars := mux.Vars(r)
+
+		// Set values
+		ruleID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Delete rule from database
+		rule.ID = ruleID
+		_, err = rule.Delete(env)
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Add admin log to database
+		err = adminLog(env, r, "rule", fmt.Sprintf("Delete rule id %d", rule.ID))
+		if err != nil {
+			env.Logger.Println(err)
+		}
+	}
+
+	// Redirect
+	http.Redirect(w, r, "/rules", 302)
+}
+
+func AdminRuleHits(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Rule Hits", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	list := models.List{}
+	var listRowFields []models.ListRowField
+
+	// Parse GET parameters ready for use
+	vars := mux.Vars(r)
+
+	ruleID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	rule := models.Rule{ID: ruleID}
+	resRule, err := rule.Get(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "404", "Oops! Page Not Found", "404 Page Not Found")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	page.Title = fmt.Sprintf("Rule Hits: %s", resRule.Name)
+
+	// Get page number
+	pageNumber := getPageNumber(r)
+
+	// Get camera names
+	cameraNames, err := getCameraNames(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	// Get rule hits
+	var ruleHit models.RuleHit
+	resRuleHits, resCount, err := ruleHit.Find(env, "AND", []models.WhereFields{{"rule_id", "=", ruleID}}, getPerPage(env), pageNumber)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	if resCount > 0 {
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Time"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Number Plate"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Camera"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Why"})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		for _, resRuleHit := range *resRuleHits {
+			detection := models.Detection{ID: resRuleHit.DetectionID}
+			resDetection, err := detection.Get(env)
+			if err != nil {
+				resDetection = &models.Detection{EventTime: resRuleHit.CreatedAt}
+			}
+			var listRowFields []models.ListRowField
+			listRowFields = append(listRowFields, models.ListRowField{Value: resDetection.EventTime})
+			listRowFields = append(listRowFields, models.ListRowField{Value: resDetection.Plate})
+			listRowFields = append(listRowFields, models.ListRowField{Value: cameraNames[resDetection.CameraID]})
+			listRowFields = append(listRowFields, models.ListRowField{Value: strings.ReplaceAll(resRuleHit.Explanation, "\n", "; ")})
+			list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		}
+		// Get pagination
+		list.Pagination = getPagination(env, pageNumber, resCount)
+	} else {
+		listRowFields = []models.ListRowField{}
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "No rule hits found"})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	}
+
+	page.View = list
+
+	views.Render(w, env, "list", http.StatusOK, page)
+}
+
+// setRuleValues will accept a rule and Request and will set the rule's values from the posted form.
+func setRuleValues(rule *models.Rule, r *http.Request) {
+	rule.Name = r.PostFormValue("name")
+	rule.Enabled = r.PostFormValue("enabled") != ""
+	rule.PlatePattern = strings.TrimSpace(r.PostFormValue("platepattern"))
+	rule.Registered = r.PostFormValue("registered")
+	rule.WatchlistID, _ = strconv.Atoi(r.PostFormValue("watchlistid"))
+	rule.Direction = r.PostFormValue("direction")
+	rule.ScheduleID, _ = strconv.Atoi(r.PostFormValue("scheduleid"))
+	rule.MinConfidence, _ = strconv.Atoi(r.PostFormValue("minconfidence"))
+	setVehicleAttributes(&rule.VehicleAttributes, r)
+	rule.CountThreshold, _ = strconv.Atoi(r.PostFormValue("countthreshold"))
+	rule.CountMinutes, _ = strconv.Atoi(r.PostFormValue("countminutes"))
+	rule.Notify = r.PostFormValue("notify") != ""
+	rule.Tag = strings.TrimSpace(r.PostFormValue("tag"))
+	rule.OutputID, _ = strconv.Atoi(r.PostFormValue("outputid"))
+	rule.EscalationPolicyID, _ = strconv.Atoi(r.PostFormValue("escalationpolicyid"))
+}
+
+// validateRule will accept a rule and will return any validation error messages.
+func validateRule(env *models.Env, rule models.Rule) []string {
+	var errorMessages []string
+	err := env.Validator.Struct(rule)
+	if err != nil {
+		for _, e := range err.(validator.ValidationErrors) {
+			errorMessages = append(errorMessages, e.Translate(env.ValidatorTranslator))
+		}
+		return errorMessages
+	}
+	if err := rule.Check(); err != nil {
+		errorMessages = append(errorMessages, err.Error())
+	}
+	if !rule.Notify && rule.Tag == "" && rule.OutputID == 0 {
+		errorMessages = append(errorMessages, "At least one action is required")
+	}
+	return errorMessages
+}
+
+// ruleForm will accept a rule and will return the form to add or edit it.
+func ruleForm(env *models.Env, rule models.Rule) (models.Form, error) {
+	form := models.Form{CancelLink: "/rules"}
+	watchlistOpts, err := watchlistOptions(env, rule.WatchlistID)
+	if err != nil {
+		return form, err
+	}
+	watchlistOpts[0].Title = "Any"
+	scheduleOpts, err := scheduleOptions(env, rule.ScheduleID)
+	if err != nil {
+		return form, err
+	}
+	cameraOpts, err := cameraScopeOptions(env, "rule", rule.ID)
+	if err != nil {
+		return form, err
+	}
+	channelOpts, err := notificationChannelOptions(env, "rule", rule.ID)
+	if err != nil {
+		return form, err
+	}
+	escalationPolicyOpts, err := escalationPolicyOptions(env, rule.EscalationPolicyID)
+	if err != nil {
+		return form, err
+	}
+	form.Fields = append(form.Fields, models.FormField{Name: "name", Title: "Name *", Type: "text", Required: true, Placeholder: "Name", Value: rule.Name})
+	form.Fields = append(form.Fields, models.FormField{Name: "enabled", Title: "Enabled", Type: "checkbox", Value: "1", Checked: rule.Enabled})
+	// Conditions
+	form.Fields = append(form.Fields, models.FormField{Name: "platepattern", Title: "When the number plate matches (* matches any characters, e.g. AB12*)", Type: "text", Required: false, Placeholder: "*", Value: rule.PlatePattern})
+	form.Fields = append(form.Fields, models.FormField{Name: "registered", Title: "When the number plate is", Type: "select", Options: []models.FormFieldOption{
+		{Value: "", Title: "Registered or unregistered", Selected: rule.Registered == ""},
+		{Value: "registered", Title: "Registered", Selected: rule.Registered == "registered"},
+		{Value: "unregistered", Title: "Unregistered", Selected: rule.Registered == "unregistered"},
+	}})
+	form.Fields = append(form.Fields, models.FormField{Name: "watchlistid", Title: "When the number plate is on watchlist", Type: "select", Options: watchlistOpts})
+	form.Fields = append(form.Fields, models.FormField{Name: "cameras", Title: "When read by cameras (none selected matches all cameras)", Type: "select", Multiple: true, Options: cameraOpts})
+	form.Fields = append(form.Fields, models.FormField{Name: "direction", Title: "When the vehicle is", Type: "select", Options: directionOptions(rule.Direction, "Entering or leaving")})
+	form.Fields = append(form.Fields, models.FormField{Name: "scheduleid", Title: "When inside schedule", Type: "select", Options: scheduleOpts})
+	form.Fields = append(form.Fields, models.FormField{Name: "minconfidence", Title: "When the confidence is at least (0-100)", Type: "number", Required: false, Placeholder: "0", Value: strconv.Itoa(rule.MinConfidence)})
+	form.Fields = append(form.Fields, vehicleAttributeFields(rule.VehicleAttributes, "When the vehicle matches ")...)
+	form.Fields = append(form.Fields, models.FormField{Name: "countthreshold", Title: "When the number plate has been read at least this many times (0 for any)", Type: "number", Required: false, Placeholder: "0", Value: strconv.Itoa(rule.CountThreshold)})
+	form.Fields = append(form.Fields, models.FormField{Name: "countminutes", Title: "In the last this many minutes", Type: "number", Required: false, Placeholder: "0", Value: strconv.Itoa(rule.CountMinutes)})
+	// Actions
+	form.Fields = append(form.Fields, models.FormField{Name: "notify", Title: "Then send an alert", Type: "checkbox", Value: "1", Checked: rule.Notify})
+	form.Fields = append(form.Fields, models.FormField{Name: "channels", Title: "Then send the alert to notification channels (as well as subscribed users)", Type: "select", Multiple: true, Options: channelOpts})
+	form.Fields = append(form.Fields, models.FormField{Name: "escalationpolicyid", Title: "Then escalate the alert if it isn't acknowledged using", Type: "select", Options: escalationPolicyOpts})
+	form.Fields = append(form.Fields, models.FormField{Name: "tag", Title: "Then tag the detection with", Type: "text", Required: false, Placeholder: "Tag", Value: rule.Tag})
+	form.Fields = append(form.Fields, models.FormField{Name: "outputid", Title: "Then trigger the camera's alarm output (0 for none)", Type: "number", Required: false, Placeholder: "0", Value: strconv.Itoa(rule.OutputID)})
+	form.SubmitName = "Save Changes"
+	return form, nil
+}
+
+// ruleActions will accept a rule and will return a description of its actions.
+func ruleActions(rule models.Rule) string {
+	var actions []string
+	if rule.Notify {
+		actions = append(actions, "Alert")
+	}
+	if rule.Tag != "" {
+		actions = append(actions, fmt.Sprintf("Tag %s", rule.Tag))
+	}
+	if rule.OutputID != 0 {
+		actions = append(actions, fmt.Sprintf("Trigger output %d", rule.OutputID))
+	}
+	return strings.Join(actions, ", ")
+}
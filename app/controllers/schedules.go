@@ -0,0 +1,291 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/models"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/views"
+	"net/http"
+	"strconv"
+)
+
+func AdminSchedules(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Schedules", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	list := models.List{}
+	var listRowFields []models.ListRowField
+
+	// Get page number
+	pageNumber := getPageNumber(r)
+
+	// Get all schedules
+	var schedule models.Schedule
+	resSchedules, resCount, err := schedule.Find(env, "AND", []models.WhereFields{}, getPerPage(env), pageNumber)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	if resCount > 0 {
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Name"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Timezone"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "Actions"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: ""})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		for _, resSchedule := range *resSchedules {
+			var listRowFields []models.ListRowField
+			listRowFields = append(listRowFields, models.ListRowField{Value: resSchedule.Name})
+			listRowFields = append(listRowFields, models.ListRowField{Value: resSchedule.Timezone})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-red", Link: fmt.Sprintf("/schedules/%v/delete", resSchedule.ID), Confirm: "Are you sure you want to delete this schedule?", Icon: "delete", Value: "Delete"})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-yellow", Link: fmt.Sprintf("/schedules/%v", resSchedule.ID), Icon: "pencil", Value: "Edit"})
+			list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		}
+		// Get pagination
+		list.Pagination = getPagination(env, pageNumber, resCount)
+	} else {
+		listRowFields = []models.ListRowField{}
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "No schedules found"})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	}
+	listRowFields = []models.ListRowField{}
+	listRowFields = append(listRowFields, models.ListRowField{Type: "link", Class: "btn btn-icon btn-primary", Link: "/schedules/add", Icon: "plus", Value: "Add"})
+	list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+
+	page.View = list
+
+	views.Render(w, env, "list", http.StatusOK, page)
+}
+
+func AdminAddSchedule(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Add Schedule", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	schedule := models.Schedule{HolidayMode: "inactive"}
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Set values
+		setScheduleValues(&schedule, r)
+		// Validate values
+		page.ErrorMessages = validateSchedule(env, schedule)
+
+		// Check for errors
+		if len(page.ErrorMessages) == 0 {
+			// Add schedule to database
+			_, err = schedule.Add(env)
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "schedule", fmt.Sprintf("Add schedule %s", schedule.Name))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			// Redirect
+			http.Redirect(w, r, "/schedules", 302)
+			return
+		}
+	}
+
+	page.View = scheduleForm(schedule)
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminEditSchedule(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Edit Schedule", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	// Parse GET parameters ready for use
+	vars := mux.Vars(r)
+
+	scheduleID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	schedule := models.Schedule{ID: scheduleID}
+	resSchedule, err := schedule.Get(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "404", "Oops! Page Not Found", "404 Page Not Found")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	schedule = *resSchedule
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Set values
+		setScheduleValues(&schedule, r)
+		// Validate values
+		page.ErrorMessages = validateSchedule(env, schedule)
+
+		// Check for errors
+		if len(page.ErrorMessages) == 0 {
+			// Update schedule in database
+			_, err = schedule.Update(env)
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "schedule", fmt.Sprintf("Update schedule id %d", schedule.ID))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			// Redirect
+			http.Redirect(w, r, "/schedules", 302)
+			return
+		}
+	}
+
+	page.View = scheduleForm(schedule)
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminDeleteSchedule(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		schedule := models.Schedule{}
+
+		// Parse GET parameters ready for use
+		vars := mux.Vars(r)
+
+		// Set values
+		scheduleID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Delete schedule from database
+		schedule.ID = scheduleID
+		_, err = schedule.Delete(env)
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Add admin log to database
+		err = adminLog(env, r, "schedule", fmt.Sprintf("Delete schedule id %d", schedule.ID))
+		if err != nil {
+			env.Logger.Println(err)
+		}
+	}
+
+	// Redirect
+	http.Redirect(w, r, "/schedules", 302)
+}
+
+// setScheduleValues will accept a schedule and Request and will set the schedule's values from the posted form.
+func setScheduleValues(schedule *models.Schedule, r *http.Request) {
+	schedule.Name = r.PostFormValue("name")
+	schedule.Timezone = r.PostFormValue("timezone")
+	schedule.Windows = r.PostFormValue("windows")
+	schedule.Holidays = r.PostFormValue("holidays")
+	schedule.HolidayMode = r.PostFormValue("holidaymode")
+}
+
+// validateSchedule will accept a schedule and will return any validation error messages.
+func validateSchedule(env *models.Env, schedule models.Schedule) []string {
+	var errorMessages []string
+	err := env.Validator.Struct(schedule)
+	if err != nil {
+		for _, e := range err.(validator.ValidationErrors) {
+			errorMessages = append(errorMessages, e.Translate(env.ValidatorTranslator))
+		}
+		return errorMessages
+	}
+	if err := schedule.Check(); err != nil {
+		errorMessages = append(errorMessages, err.Error())
+	}
+	return errorMessages
+}
+
+// scheduleForm will accept a schedule and will return the form to add or edit it.
+func scheduleForm(schedule models.Schedule) models.Form {
+	form := models.Form{CancelLink: "/schedules"}
+	form.Fields = append(form.Fields, models.FormField{Name: "name", Title: "Name *", Type: "text", Required: true, Placeholder: "Name", Value: schedule.Name})
+	form.Fields = append(form.Fields, models.FormField{Name: "timezone", Title: "Timezone (defaults to server timezone)", Type: "text", Required: false, Placeholder: "Europe/London", Value: schedule.Timezone})
+	form.Fields = append(form.Fields, models.FormField{Name: "windows", Title: "Active Windows * (one per line, e.g. mon-fri 18:00-08:00 or sat,sun 00:00-24:00)", Type: "textarea", Required: true, Placeholder: "mon-fri 18:00-08:00", Value: schedule.Windows})
+	form.Fields = append(form.Fields, models.FormField{Name: "holidays", Title: "Holidays (one date per line)", Type: "textarea", Required: false, Placeholder: "2006-01-02", Value: schedule.Holidays})
+	form.Fields = append(form.Fields, models.FormField{Name: "holidaymode", Title: "On Holidays", Type: "select", Options: []models.FormFieldOption{
+		{Value: "inactive", Title: "Inactive all day", Selected: schedule.HolidayMode == "inactive"},
+		{Value: "active", Title: "Active all day", Selected: schedule.HolidayMode == "active"},
+	}})
+	form.SubmitName = "Save Changes"
+	return form
+}
+
+// scheduleOptions will return the schedules as form field options with the schedule ID provided selected.
+func scheduleOptions(env *models.Env, scheduleID int) ([]models.FormFieldOption, error) {
+	options := []models.FormFieldOption{{Value: "0", Title: "Always", Selected: scheduleID == 0}}
+	var schedule models.Schedule
+	resSchedules, _, err := schedule.Find(env, "AND", []models.WhereFields{}, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, resSchedule := range *resSchedules {
+		options = append(options, models.FormFieldOption{Value: strconv.Itoa(resSchedule.ID), Title: resSchedule.Name, Selected: resSchedule.ID == scheduleID})
+	}
+	return options, nil
+}
+
+// getScheduleNames will return a map of schedule names by ID.
+func getScheduleNames(env *models.Env) (map[int]string, error) {
+	names := map[int]string{0: "Always"}
+	var schedule models.Schedule
+	resSchedules, _, err := schedule.Find(env, "AND", []models.WhereFields{}, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, resSchedule := range *resSchedules {
+		names[resSchedule.ID] = resSchedule.Name
+	}
+	return names, nil
+}
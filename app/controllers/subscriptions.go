@@ -0,0 +1,471 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/models"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/views"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func AdminSubscriptions(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	user, ok := getSubscriptionUser(env, w, r)
+	if !ok {
+		return
+	}
+	var page = models.Page{Title: fmt.Sprintf("Subscriptions for %s", user.Email), RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	list := models.List{}
+	var listRowFields []models.ListRowField
+
+	// Get page number
+	pageNumber := getPageNumber(r)
+
+	// Get the user's subscriptions
+	var subscription models.Subscription
+	resSubscriptions, resCount, err := subscription.Find(env, "AND", []models.WhereFields{{"user_id", "=", user.ID}}, getPerPage(env), pageNumber)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	// Get subject and notification channel names
+	subjectNames, err := getSubscriptionSubjectNames(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	channelNames, err := getNotificationChannelNames(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	if resCount > 0 {
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Alerts For"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Send To"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "Actions"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: ""})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		for _, resSubscription := range *resSubscriptions {
+			var listRowFields []models.ListRowField
+			var sendTo []string
+			if resSubscription.Frequency != "" {
+				sendTo = append(sendTo, fmt.Sprintf("Email (%s at %02d:00)", strings.ToLower(models.SubscriptionFrequencies[resSubscription.Frequency]), resSubscription.DigestHour))
+			} else if resSubscription.Email {
+				sendTo = append(sendTo, "Email")
+			}
+			var notificationTarget models.NotificationTarget
+			channelIDs, err := notificationTarget.Find(env, "subscription", resSubscription.ID)
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			for _, channelID := range channelIDs {
+				sendTo = append(sendTo, channelNames[channelID])
+			}
+			listRowFields = append(listRowFields, models.ListRowField{Value: subjectNames[subscriptionSubjectKey(resSubscription)]})
+			listRowFields = append(listRowFields, models.ListRowField{Value: strings.Join(sendTo, ", ")})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-red", Link: fmt.Sprintf("/users/%v/subscriptions/%v/delete", user.ID, resSubscription.ID), Confirm: "Are you sure you want to delete this subscription?", Icon: "delete", Value: "Delete"})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-yellow", Link: fmt.Sprintf("/users/%v/subscriptions/%v", user.ID, resSubscription.ID), Icon: "pencil", Value: "Edit"})
+			list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		}
+		// Get pagination
+		list.Pagination = getPagination(env, pageNumber, resCount)
+	} else {
+		listRowFields = []models.ListRowField{}
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "No subscriptions found"})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	}
+	listRowFields = []models.ListRowField{}
+	listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-primary", Link: fmt.Sprintf("/users/%v/subscriptions/add", user.ID), Icon: "plus", Value: "Add"})
+	listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn", Link: "/users", Value: "Back to Users"})
+	list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+
+	page.View = list
+
+	views.Render(w, env, "list", http.StatusOK, page)
+}
+
+func AdminAddSubscription(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	user, ok := getSubscriptionUser(env, w, r)
+	if !ok {
+		return
+	}
+	var page = models.Page{Title: "Add Subscription", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	subscription := models.Subscription{UserID: user.ID, SubjectType: "all", Email: true, DigestHour: 8}
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Set values
+		setSubscriptionValues(&subscription, r)
+		// Validate values
+		page.ErrorMessages = validateSubscription(env, subscription, r)
+
+		// Check for errors
+		if len(page.ErrorMessages) == 0 {
+			// Add subscription to database
+			_, err = subscription.Add(env)
+			if err == nil {
+				// Save notification channels
+				err = saveNotificationTargets(env, "subscription", subscription.ID, r)
+			}
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "subscription", fmt.Sprintf("Add subscription to %s for user id %d", subscriptionSubjectKey(subscription), user.ID))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			// Redirect
+			http.Redirect(w, r, fmt.Sprintf("/users/%d/subscriptions", user.ID), 302)
+			return
+		}
+	}
+
+	form, err := subscriptionForm(env, subscription)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	page.View = form
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminEditSubscription(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	user, ok := getSubscriptionUser(env, w, r)
+	if !ok {
+		return
+	}
+	var page = models.Page{Title: "Edit Subscription", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	// Parse GET parameters ready for use
+	vars := mux.Vars(r)
+
+	subscriptionID, err := strconv.Atoi(fmt.Sprint(vars["subscriptionid"]))
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	subscription := models.Subscription{ID: subscriptionID}
+	resSubscription, err := subscription.Get(env)
+	if err != nil || resSubscription.UserID != user.ID {
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		err := displayError(env, w, r, "404", "Oops! Page Not Found", "404 Page Not Found")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	subscription = *resSubscription
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Set values
+		setSubscriptionValues(&subscription, r)
+		// Validate values
+		page.ErrorMessages = validateSubscription(env, subscription, r)
+
+		// Check for errors
+		if len(page.ErrorMessages) == 0 {
+			// Update subscription in database
+			_, err = subscription.Update(env)
+			if err == nil {
+				// Save notification channels
+				err = saveNotificationTargets(env, "subscription", subscription.ID, r)
+			}
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "subscription", fmt.Sprintf("Update subscription id %d", subscription.ID))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			// Redirect
+			http.Redirect(w, r, fmt.Sprintf("/users/%d/subscriptions", user.ID), 302)
+			return
+		}
+	}
+
+	form, err := subscriptionForm(env, subscription)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	page.View = form
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminDeleteSubscription(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	// Parse GET parameters ready for use
+	vars := mux.Vars(r)
+
+	userID, _ := strconv.Atoi(fmt.Sprint(vars["id"]))
+
+	if r.Method == http.MethodGet {
+		// Set values
+		subscriptionID, err := strconv.Atoi(fmt.Sprint(vars["subscriptionid"]))
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Delete subscription from database if it belongs to the user
+		subscription := models.Subscription{ID: subscriptionID}
+		resSubscription, err := subscription.Get(env)
+		if err == nil && resSubscription.UserID == userID {
+			_, err = resSubscription.Delete(env)
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+
+			// Add admin log to database
+			err = adminLog(env, r, "subscription", fmt.Sprintf("Delete subscription id %d", subscription.ID))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+		}
+	}
+
+	// Redirect
+	http.Redirect(w, r, fmt.Sprintf("/users/%d/subscriptions", userID), 302)
+}
+
+// getSubscriptionUser will get the user whose subscriptions are being managed from the Request, displaying
+// an error and returning false if they don't exist.
+func getSubscriptionUser(env *models.Env, w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	// Parse GET parameters ready for use
+	vars := mux.Vars(r)
+
+	userID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+	if err == nil {
+		user := models.User{ID: userID}
+		resUser, err := user.Get(env)
+		if err == nil {
+			return resUser, true
+		}
+	}
+	env.Logger.Println(err)
+	err = displayError(env, w, r, "404", "Oops! Page Not Found", "404 Page Not Found")
+	if err != nil {
+		env.Logger.Println(err)
+	}
+	return nil, false
+}
+
+// setSubscriptionValues will accept a subscription and Request and will set the subscription's values from the posted form.
+func setSubscriptionValues(subscription *models.Subscription, r *http.Request) {
+	subscription.SubjectType, subscription.SubjectID = parseSubscriptionSubject(r.PostFormValue("subject"))
+	subscription.Email = r.PostFormValue("email") == "1"
+	frequency, digestHour := subscription.Frequency, subscription.DigestHour
+	subscription.Frequency = r.PostFormValue("frequency")
+	subscription.DigestHour, _ = strconv.Atoi(r.PostFormValue("digesthour"))
+	if subscription.Frequency != "" {
+		// Digests are only emailed
+		subscription.Email = true
+	}
+	if subscription.Frequency != frequency || subscription.DigestHour != digestHour {
+		// Start again from the next digest period
+		subscription.LastDigestAt = ""
+	}
+}
+
+// validateSubscription will accept a subscription and Request and will return any validation error messages.
+func validateSubscription(env *models.Env, subscription models.Subscription, r *http.Request) []string {
+	var errorMessages []string
+	err := env.Validator.Struct(subscription)
+	if err != nil {
+		for _, e := range err.(validator.ValidationErrors) {
+			errorMessages = append(errorMessages, e.Translate(env.ValidatorTranslator))
+		}
+	}
+	if subscription.Frequency == "" && !subscription.Email && len(models.ParseChannelIDs(r.PostForm["channels"])) == 0 {
+		errorMessages = append(errorMessages, "Choose email or at least one notification channel to send alerts to")
+	}
+	return errorMessages
+}
+
+// subscriptionForm will accept a subscription and will return the form to add or edit it.
+func subscriptionForm(env *models.Env, subscription models.Subscription) (models.Form, error) {
+	form := models.Form{CancelLink: fmt.Sprintf("/users/%d/subscriptions", subscription.UserID)}
+	subjectOpts, err := subscriptionSubjectOptions(env, subscriptionSubjectKey(subscription))
+	if err != nil {
+		return form, err
+	}
+	channelOpts, err := notificationChannelOptions(env, "subscription", subscription.ID)
+	if err != nil {
+		return form, err
+	}
+	form.Fields = append(form.Fields, models.FormField{Name: "subject", Title: "Alerts For *", Type: "select", Options: subjectOpts})
+	form.Fields = append(form.Fields, models.FormField{Name: "frequency", Title: "Send", Type: "select", Options: []models.FormFieldOption{
+		{Value: "", Title: "Instantly", Selected: subscription.Frequency == ""},
+		{Value: "daily", Title: "Daily digest email", Selected: subscription.Frequency == "daily"},
+		{Value: "weekly", Title: "Weekly digest email (Mondays)", Selected: subscription.Frequency == "weekly"},
+	}})
+	form.Fields = append(form.Fields, models.FormField{Name: "digesthour", Title: "Send Digest At", Type: "select", Options: digestHourOptions(subscription.DigestHour)})
+	form.Fields = append(form.Fields, models.FormField{Name: "email", Title: "Send to the user's email address", Type: "checkbox", Value: "1", Checked: subscription.Email})
+	form.Fields = append(form.Fields, models.FormField{Name: "channels", Title: "Send to notification channels (not used for digests)", Type: "select", Multiple: true, Options: channelOpts})
+	form.SubmitName = "Save Changes"
+	return form, nil
+}
+
+// digestHourOptions will return the hours of the day as form field options with the hour provided selected.
+func digestHourOptions(digestHour int) []models.FormFieldOption {
+	var options []models.FormFieldOption
+	for hour := 0; hour < 24; hour++ {
+		options = append(options, models.FormFieldOption{Value: strconv.Itoa(hour), Title: fmt.Sprintf("%02d:00", hour), Selected: hour == digestHour})
+	}
+	return options
+}
+
+// subscriptionSubjectKey will accept a subscription and will return what it's subscribed to in the format
+// "all", "watchlist:1", "camera:1" or "rule:1".
+func subscriptionSubjectKey(subscription models.Subscription) string {
+	if subscription.SubjectType == "all" {
+		return "all"
+	}
+	return fmt.Sprintf("%s:%d", subscription.SubjectType, subscription.SubjectID)
+}
+
+// parseSubscriptionSubject will accept a subject in the format returned by subscriptionSubjectKey and will
+// return its type and ID.
+func parseSubscriptionSubject(key string) (string, int) {
+	subjectType, subjectID, _ := strings.Cut(key, ":")
+	id, _ := strconv.Atoi(subjectID)
+	if subjectType != "all" && id == 0 {
+		return "", 0
+	}
+	return subjectType, id
+}
+
+// subscriptionSubjectOptions will return everything that can be subscribed to as form field options with the
+// subject key provided selected.
+func subscriptionSubjectOptions(env *models.Env, selected string) ([]models.FormFieldOption, error) {
+	options := []models.FormFieldOption{{Value: "all", Title: "All alerts", Selected: selected == "all"}}
+	var watchlist models.Watchlist
+	resWatchlists, _, err := watchlist.Find(env, "AND", []models.WhereFields{}, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, resWatchlist := range *resWatchlists {
+		key := fmt.Sprintf("watchlist:%d", resWatchlist.ID)
+		options = append(options, models.FormFieldOption{Value: key, Title: "Watchlist: " + resWatchlist.Name, Selected: selected == key})
+	}
+	var camera models.Camera
+	resCameras, _, err := camera.Find(env, "AND", []models.WhereFields{}, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, resCamera := range *resCameras {
+		key := fmt.Sprintf("camera:%d", resCamera.ID)
+		options = append(options, models.FormFieldOption{Value: key, Title: "Camera: " + resCamera.DisplayName(), Selected: selected == key})
+	}
+	var rule models.Rule
+	resRules, _, err := rule.Find(env, "AND", []models.WhereFields{}, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, resRule := range *resRules {
+		key := fmt.Sprintf("rule:%d", resRule.ID)
+		options = append(options, models.FormFieldOption{Value: key, Title: "Rule: " + resRule.Name, Selected: selected == key})
+	}
+	return options, nil
+}
+
+// getSubscriptionSubjectNames will return a map of the names of everything that can be subscribed to by subject key.
+func getSubscriptionSubjectNames(env *models.Env) (map[string]string, error) {
+	names := map[string]string{}
+	options, err := subscriptionSubjectOptions(env, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, option := range options {
+		names[option.Value] = option.Title
+	}
+	return names, nil
+}
+
+// getNotificationChannelNames will return a map of notification channel names by ID.
+func getNotificationChannelNames(env *models.Env) (map[int]string, error) {
+	names := map[int]string{}
+	var notificationChannel models.NotificationChannel
+	resNotificationChannels, _, err := notificationChannel.Find(env, "AND", []models.WhereFields{}, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, resNotificationChannel := range *resNotificationChannels {
+		names[resNotificationChannel.ID] = resNotificationChannel.Name
+	}
+	return names, nil
+}
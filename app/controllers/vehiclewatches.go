@@ -0,0 +1,329 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/models"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/views"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func AdminVehicleWatches(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Vehicle Watches", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	list := models.List{}
+	var listRowFields []models.ListRowField
+
+	// Get page number
+	pageNumber := getPageNumber(r)
+
+	// Get all vehicle watches
+	var vehicleWatch models.VehicleWatch
+	resVehicleWatches, resCount, err := vehicleWatch.Find(env, "AND", []models.WhereFields{}, getPerPage(env), pageNumber)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	// Get schedule names
+	scheduleNames, err := getScheduleNames(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	if resCount > 0 {
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Name"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Vehicle"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Schedule"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "Actions"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: ""})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		for _, resVehicleWatch := range *resVehicleWatches {
+			var listRowFields []models.ListRowField
+			listRowFields = append(listRowFields, models.ListRowField{Value: resVehicleWatch.Name})
+			listRowFields = append(listRowFields, models.ListRowField{Value: resVehicleWatch.VehicleAttributes.String()})
+			listRowFields = append(listRowFields, models.ListRowField{Value: scheduleNames[resVehicleWatch.ScheduleID]})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-red", Link: fmt.Sprintf("/vehicles/%v/delete", resVehicleWatch.ID), Confirm: "Are you sure you want to delete this vehicle watch?", Icon: "delete", Value: "Delete"})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-yellow", Link: fmt.Sprintf("/vehicles/%v", resVehicleWatch.ID), Icon: "pencil", Value: "Edit"})
+			list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		}
+		// Get pagination
+		list.Pagination = getPagination(env, pageNumber, resCount)
+	} else {
+		listRowFields = []models.ListRowField{}
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "No vehicle watches found"})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	}
+	listRowFields = []models.ListRowField{}
+	listRowFields = append(listRowFields, models.ListRowField{Type: "link", Class: "btn btn-icon btn-primary", Link: "/vehicles/add", Icon: "plus", Value: "Add"})
+	list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+
+	page.View = list
+
+	views.Render(w, env, "list", http.StatusOK, page)
+}
+
+func AdminAddVehicleWatch(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Add Vehicle Watch", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	vehicleWatch := models.VehicleWatch{}
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Set values
+		setVehicleWatchValues(&vehicleWatch, r)
+		// Validate values
+		page.ErrorMessages = validateVehicleWatch(env, vehicleWatch)
+
+		// Check for errors
+		if len(page.ErrorMessages) == 0 {
+			// Add vehicle watch to database
+			_, err = vehicleWatch.Add(env)
+			if err == nil {
+				// Save camera scopes
+				err = saveCameraScopes(env, "vehiclewatch", vehicleWatch.ID, r)
+			}
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "vehiclewatch", fmt.Sprintf("Add vehicle watch %s", vehicleWatch.Name))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			// Redirect
+			http.Redirect(w, r, "/vehicles", 302)
+			return
+		}
+	}
+
+	form, err := vehicleWatchForm(env, vehicleWatch)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	page.View = form
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminEditVehicleWatch(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Edit Vehicle Watch", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	// Parse GET parameters ready for use
+	vars := mux.Vars(r)
+
+	vehicleWatchID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	vehicleWatch := models.VehicleWatch{ID: vehicleWatchID}
+	resVehicleWatch, err := vehicleWatch.Get(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "404", "Oops! Page Not Found", "404 Page Not Found")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	vehicleWatch = *resVehicleWatch
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Set values
+		setVehicleWatchValues(&vehicleWatch, r)
+		// Validate values
+		page.ErrorMessages = validateVehicleWatch(env, vehicleWatch)
+
+		// Check for errors
+		if len(page.ErrorMessages) == 0 {
+			// Update vehicle watch in database
+			_, err = vehicleWatch.Update(env)
+			if err == nil {
+				// Save camera scopes
+				err = saveCameraScopes(env, "vehiclewatch", vehicleWatch.ID, r)
+			}
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "vehiclewatch", fmt.Sprintf("Update vehicle watch id %d", vehicleWatch.ID))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			// Redirect
+			http.Redirect(w, r, "/vehicles", 302)
+			return
+		}
+	}
+
+	form, err := vehicleWatchForm(env, vehicleWatch)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	page.View = form
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminDeleteVehicleWatch(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		vehicleWatch := models.VehicleWatch{}
+
+		// Parse GET parameters ready for use
+		vars := mux.Vars(r)
+
+		// Set values
+		vehicleWatchID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Delete vehicle watch from database
+		vehicleWatch.ID = vehicleWatchID
+		_, err = vehicleWatch.Delete(env)
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Add admin log to database
+		err = adminLog(env, r, "vehiclewatch", fmt.Sprintf("Delete vehicle watch id %d", vehicleWatch.ID))
+		if err != nil {
+			env.Logger.Println(err)
+		}
+	}
+
+	// Redirect
+	http.Redirect(w, r, "/vehicles", 302)
+}
+
+// setVehicleWatchValues will accept a vehicle watch and Request and will set the vehicle watch's values from the posted form.
+func setVehicleWatchValues(vehicleWatch *models.VehicleWatch, r *http.Request) {
+	vehicleWatch.Name = r.PostFormValue("name")
+	vehicleWatch.WatchlistID, _ = strconv.Atoi(r.PostFormValue("watchlistid"))
+	vehicleWatch.ScheduleID, _ = strconv.Atoi(r.PostFormValue("scheduleid"))
+	setVehicleAttributes(&vehicleWatch.VehicleAttributes, r)
+}
+
+// validateVehicleWatch will accept a vehicle watch and will return any validation error messages.
+func validateVehicleWatch(env *models.Env, vehicleWatch models.VehicleWatch) []string {
+	var errorMessages []string
+	err := env.Validator.Struct(vehicleWatch)
+	if err != nil {
+		for _, e := range err.(validator.ValidationErrors) {
+			errorMessages = append(errorMessages, e.Translate(env.ValidatorTranslator))
+		}
+	}
+	if vehicleWatch.VehicleAttributes.IsEmpty() {
+		errorMessages = append(errorMessages, "At least one vehicle attribute is required")
+	}
+	return errorMessages
+}
+
+// vehicleWatchForm will accept a vehicle watch and will return the form to add or edit it.
+func vehicleWatchForm(env *models.Env, vehicleWatch models.VehicleWatch) (models.Form, error) {
+	form := models.Form{CancelLink: "/vehicles"}
+	watchlistOpts, err := watchlistOptions(env, vehicleWatch.WatchlistID)
+	if err != nil {
+		return form, err
+	}
+	scheduleOpts, err := scheduleOptions(env, vehicleWatch.ScheduleID)
+	if err != nil {
+		return form, err
+	}
+	cameraOpts, err := cameraScopeOptions(env, "vehiclewatch", vehicleWatch.ID)
+	if err != nil {
+		return form, err
+	}
+	form.Fields = append(form.Fields, models.FormField{Name: "name", Title: "Name *", Type: "text", Required: true, Placeholder: "Name", Value: vehicleWatch.Name})
+	form.Fields = append(form.Fields, vehicleAttributeFields(vehicleWatch.VehicleAttributes, "")...)
+	form.Fields = append(form.Fields, models.FormField{Name: "watchlistid", Title: "Watchlist", Type: "select", Options: watchlistOpts})
+	form.Fields = append(form.Fields, models.FormField{Name: "scheduleid", Title: "Alert Schedule", Type: "select", Options: scheduleOpts})
+	form.Fields = append(form.Fields, models.FormField{Name: "cameras", Title: "Alert Cameras (none selected alerts at all cameras)", Type: "select", Multiple: true, Options: cameraOpts})
+	form.SubmitName = "Save Changes"
+	return form, nil
+}
+
+// setVehicleAttributes will accept vehicle attributes and Request and will set the attributes from the posted form.
+func setVehicleAttributes(vehicle *models.VehicleAttributes, r *http.Request) {
+	vehicle.VehicleType = strings.TrimSpace(r.PostFormValue("vehicletype"))
+	vehicle.Colour = strings.TrimSpace(r.PostFormValue("colour"))
+	vehicle.Brand = strings.TrimSpace(r.PostFormValue("brand"))
+	vehicle.Model = strings.TrimSpace(r.PostFormValue("model"))
+}
+
+// vehicleAttributeFields will accept vehicle attributes and will return the form fields to edit them.
+func vehicleAttributeFields(vehicle models.VehicleAttributes, titlePrefix string) []models.FormField {
+	return []models.FormField{
+		{Name: "vehicletype", Title: titlePrefix + "Vehicle Type (as reported by the camera, e.g. van, SUV or truck)", Type: "text", Placeholder: "van", Value: vehicle.VehicleType},
+		{Name: "colour", Title: titlePrefix + "Colour (e.g. white, black or deepBlue)", Type: "text", Placeholder: "white", Value: vehicle.Colour},
+		{Name: "brand", Title: titlePrefix + "Brand (as reported by the camera)", Type: "text", Placeholder: "Brand", Value: vehicle.Brand},
+		{Name: "model", Title: titlePrefix + "Model (as reported by the camera)", Type: "text", Placeholder: "Model", Value: vehicle.Model},
+	}
+}
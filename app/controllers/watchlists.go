@@ -0,0 +1,346 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/models"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/views"
+	"net/http"
+	"strconv"
+)
+
+func AdminWatchlists(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Watchlists", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	list := models.List{}
+	var listRowFields []models.ListRowField
+
+	// Get page number
+	pageNumber := getPageNumber(r)
+
+	// Get all watchlists
+	var watchlist models.Watchlist
+	resWatchlists, resCount, err := watchlist.Find(env, "AND", []models.WhereFields{}, getPerPage(env), pageNumber)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	// Get schedule names
+	scheduleNames, err := getScheduleNames(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	if resCount > 0 {
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Name"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Schedule"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "Actions"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: ""})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		for _, resWatchlist := range *resWatchlists {
+			var listRowFields []models.ListRowField
+			listRowFields = append(listRowFields, models.ListRowField{Value: resWatchlist.Name})
+			listRowFields = append(listRowFields, models.ListRowField{Value: scheduleNames[resWatchlist.ScheduleID]})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-red", Link: fmt.Sprintf("/watchlists/%v/delete", resWatchlist.ID), Confirm: "Are you sure you want to delete this watchlist?", Icon: "delete", Value: "Delete"})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-yellow", Link: fmt.Sprintf("/watchlists/%v", resWatchlist.ID), Icon: "pencil", Value: "Edit"})
+			list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		}
+		// Get pagination
+		list.Pagination = getPagination(env, pageNumber, resCount)
+	} else {
+		listRowFields = []models.ListRowField{}
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "No watchlists found"})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	}
+	listRowFields = []models.ListRowField{}
+	listRowFields = append(listRowFields, models.ListRowField{Type: "link", Class: "btn btn-icon btn-primary", Link: "/watchlists/add", Icon: "plus", Value: "Add"})
+	list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+
+	page.View = list
+
+	views.Render(w, env, "list", http.StatusOK, page)
+}
+
+func AdminAddWatchlist(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Add Watchlist", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	watchlist := models.Watchlist{}
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Set values
+		setWatchlistValues(&watchlist, r)
+		// Validate values
+		page.ErrorMessages = validateWatchlist(env, watchlist)
+
+		// Check for errors
+		if len(page.ErrorMessages) == 0 {
+			// Add watchlist to database
+			_, err = watchlist.Add(env)
+			if err == nil {
+				// Save camera scopes
+				err = saveCameraScopes(env, "watchlist", watchlist.ID, r)
+			}
+			if err == nil {
+				// Save notification channels
+				err = saveNotificationTargets(env, "watchlist", watchlist.ID, r)
+			}
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "watchlist", fmt.Sprintf("Add watchlist %s", watchlist.Name))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			// Redirect
+			http.Redirect(w, r, "/watchlists", 302)
+			return
+		}
+	}
+
+	form, err := watchlistForm(env, watchlist)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	page.View = form
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminEditWatchlist(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Edit Watchlist", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	// Parse GET parameters ready for use
+	vars := mux.Vars(r)
+
+	watchlistID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	watchlist := models.Watchlist{ID: watchlistID}
+	resWatchlist, err := watchlist.Get(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "404", "Oops! Page Not Found", "404 Page Not Found")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	watchlist = *resWatchlist
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Set values
+		setWatchlistValues(&watchlist, r)
+		// Validate values
+		page.ErrorMessages = validateWatchlist(env, watchlist)
+
+		// Check for errors
+		if len(page.ErrorMessages) == 0 {
+			// Update watchlist in database
+			_, err = watchlist.Update(env)
+			if err == nil {
+				// Save camera scopes
+				err = saveCameraScopes(env, "watchlist", watchlist.ID, r)
+			}
+			if err == nil {
+				// Save notification channels
+				err = saveNotificationTargets(env, "watchlist", watchlist.ID, r)
+			}
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "watchlist", fmt.Sprintf("Update watchlist id %d", watchlist.ID))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			// Redirect
+			http.Redirect(w, r, "/watchlists", 302)
+			return
+		}
+	}
+
+	form, err := watchlistForm(env, watchlist)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+
+	page.View = form
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminDeleteWatchlist(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		watchlist := models.Watchlist{}
+
+		// Parse GET parameters ready for use
+		vars := mux.Vars(r)
+
+		// Set values
+		watchlistID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Delete watchlist from database
+		watchlist.ID = watchlistID
+		_, err = watchlist.Delete(env)
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Add admin log to database
+		err = adminLog(env, r, "watchlist", fmt.Sprintf("Delete watchlist id %d", watchlist.ID))
+		if err != nil {
+			env.Logger.Println(err)
+		}
+	}
+
+	// Redirect
+	http.Redirect(w, r, "/watchlists", 302)
+}
+
+// setWatchlistValues will accept a watchlist and Request and will set the watchlist's values from the posted form.
+func setWatchlistValues(watchlist *models.Watchlist, r *http.Request) {
+	watchlist.Name = r.PostFormValue("name")
+	watchlist.ScheduleID, _ = strconv.Atoi(r.PostFormValue("scheduleid"))
+	watchlist.MinConfidence, _ = strconv.Atoi(r.PostFormValue("minconfidence"))
+	watchlist.AlertDirection = r.PostFormValue("alertdirection")
+	watchlist.DwellMinutes, _ = strconv.Atoi(r.PostFormValue("dwellminutes"))
+	watchlist.EscalationPolicyID, _ = strconv.Atoi(r.PostFormValue("escalationpolicyid"))
+	watchlist.Critical = r.PostFormValue("critical") != ""
+	watchlist.TemplateID, _ = strconv.Atoi(r.PostFormValue("templateid"))
+	watchlist.CooldownMinutes, _ = strconv.Atoi(r.PostFormValue("cooldownminutes"))
+}
+
+// validateWatchlist will accept a watchlist and will return any validation error messages.
+func validateWatchlist(env *models.Env, watchlist models.Watchlist) []string {
+	var errorMessages []string
+	err := env.Validator.Struct(watchlist)
+	if err != nil {
+		for _, e := range err.(validator.ValidationErrors) {
+			errorMessages = append(errorMessages, e.Translate(env.ValidatorTranslator))
+		}
+	}
+	return errorMessages
+}
+
+// watchlistForm will accept a watchlist and will return the form to add or edit it.
+func watchlistForm(env *models.Env, watchlist models.Watchlist) (models.Form, error) {
+	form := models.Form{CancelLink: "/watchlists"}
+	scheduleOpts, err := scheduleOptions(env, watchlist.ScheduleID)
+	if err != nil {
+		return form, err
+	}
+	cameraOpts, err := cameraScopeOptions(env, "watchlist", watchlist.ID)
+	if err != nil {
+		return form, err
+	}
+	channelOpts, err := notificationChannelOptions(env, "watchlist", watchlist.ID)
+	if err != nil {
+		return form, err
+	}
+	escalationPolicyOpts, err := escalationPolicyOptions(env, watchlist.EscalationPolicyID)
+	if err != nil {
+		return form, err
+	}
+	messageTemplateOpts, err := messageTemplateOptions(env, watchlist.TemplateID, "Default")
+	if err != nil {
+		return form, err
+	}
+	form.Fields = append(form.Fields, models.FormField{Name: "name", Title: "Name *", Type: "text", Required: true, Placeholder: "Name", Value: watchlist.Name})
+	form.Fields = append(form.Fields, models.FormField{Name: "scheduleid", Title: "Alert Schedule", Type: "select", Options: scheduleOpts})
+	form.Fields = append(form.Fields, models.FormField{Name: "cameras", Title: "Alert Cameras (none selected alerts at all cameras)", Type: "select", Multiple: true, Options: cameraOpts})
+	form.Fields = append(form.Fields, models.FormField{Name: "minconfidence", Title: "Minimum Confidence (0-100, 0 uses the camera's minimum confidence)", Type: "number", Required: false, Placeholder: "0", Value: strconv.Itoa(watchlist.MinConfidence)})
+	form.Fields = append(form.Fields, models.FormField{Name: "alertdirection", Title: "Alert When", Type: "select", Options: directionOptions(watchlist.AlertDirection, "Entering or leaving")})
+	form.Fields = append(form.Fields, models.FormField{Name: "dwellminutes", Title: "Alert When On Site Longer Than (minutes, 0 uses the global dwell time)", Type: "number", Required: false, Placeholder: "0", Value: strconv.Itoa(watchlist.DwellMinutes)})
+	form.Fields = append(form.Fields, models.FormField{Name: "cooldownminutes", Title: "Alert Cooldown (minutes to suppress repeat alerts for the same number plate, 0 uses the camera's cooldown)", Type: "number", Required: false, Placeholder: "0", Value: strconv.Itoa(watchlist.CooldownMinutes)})
+	form.Fields = append(form.Fields, models.FormField{Name: "channels", Title: "Notification Channels (sent to as well as subscribed users)", Type: "select", Multiple: true, Options: channelOpts})
+	form.Fields = append(form.Fields, models.FormField{Name: "templateid", Title: "Message Template (used for emails and channels without their own template)", Type: "select", Options: messageTemplateOpts})
+	form.Fields = append(form.Fields, models.FormField{Name: "escalationpolicyid", Title: "Escalate Unacknowledged Alerts Using", Type: "select", Options: escalationPolicyOpts})
+	form.Fields = append(form.Fields, models.FormField{Name: "critical", Title: "Critical (alerts are sent during users' quiet hours and do not disturb)", Type: "checkbox", Value: "1", Checked: watchlist.Critical})
+	form.SubmitName = "Save Changes"
+	return form, nil
+}
+
+// watchlistOptions will return the watchlists as form field options with the watchlist ID provided selected.
+func watchlistOptions(env *models.Env, watchlistID int) ([]models.FormFieldOption, error) {
+	options := []models.FormFieldOption{{Value: "0", Title: "None", Selected: watchlistID == 0}}
+	var watchlist models.Watchlist
+	resWatchlists, _, err := watchlist.Find(env, "AND", []models.WhereFields{}, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, resWatchlist := range *resWatchlists {
+		options = append(options, models.FormFieldOption{Value: strconv.Itoa(resWatchlist.ID), Title: resWatchlist.Name, Selected: resWatchlist.ID == watchlistID})
+	}
+	return options, nil
+}
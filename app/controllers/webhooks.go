@@ -0,0 +1,374 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/models"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/views"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// webhookEventOrder is the order webhook events are displayed in
+var webhookEventOrder = []string{"detection", "alert", "camera"}
+
+func AdminWebhooks(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Webhooks", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	list := models.List{}
+	var listRowFields []models.ListRowField
+
+	// Get page number
+	pageNumber := getPageNumber(r)
+
+	// Get all webhooks
+	var webhook models.Webhook
+	resWebhooks, resCount, err := webhook.Find(env, "AND", []models.WhereFields{}, getPerPage(env), pageNumber)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	if resCount > 0 {
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Name"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "URL"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Events"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Enabled"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "Actions"})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: ""})
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: ""})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		for _, resWebhook := range *resWebhooks {
+			var listRowFields []models.ListRowField
+			enabled := "No"
+			if resWebhook.Enabled {
+				enabled = "Yes"
+			}
+			var events []string
+			for _, event := range resWebhook.EventList() {
+				events = append(events, models.WebhookEvents[event])
+			}
+			listRowFields = append(listRowFields, models.ListRowField{Value: resWebhook.Name})
+			listRowFields = append(listRowFields, models.ListRowField{Value: resWebhook.URL})
+			listRowFields = append(listRowFields, models.ListRowField{Value: strings.Join(events, ", ")})
+			listRowFields = append(listRowFields, models.ListRowField{Value: enabled})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-red", Link: fmt.Sprintf("/webhooks/%v/delete", resWebhook.ID), Confirm: "Are you sure you want to delete this webhook?", Icon: "delete", Value: "Delete"})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon", Link: fmt.Sprintf("/webhooks/%v/deliveries", resWebhook.ID), Icon: "history", Value: "Deliveries"})
+			listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto field-padding-right", Type: "link", Class: "btn btn-icon btn-yellow", Link: fmt.Sprintf("/webhooks/%v", resWebhook.ID), Icon: "pencil", Value: "Edit"})
+			list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		}
+		// Get pagination
+		list.Pagination = getPagination(env, pageNumber, resCount)
+	} else {
+		listRowFields = []models.ListRowField{}
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "No webhooks found"})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	}
+	listRowFields = []models.ListRowField{}
+	listRowFields = append(listRowFields, models.ListRowField{Type: "link", Class: "btn btn-icon btn-primary", Link: "/webhooks/add", Icon: "plus", Value: "Add"})
+	list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+
+	page.View = list
+
+	views.Render(w, env, "list", http.StatusOK, page)
+}
+
+func AdminAddWebhook(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Add Webhook", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	webhook := models.Webhook{Events: "alert", Enabled: true}
+
+	// Generate a secret to sign the webhook's requests with
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	webhook.Secret = hex.EncodeToString(secret)
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Set values
+		setWebhookValues(&webhook, r)
+		// Validate values
+		page.ErrorMessages = validateWebhook(env, webhook)
+
+		// Check for errors
+		if len(page.ErrorMessages) == 0 {
+			// Add webhook to database
+			_, err = webhook.Add(env)
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "webhook", fmt.Sprintf("Add webhook %s", webhook.Name))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			// Redirect
+			http.Redirect(w, r, "/webhooks", 302)
+			return
+		}
+	}
+
+	page.View = webhookForm(webhook)
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminEditWebhook(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	var page = models.Page{Title: "Edit Webhook", RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	webhook, ok := getWebhook(env, w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		// Parse form data ready for use
+		err := r.ParseForm()
+		if err != nil {
+			err := displayError(env, w, r, "400", "Oops! Please try again later", "400 Bad Request")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Set values
+		setWebhookValues(&webhook, r)
+		// Validate values
+		page.ErrorMessages = validateWebhook(env, webhook)
+
+		// Check for errors
+		if len(page.ErrorMessages) == 0 {
+			// Update webhook in database
+			_, err = webhook.Update(env)
+			if err != nil {
+				env.Logger.Println(err)
+				err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+				if err != nil {
+					env.Logger.Println(err)
+				}
+				return
+			}
+			// Add admin log to database
+			err = adminLog(env, r, "webhook", fmt.Sprintf("Update webhook id %d", webhook.ID))
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			// Redirect
+			http.Redirect(w, r, "/webhooks", 302)
+			return
+		}
+	}
+
+	page.View = webhookForm(webhook)
+
+	views.Render(w, env, "form", http.StatusOK, page)
+}
+
+func AdminDeleteWebhook(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		webhook := models.Webhook{}
+
+		// Parse GET parameters ready for use
+		vars := mux.Vars(r)
+
+		// Set values
+		webhookID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Delete webhook from database
+		webhook.ID = webhookID
+		_, err = webhook.Delete(env)
+		if err != nil {
+			env.Logger.Println(err)
+			err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			return
+		}
+
+		// Add admin log to database
+		err = adminLog(env, r, "webhook", fmt.Sprintf("Delete webhook id %d", webhook.ID))
+		if err != nil {
+			env.Logger.Println(err)
+		}
+	}
+
+	// Redirect
+	http.Redirect(w, r, "/webhooks", 302)
+}
+
+func AdminWebhookDeliveries(env *models.Env, w http.ResponseWriter, r *http.Request) {
+	webhook, ok := getWebhook(env, w, r)
+	if !ok {
+		return
+	}
+
+	var page = models.Page{Title: fmt.Sprintf("Webhook Deliveries: %s", webhook.Name), RequestURL: r.URL.String(), Theme: getTheme(r)}
+
+	list := models.List{}
+	var listRowFields []models.ListRowField
+
+	// Get page number
+	pageNumber := getPageNumber(r)
+
+	// Get the webhook's deliveries
+	var webhookDelivery models.WebhookDelivery
+	resWebhookDeliveries, resCount, err := webhookDelivery.Find(env, "AND", []models.WhereFields{{"webhook_id", "=", webhook.ID}}, getPerPage(env), pageNumber)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return
+	}
+	if resCount > 0 {
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Time"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Event"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Status"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Attempts"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Response"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Duration"})
+		listRowFields = append(listRowFields, models.ListRowField{Value: "Error"})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		for _, resWebhookDelivery := range *resWebhookDeliveries {
+			var listRowFields []models.ListRowField
+			response := ""
+			if resWebhookDelivery.StatusCode > 0 {
+				response = strconv.Itoa(resWebhookDelivery.StatusCode)
+			}
+			listRowFields = append(listRowFields, models.ListRowField{Value: resWebhookDelivery.CreatedAt})
+			listRowFields = append(listRowFields, models.ListRowField{Value: models.WebhookEvents[resWebhookDelivery.Event]})
+			listRowFields = append(listRowFields, models.ListRowField{Value: resWebhookDelivery.Status})
+			listRowFields = append(listRowFields, models.ListRowField{Value: strconv.Itoa(resWebhookDelivery.Attempts)})
+			listRowFields = append(listRowFields, models.ListRowField{Value: response})
+			listRowFields = append(listRowFields, models.ListRowField{Value: fmt.Sprintf("%dms", resWebhookDelivery.DurationMS)})
+			listRowFields = append(listRowFields, models.ListRowField{Value: resWebhookDelivery.Error})
+			list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+		}
+		// Get pagination
+		list.Pagination = getPagination(env, pageNumber, resCount)
+	} else {
+		listRowFields = []models.ListRowField{}
+		listRowFields = append(listRowFields, models.ListRowField{FieldClass: " field-width-auto", Value: "No deliveries found"})
+		list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+	}
+	listRowFields = []models.ListRowField{}
+	listRowFields = append(listRowFields, models.ListRowField{Type: "link", Class: "btn btn-icon", Link: "/webhooks", Icon: "arrow-left", Value: "Back"})
+	list.Rows = append(list.Rows, models.ListRow{Fields: listRowFields})
+
+	page.View = list
+
+	views.Render(w, env, "list", http.StatusOK, page)
+}
+
+// getWebhook will get the webhook from the request's ID, displaying an error and returning false if it isn't found.
+func getWebhook(env *models.Env, w http.ResponseWriter, r *http.Request) (models.Webhook, bool) {
+	// Parse GET parameters ready for use
+	vars := mux.Vars(r)
+
+	webhookID, err := strconv.Atoi(fmt.Sprint(vars["id"]))
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "500", "Oops! Please try again later", "Internal Server Error")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return models.Webhook{}, false
+	}
+
+	webhook := models.Webhook{ID: webhookID}
+	resWebhook, err := webhook.Get(env)
+	if err != nil {
+		env.Logger.Println(err)
+		err := displayError(env, w, r, "404", "Oops! Page Not Found", "404 Page Not Found")
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		return models.Webhook{}, false
+	}
+	return *resWebhook, true
+}
+
+// setWebhookValues will accept a webhook and Request and will set the webhook's values from the posted form.
+func setWebhookValues(webhook *models.Webhook, r *http.Request) {
+	webhook.Name = r.PostFormValue("name")
+	webhook.URL = strings.TrimSpace(r.PostFormValue("url"))
+	webhook.Secret = strings.TrimSpace(r.PostFormValue("secret"))
+	var events []string
+	for _, event := range r.PostForm["events"] {
+		if _, ok := models.WebhookEvents[event]; ok {
+			events = append(events, event)
+		}
+	}
+	webhook.Events = strings.Join(events, ",")
+	webhook.Enabled = r.PostFormValue("enabled") == "1"
+}
+
+// validateWebhook will accept a webhook and will return any validation error messages.
+func validateWebhook(env *models.Env, webhook models.Webhook) []string {
+	var errorMessages []string
+	err := env.Validator.Struct(webhook)
+	if err != nil {
+		for _, e := range err.(validator.ValidationErrors) {
+			errorMessages = append(errorMessages, e.Translate(env.ValidatorTranslator))
+		}
+	}
+	if webhook.URL != "" && !strings.HasPrefix(webhook.URL, "http://") && !strings.HasPrefix(webhook.URL, "https://") {
+		errorMessages = append(errorMessages, "URL must start with http:// or https://")
+	}
+	return errorMessages
+}
+
+// webhookForm will accept a webhook and will return the form to add or edit it.
+func webhookForm(webhook models.Webhook) models.Form {
+	var eventOptions []models.FormFieldOption
+	for _, event := range webhookEventOrder {
+		eventOptions = append(eventOptions, models.FormFieldOption{Value: event, Title: models.WebhookEvents[event], Selected: webhook.HasEvent(event)})
+	}
+	form := models.Form{CancelLink: "/webhooks"}
+	form.Fields = append(form.Fields, models.FormField{Name: "name", Title: "Name *", Type: "text", Required: true, Placeholder: "Name", Value: webhook.Name})
+	form.Fields = append(form.Fields, models.FormField{Name: "url", Title: "URL *", Type: "text", Required: true, Placeholder: "https://example.com/webhook", Value: webhook.URL})
+	form.Fields = append(form.Fields, models.FormField{Name: "secret", Title: fmt.Sprintf("Secret * (used to sign requests in the %s header)", models.WebhookSignatureHeader), Type: "text", Required: true, Placeholder: "Secret", Value: webhook.Secret})
+	form.Fields = append(form.Fields, models.FormField{Name: "events", Title: "Events *", Type: "select", Multiple: true, Options: eventOptions})
+	form.Fields = append(form.Fields, models.FormField{Name: "enabled", Title: "Enabled", Type: "checkbox", Value: "1", Checked: webhook.Enabled})
+	form.SubmitName = "Save Changes"
+	return form
+}
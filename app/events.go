@@ -0,0 +1,572 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/models"
+	"strings"
+	"time"
+)
+
+// matchedRule is an alert rule that matched a detection, with the explanation of why it matched.
+type matchedRule struct {
+	Rule        models.Rule
+	Explanation []string
+}
+
+// processEvent parses an event received from a camera, stores it as a detection and sends an alert
+// if the number plate read is in the number plate database and passes all of its alert checks, if
+// the vehicle matches any vehicle watches or if any alert rules with a notify action match.
+func processEvent(cam models.Camera, msg []byte, env *models.Env) {
+	event, err := models.ParseEvent(msg)
+	if err != nil {
+		env.Logger.Printf("[%s] Error parsing event: %v\n", cam.IPAddress, err)
+		return
+	}
+	if !event.IsANPR() {
+		return
+	}
+
+	detection := models.Detection{
+		CameraID:          cam.ID,
+		Plate:             event.Plate(),
+		Confidence:        event.ANPR.ConfidenceLevel,
+		Direction:         cam.Direction(event.ANPR.Direction),
+		EventTime:         event.Time().Format(models.DateTimeFormat),
+		VehicleAttributes: event.Vehicle(),
+	}
+	var alerts []models.Notification
+
+	// Check if the number plate exists in the number plate database
+	numberPlate, watchlist, err := findNumberPlate(env, event.Plate())
+	if err != nil {
+		env.Logger.Println(err)
+		return
+	}
+	if numberPlate != nil {
+		detection.NumberPlateID = numberPlate.ID
+	}
+
+	// Flag reads below the camera or watchlist confidence threshold
+	detection.LowConfidence = detection.Confidence < minConfidence(cam, watchlist)
+	if detection.LowConfidence {
+		env.Logger.Printf("[%s] Number plate %s read with low confidence %d\n", cam.IPAddress, detection.Plate, detection.Confidence)
+	} else if numberPlate != nil {
+		alert, err := shouldAlert(env, cam, *numberPlate, watchlist, detection, event.Time())
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		if alert {
+			channelIDs, err := watchlistChannels(env, watchlist)
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			alerts = append(alerts, models.Notification{Title: "ANPR Alert", Message: numberPlateAlertReason(cam, *numberPlate, detection.VehicleAttributes), ChannelIDs: channelIDs, WatchlistID: numberPlate.WatchlistID})
+		}
+	}
+
+	// Check if the vehicle matches any vehicle watches
+	vehicleAlerts, err := matchVehicleWatches(env, cam, detection, event.Time())
+	if err != nil {
+		env.Logger.Println(err)
+	}
+	alerts = append(alerts, vehicleAlerts...)
+
+	// Check if the vehicle is tailgating an authorised vehicle, ignoring low confidence reads as a
+	// misread of an authorised plate won't be found in the number plate database
+	if cam.TailgateSeconds > 0 && !detection.LowConfidence {
+		tailgateAlert, err := checkTailgating(env, cam, detection, numberPlate, event.Time())
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		if tailgateAlert != "" {
+			detection.AddTag("tailgating")
+			alerts = append(alerts, models.Notification{Title: "ANPR Alert", Message: tailgateAlert})
+		}
+	}
+
+	// Check if the number plate is loitering around the camera or its camera group
+	if !detection.LowConfidence {
+		loiterAlerts, err := checkLoitering(env, cam, detection, event.Time())
+		if err != nil {
+			env.Logger.Println(err)
+		}
+		for _, loiterAlert := range loiterAlerts {
+			alerts = append(alerts, models.Notification{Title: "ANPR Alert", Message: loiterAlert})
+		}
+	}
+
+	// Evaluate the alert rules
+	matchedRules, err := matchRules(env, models.RuleInput{
+		Camera:      cam,
+		Detection:   detection,
+		NumberPlate: numberPlate,
+		Time:        event.Time(),
+	})
+	if err != nil {
+		env.Logger.Println(err)
+	}
+	for _, m := range matchedRules {
+		detection.AddTag(m.Rule.Tag)
+		if m.Rule.Notify {
+			var notificationTarget models.NotificationTarget
+			channelIDs, err := notificationTarget.Find(env, "rule", m.Rule.ID)
+			if err != nil {
+				env.Logger.Println(err)
+			}
+			alerts = append(alerts, models.Notification{Title: "ANPR Alert", Message: fmt.Sprintf("Rule %s matched number plate %s at %s. %s.", m.Rule.Name, detection.Plate, cam.DisplayName(), strings.Join(m.Explanation, ". ")), ChannelIDs: channelIDs, RuleID: m.Rule.ID})
+		}
+	}
+	detection.Alerted = len(alerts) > 0
+
+	// Store the detection
+	_, err = detection.Add(env)
+	if err != nil {
+		env.Logger.Println(err)
+	} else {
+		if err := trackPresence(env, detection); err != nil {
+			env.Logger.Println(err)
+		}
+		detectionData := models.WebhookDetection{
+			Detection: detection,
+			Camera:    webhookCamera(cam),
+			Link:      externalLink(env, fmt.Sprintf("/detections/%d", detection.ID)),
+		}
+		models.TriggerWebhooks(env, "detection", detectionData)
+		env.MQTT.PublishDetection(detectionData)
+	}
+
+	// Record the rule hits and trigger any camera outputs
+	for _, m := range matchedRules {
+		ruleHit := models.RuleHit{RuleID: m.Rule.ID, DetectionID: detection.ID, Explanation: strings.Join(m.Explanation, "\n")}
+		if _, err := ruleHit.Add(env); err != nil {
+			env.Logger.Println(err)
+		}
+		if m.Rule.OutputID > 0 {
+			go func(outputID int) {
+				if err := cam.TriggerOutput(outputID); err != nil {
+					env.Logger.Printf("[%s] Error triggering output: %v\n", cam.IPAddress, err)
+				}
+			}(m.Rule.OutputID)
+		}
+	}
+
+	if len(alerts) == 0 {
+		return
+	}
+
+	// Send the alerts off the camera's read loop as getting the picture can be slow
+	go func() {
+		// Add the detection's details and a picture of the read to the alerts
+		snapshot, err := eventPicture(cam, *event)
+		if err != nil {
+			env.Logger.Printf("[%s] Error getting picture: %v\n", cam.IPAddress, err)
+		}
+		for _, alert := range alerts {
+			alert.Title = fmt.Sprintf("ANPR Alert: %s", detection.Plate)
+			alert.CameraID = cam.ID
+			alert.Plate = detection.Plate
+			alert.NumberPlateID = detection.NumberPlateID
+			alert.DetectionID = detection.ID
+			addDetectionFields(&alert, cam, detection, numberPlate, watchlist)
+			if detection.ID != 0 {
+				alert.Link = externalLink(env, fmt.Sprintf("/detections/%d", detection.ID))
+			}
+			alert.Snapshot = snapshot
+			sendAlert(alert, env)
+		}
+	}()
+}
+
+// eventPicture gets the event's own picture of the read if the camera provided one, falling back to
+// a snapshot from the camera.
+func eventPicture(cam models.Camera, event models.Event) ([]byte, error) {
+	if pictureURL := event.PictureURL(); pictureURL != "" {
+		if picture, err := cam.Picture(pictureURL); err == nil {
+			return picture, nil
+		}
+	}
+	return cam.Snapshot(event.ChannelID)
+}
+
+// addDetectionFields adds the details of a detection to an alert.
+func addDetectionFields(alert *models.Notification, cam models.Camera, detection models.Detection, numberPlate *models.NumberPlate, watchlist *models.Watchlist) {
+	alert.AddField("Number Plate", detection.Plate)
+	if numberPlate != nil {
+		alert.AddField("Registered To", numberPlate.Name)
+	}
+	if watchlist != nil {
+		alert.AddField("Watchlist", watchlist.Name)
+	}
+	alert.AddField("Camera", cam.DisplayName())
+	switch detection.Direction {
+	case "entry":
+		alert.AddField("Direction", "Entry")
+	case "exit":
+		alert.AddField("Direction", "Exit")
+	}
+	alert.AddField("Time", detection.EventTime)
+	alert.AddField("Confidence", fmt.Sprintf("%d%%", detection.Confidence))
+	alert.AddField("Vehicle", detection.VehicleAttributes.String())
+}
+
+// findNumberPlate finds a number plate and its watchlist in the number plate database, returning nil
+// if it doesn't exist.
+func findNumberPlate(env *models.Env, plate string) (*models.NumberPlate, *models.Watchlist, error) {
+	var numberPlate models.NumberPlate
+	resNumberPlates, resCount, err := numberPlate.FindByPlate(env, plate)
+	if err != nil || resCount == 0 {
+		return nil, nil, err
+	}
+	numberPlate = (*resNumberPlates)[0]
+	watchlist, err := findWatchlist(env, numberPlate.WatchlistID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &numberPlate, watchlist, nil
+}
+
+// findWatchlist finds a watchlist by ID, returning nil if the ID is 0 or it doesn't exist.
+func findWatchlist(env *models.Env, watchlistID int) (*models.Watchlist, error) {
+	if watchlistID == 0 {
+		return nil, nil
+	}
+	watchlist := models.Watchlist{ID: watchlistID}
+	resWatchlist, err := watchlist.Get(env)
+	if err != nil && !errors.Is(err, env.DB.ErrRecordNotFound) {
+		return nil, err
+	}
+	return resWatchlist, nil
+}
+
+// watchlistChannels returns the notification channels the watchlist's alerts are sent to, returning
+// none to send to all notification channels if there isn't a watchlist or it doesn't choose any.
+func watchlistChannels(env *models.Env, watchlist *models.Watchlist) ([]int, error) {
+	if watchlist == nil {
+		return nil, nil
+	}
+	var notificationTarget models.NotificationTarget
+	return notificationTarget.Find(env, "watchlist", watchlist.ID)
+}
+
+// minConfidence returns the minimum confidence for a read to alert, using the watchlist's threshold
+// over the camera's if it has one.
+func minConfidence(cam models.Camera, watchlist *models.Watchlist) int {
+	if watchlist != nil && watchlist.MinConfidence > 0 {
+		return watchlist.MinConfidence
+	}
+	return cam.MinConfidence
+}
+
+// shouldAlert checks a number plate read at the time provided is valid, matches its registered
+// vehicle attribute rules and alert direction, is in its camera scopes and is inside its schedules.
+func shouldAlert(env *models.Env, cam models.Camera, numberPlate models.NumberPlate, watchlist *models.Watchlist, detection models.Detection, t time.Time) (bool, error) {
+	// Check the number plate is valid at the time of the event
+	if !numberPlate.ValidAt(t) {
+		env.Logger.Printf("[%s] Number plate %s read outside of its validity window\n", cam.IPAddress, numberPlate.Plate)
+		return false, nil
+	}
+
+	// Check the vehicle differs from the registered vehicle if only alerting on mismatches
+	if numberPlate.AlertOnMismatch && !numberPlate.VehicleAttributes.Differs(detection.VehicleAttributes) {
+		env.Logger.Printf("[%s] Number plate %s read on its registered vehicle\n", cam.IPAddress, numberPlate.Plate)
+		return false, nil
+	}
+
+	// Check the vehicle is travelling in the number plate and watchlist alert directions
+	if !directionAllowed(detection.Direction, numberPlate.AlertDirection, watchlist) {
+		env.Logger.Printf("[%s] Number plate %s read outside of its alert direction (%s)\n", cam.IPAddress, numberPlate.Plate, detection.Direction)
+		return false, nil
+	}
+
+	// Check the camera is in the number plate and watchlist camera scopes
+	inScope, err := cameraInScope(env, cam, "numberplate", numberPlate.ID, watchlist)
+	if err != nil {
+		return false, err
+	}
+	if !inScope {
+		env.Logger.Printf("[%s] Number plate %s read at a camera outside of its alert cameras\n", cam.IPAddress, numberPlate.Plate)
+		return false, nil
+	}
+
+	// Check the number plate, watchlist and camera schedules are active
+	active, err := scheduleActive(env, t, numberPlate.ScheduleID, cam, watchlist)
+	if err != nil {
+		return false, err
+	}
+	if !active {
+		env.Logger.Printf("[%s] Number plate %s read outside of its alert schedule\n", cam.IPAddress, numberPlate.Plate)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// matchVehicleWatches checks a detection against the vehicle watches, returning an alert for each
+// vehicle watch it matches that passes its confidence, camera scope and schedule checks.
+func matchVehicleWatches(env *models.Env, cam models.Camera, detection models.Detection, t time.Time) ([]models.Notification, error) {
+	if detection.VehicleAttributes.IsEmpty() {
+		return nil, nil
+	}
+	var vehicleWatch models.VehicleWatch
+	resVehicleWatches, _, err := vehicleWatch.Find(env, "AND", []models.WhereFields{}, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	var alerts []models.Notification
+	for _, resVehicleWatch := range *resVehicleWatches {
+		if !resVehicleWatch.VehicleAttributes.Matches(detection.VehicleAttributes) {
+			continue
+		}
+		watchlist, err := findWatchlist(env, resVehicleWatch.WatchlistID)
+		if err != nil {
+			return alerts, err
+		}
+
+		// Check the read is above the camera or watchlist confidence threshold
+		if detection.Confidence < minConfidence(cam, watchlist) {
+			env.Logger.Printf("[%s] Vehicle watch %s matched with low confidence %d\n", cam.IPAddress, resVehicleWatch.Name, detection.Confidence)
+			continue
+		}
+
+		// Check the vehicle is travelling in the watchlist alert direction
+		if !directionAllowed(detection.Direction, "", watchlist) {
+			env.Logger.Printf("[%s] Vehicle watch %s matched outside of its alert direction (%s)\n", cam.IPAddress, resVehicleWatch.Name, detection.Direction)
+			continue
+		}
+
+		// Check the camera is in the vehicle watch and watchlist camera scopes
+		inScope, err := cameraInScope(env, cam, "vehiclewatch", resVehicleWatch.ID, watchlist)
+		if err != nil {
+			return alerts, err
+		}
+		if !inScope {
+			env.Logger.Printf("[%s] Vehicle watch %s matched at a camera outside of its alert cameras\n", cam.IPAddress, resVehicleWatch.Name)
+			continue
+		}
+
+		// Check the vehicle watch, watchlist and camera schedules are active
+		active, err := scheduleActive(env, t, resVehicleWatch.ScheduleID, cam, watchlist)
+		if err != nil {
+			return alerts, err
+		}
+		if !active {
+			env.Logger.Printf("[%s] Vehicle watch %s matched outside of its alert schedule\n", cam.IPAddress, resVehicleWatch.Name)
+			continue
+		}
+
+		channelIDs, err := watchlistChannels(env, watchlist)
+		if err != nil {
+			return alerts, err
+		}
+		alerts = append(alerts, models.Notification{Title: "ANPR Alert", Message: fmt.Sprintf("Vehicle watch %s matched a %s with number plate %s at %s.", resVehicleWatch.Name, detection.VehicleAttributes, detection.Plate, cam.DisplayName()), ChannelIDs: channelIDs, WatchlistID: resVehicleWatch.WatchlistID})
+	}
+	return alerts, nil
+}
+
+// checkTailgating checks if an unauthorised vehicle was read within the camera's tailgate interval of
+// an authorised vehicle, returning an alert reason if it was. Authorised vehicles are number plates
+// in the number plate database that are valid at the time of the read.
+func checkTailgating(env *models.Env, cam models.Camera, detection models.Detection, numberPlate *models.NumberPlate, t time.Time) (string, error) {
+	if numberPlate != nil && numberPlate.ValidAt(t) {
+		return "", nil
+	}
+
+	// Get the previous read at the camera inside the tailgate interval
+	since := t.Add(-time.Duration(cam.TailgateSeconds) * time.Second)
+	var previous models.Detection
+	resDetections, _, err := previous.Find(env, "AND", []models.WhereFields{{"camera_id", "=", cam.ID}, {"event_time", ">=", since.Format(models.DateTimeFormat)}}, 1, 1)
+	if err != nil || len(*resDetections) == 0 {
+		return "", err
+	}
+	previous = (*resDetections)[0]
+	if previous.Plate == detection.Plate || previous.NumberPlateID == 0 {
+		return "", nil
+	}
+
+	// Check the previous vehicle was authorised
+	previousNumberPlate := models.NumberPlate{ID: previous.NumberPlateID}
+	resNumberPlate, err := previousNumberPlate.Get(env)
+	if err != nil {
+		if errors.Is(err, env.DB.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+	previousTime, err := time.ParseInLocation(models.DateTimeFormat, previous.EventTime, time.Local)
+	if err != nil || !resNumberPlate.ValidAt(previousTime) {
+		return "", nil
+	}
+
+	env.Logger.Printf("[%s] Number plate %s tailgated authorised number plate %s\n", cam.IPAddress, detection.Plate, previous.Plate)
+	return fmt.Sprintf("Unauthorised number plate %s followed authorised number plate %s through %s within %d seconds.", detection.Plate, previous.Plate, cam.DisplayName(), cam.TailgateSeconds), nil
+}
+
+// checkLoitering checks if a read takes the number of reads of its number plate past the loitering
+// threshold of the camera or its camera group, returning an alert reason for each threshold crossed.
+func checkLoitering(env *models.Env, cam models.Camera, detection models.Detection, t time.Time) ([]string, error) {
+	var alerts []string
+	if cam.LoiterCount > 0 && cam.LoiterMinutes > 0 {
+		crossed, err := loiterThresholdCrossed(env, detection.Plate, []int{cam.ID}, cam.LoiterCount, cam.LoiterMinutes, t)
+		if err != nil {
+			return alerts, err
+		}
+		if crossed {
+			alerts = append(alerts, fmt.Sprintf("Number plate %s has been read more than %d times in %d minutes at %s.", detection.Plate, cam.LoiterCount, cam.LoiterMinutes, cam.DisplayName()))
+		}
+	}
+	if cam.CameraGroupID == 0 {
+		return alerts, nil
+	}
+	cameraGroup := models.CameraGroup{ID: cam.CameraGroupID}
+	resCameraGroup, err := cameraGroup.Get(env)
+	if err != nil {
+		if errors.Is(err, env.DB.ErrRecordNotFound) {
+			return alerts, nil
+		}
+		return alerts, err
+	}
+	if resCameraGroup.LoiterCount > 0 && resCameraGroup.LoiterMinutes > 0 {
+		var camera models.Camera
+		resCameras, _, err := camera.Find(env, "AND", []models.WhereFields{{"camera_group_id", "=", cam.CameraGroupID}}, 0, 1)
+		if err != nil {
+			return alerts, err
+		}
+		var cameraIDs []int
+		for _, resCamera := range *resCameras {
+			cameraIDs = append(cameraIDs, resCamera.ID)
+		}
+		crossed, err := loiterThresholdCrossed(env, detection.Plate, cameraIDs, resCameraGroup.LoiterCount, resCameraGroup.LoiterMinutes, t)
+		if err != nil {
+			return alerts, err
+		}
+		if crossed {
+			alerts = append(alerts, fmt.Sprintf("Number plate %s has been read more than %d times in %d minutes by the %s cameras.", detection.Plate, resCameraGroup.LoiterCount, resCameraGroup.LoiterMinutes, resCameraGroup.Name))
+		}
+	}
+	return alerts, nil
+}
+
+// loiterThresholdCrossed checks if a read at the time provided is the one that takes the number of
+// reads of the number plate at the cameras past the count in the last number of minutes, so each
+// loitering vehicle only alerts once while it stays over the threshold.
+func loiterThresholdCrossed(env *models.Env, plate string, cameraIDs []int, count, minutes int, t time.Time) (bool, error) {
+	var detection models.Detection
+	previous, err := detection.CountByPlateAtCameras(env, plate, cameraIDs, t.Add(-time.Duration(minutes)*time.Minute))
+	if err != nil {
+		return false, err
+	}
+	return previous == count, nil
+}
+
+// matchRules evaluates the enabled alert rules against the input, returning the rules that matched.
+func matchRules(env *models.Env, input models.RuleInput) ([]matchedRule, error) {
+	var rule models.Rule
+	resRules, _, err := rule.Find(env, "AND", []models.WhereFields{{"enabled", "=", 1}}, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	var matchedRules []matchedRule
+	for _, resRule := range *resRules {
+		matched, explanation, err := resRule.Match(env, input)
+		if err != nil {
+			return matchedRules, err
+		}
+		if matched {
+			env.Logger.Printf("[%s] Rule %s matched number plate %s\n", input.Camera.IPAddress, resRule.Name, input.Detection.Plate)
+			matchedRules = append(matchedRules, matchedRule{Rule: resRule, Explanation: explanation})
+		}
+	}
+	return matchedRules, nil
+}
+
+// trackPresence opens a visit when a vehicle enters the site and closes its open visit when it leaves,
+// ignoring low confidence reads.
+func trackPresence(env *models.Env, detection models.Detection) error {
+	// Low confidence reads may be misreads, which would add phantom vehicles or close the wrong visit
+	if detection.Direction == "" || detection.LowConfidence {
+		return nil
+	}
+	var visit models.Visit
+	onSite, err := visit.FindOnSite(env, detection.Plate)
+	if err != nil {
+		return err
+	}
+	switch detection.Direction {
+	case "entry":
+		// Keep the original arrival if the vehicle is already on site
+		if onSite != nil {
+			return nil
+		}
+		visit = models.Visit{
+			Plate:            detection.Plate,
+			NumberPlateID:    detection.NumberPlateID,
+			EntryDetectionID: detection.ID,
+			EntryCameraID:    detection.CameraID,
+			EntryTime:        detection.EventTime,
+		}
+		_, err = visit.Add(env)
+	case "exit":
+		if onSite == nil {
+			return nil
+		}
+		onSite.ExitDetectionID = detection.ID
+		onSite.ExitCameraID = detection.CameraID
+		onSite.ExitTime = detection.EventTime
+		onSite.ClosedReason = "exit"
+		_, err = onSite.Close(env)
+	}
+	return err
+}
+
+// numberPlateAlertReason returns a description of why a number plate read is being alerted on.
+func numberPlateAlertReason(cam models.Camera, numberPlate models.NumberPlate, vehicle models.VehicleAttributes) string {
+	if numberPlate.AlertOnMismatch {
+		return fmt.Sprintf("Number plate %s was read on a %s at %s, which doesn't match its registered %s.", numberPlate.Plate, vehicle, cam.DisplayName(), numberPlate.VehicleAttributes)
+	}
+	return fmt.Sprintf("Number plate %s was read at %s.", numberPlate.Plate, cam.DisplayName())
+}
+
+// directionAllowed checks the direction of a read is the alert direction provided and the
+// watchlist's alert direction, if they're set. Reads from cameras without a direction set
+// are always allowed as it's unknown whether the vehicle is entering or leaving.
+func directionAllowed(direction, alertDirection string, watchlist *models.Watchlist) bool {
+	if direction == "" {
+		return true
+	}
+	if alertDirection != "" && alertDirection != direction {
+		return false
+	}
+	if watchlist != nil && watchlist.AlertDirection != "" && watchlist.AlertDirection != direction {
+		return false
+	}
+	return true
+}
+
+// scheduleActive checks the schedule provided, the camera's schedule and the watchlist's schedule
+// are all active at the time provided.
+func scheduleActive(env *models.Env, t time.Time, scheduleID int, cam models.Camera, watchlist *models.Watchlist) (bool, error) {
+	scheduleIDs := []int{scheduleID, cam.ScheduleID}
+	if watchlist != nil {
+		scheduleIDs = append(scheduleIDs, watchlist.ScheduleID)
+	}
+	for _, scheduleID := range scheduleIDs {
+		active, err := models.ScheduleActive(env, scheduleID, t)
+		if err != nil || !active {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// cameraInScope checks the camera is in the camera scopes of the owner provided and its watchlist.
+func cameraInScope(env *models.Env, cam models.Camera, ownerType string, ownerID int, watchlist *models.Watchlist) (bool, error) {
+	inScope, err := models.CameraInScope(env, ownerType, ownerID, cam)
+	if err != nil || !inScope {
+		return false, err
+	}
+	if watchlist != nil {
+		return models.CameraInScope(env, "watchlist", watchlist.ID, cam)
+	}
+	return true, nil
+}
@@ -0,0 +1,289 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"github.com/olivercullimore/hikvision-anpr-alerts/app/models"
+	"strconv"
+	"time"
+)
+
+// startJobs starts the background jobs.
+func startJobs(env *models.Env) {
+	go runJob(env, time.Hour, expireNumberPlates)
+	go runJob(env, 5*time.Minute, timeoutVisits)
+	go runJob(env, time.Minute, alertDwellingVehicles)
+	go runJob(env, time.Minute, checkExpectedArrivals)
+	go runJob(env, time.Minute, escalateAlerts)
+	go runJob(env, 5*time.Minute, sendDigests)
+	go runJob(env, time.Hour, pruneWebhookDeliveries)
+	go runJob(env, time.Hour, pruneOutbox)
+	go runOutbox(env)
+	go runWebhooks(env)
+}
+
+// runOutbox delivers notifications as soon as they're queued in the outbox and checks for retries
+// that are due every 15 seconds.
+func runOutbox(env *models.Env) {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+	for {
+		if err := models.DeliverOutbox(env); err != nil {
+			env.Logger.Printf("Error delivering outbox: %s\n", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-models.OutboxQueued:
+		}
+	}
+}
+
+// runWebhooks sends webhook deliveries as soon as they're logged and checks for retries that are due
+// every 15 seconds.
+func runWebhooks(env *models.Env) {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+	for {
+		if err := models.DeliverWebhooks(env); err != nil {
+			env.Logger.Printf("Error delivering webhooks: %s\n", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-models.WebhooksQueued:
+		}
+	}
+}
+
+// escalateAlerts sends alerts that haven't been acknowledged to the next steps of their escalation
+// policy.
+func escalateAlerts(env *models.Env) {
+	if err := models.EscalateAlerts(env); err != nil {
+		env.Logger.Printf("Error escalating alerts: %s\n", err)
+	}
+}
+
+// sendDigests emails digest subscriptions whose period has ended.
+func sendDigests(env *models.Env) {
+	if err := models.SendDigests(env); err != nil {
+		env.Logger.Printf("Error sending digests: %s\n", err)
+	}
+}
+
+// pruneWebhookDeliveries deletes webhook deliveries that were delivered or failed longer ago than the
+// webhook retention period.
+func pruneWebhookDeliveries(env *models.Env) {
+	days, err := strconv.Atoi(env.Config.WebhookRetentionDays)
+	if err != nil || days <= 0 {
+		return
+	}
+	var webhookDelivery models.WebhookDelivery
+	count, err := webhookDelivery.DeleteFinished(env, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		env.Logger.Printf("Error deleting old webhook deliveries: %s\n", err)
+	} else if count > 0 {
+		env.Logger.Printf("Deleted %d old webhook deliveries\n", count)
+	}
+}
+
+// pruneOutbox deletes outbox messages that were sent or failed longer ago than the outbox retention
+// period, and the snapshots no messages are left for.
+func pruneOutbox(env *models.Env) {
+	days, err := strconv.Atoi(env.Config.OutboxRetentionDays)
+	if err != nil || days <= 0 {
+		return
+	}
+	before := time.Now().AddDate(0, 0, -days)
+	var outboxMessage models.OutboxMessage
+	count, err := outboxMessage.DeleteFinished(env, before)
+	if err != nil {
+		env.Logger.Printf("Error deleting old outbox messages: %s\n", err)
+		return
+	}
+	if count > 0 {
+		env.Logger.Printf("Deleted %d old outbox messages\n", count)
+	}
+	var snapshot models.Snapshot
+	count, err = snapshot.DeleteUnused(env, before)
+	if err != nil {
+		env.Logger.Printf("Error deleting old snapshots: %s\n", err)
+	} else if count > 0 {
+		env.Logger.Printf("Deleted %d old snapshots\n", count)
+	}
+}
+
+// runJob runs a job straight away and then every interval.
+func runJob(env *models.Env, interval time.Duration, job func(env *models.Env)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		job(env)
+		<-ticker.C
+	}
+}
+
+// expireNumberPlates archives or deletes number plates whose validity window has ended.
+func expireNumberPlates(env *models.Env) {
+	var numberPlate models.NumberPlate
+	switch env.Config.ExpiredPlates {
+	case "archive":
+		count, err := numberPlate.ArchiveExpired(env, time.Now())
+		if err != nil {
+			env.Logger.Printf("Error archiving expired number plates: %s\n", err)
+		} else if count > 0 {
+			env.Logger.Printf("Archived %d expired number plates\n", count)
+		}
+	case "delete":
+		count, err := numberPlate.DeleteExpired(env, time.Now())
+		if err != nil {
+			env.Logger.Printf("Error deleting expired number plates: %s\n", err)
+		} else if count > 0 {
+			env.Logger.Printf("Deleted %d expired number plates\n", count)
+		}
+	}
+}
+
+// timeoutVisits closes visits for vehicles that entered longer ago than the visit timeout without
+// an exit read.
+func timeoutVisits(env *models.Env) {
+	hours, err := strconv.Atoi(env.Config.VisitTimeoutHours)
+	if err != nil || hours <= 0 {
+		return
+	}
+	var visit models.Visit
+	count, err := visit.TimeoutStale(env, time.Now().Add(-time.Duration(hours)*time.Hour))
+	if err != nil {
+		env.Logger.Printf("Error timing out visits: %s\n", err)
+	} else if count > 0 {
+		env.Logger.Printf("Timed out %d visits\n", count)
+	}
+}
+
+// alertDwellingVehicles sends an alert for each vehicle that has been on site longer than its dwell
+// time, once per visit.
+func alertDwellingVehicles(env *models.Env) {
+	var visit models.Visit
+	resVisits, _, err := visit.Find(env, "AND", []models.WhereFields{{"closed_reason", "=", ""}, {"dwell_alerted", "=", 0}}, 0, 1)
+	if err != nil {
+		env.Logger.Printf("Error finding vehicles on site: %s\n", err)
+		return
+	}
+	now := time.Now()
+	for _, resVisit := range *resVisits {
+		entryTime, err := time.ParseInLocation(models.DateTimeFormat, resVisit.EntryTime, time.Local)
+		if err != nil {
+			continue
+		}
+		dwellTime, err := getDwellTime(env, resVisit)
+		if err != nil {
+			env.Logger.Printf("Error getting dwell time for %s: %s\n", resVisit.Plate, err)
+			continue
+		}
+		if dwellTime == 0 || now.Sub(entryTime) < dwellTime {
+			continue
+		}
+		if _, err := resVisit.SetDwellAlerted(env); err != nil {
+			env.Logger.Printf("Error marking dwell time alert for %s: %s\n", resVisit.Plate, err)
+			continue
+		}
+		alert := models.Notification{
+			Title:         fmt.Sprintf("ANPR Alert: %s", resVisit.Plate),
+			Message:       fmt.Sprintf("Vehicle %s has been on site since %s, longer than %s.", resVisit.Plate, resVisit.EntryTime, dwellTime),
+			Link:          externalLink(env, "/onsite"),
+			CameraID:      resVisit.EntryCameraID,
+			Plate:         resVisit.Plate,
+			NumberPlateID: resVisit.NumberPlateID,
+		}
+		numberPlate := models.NumberPlate{ID: resVisit.NumberPlateID}
+		if resNumberPlate, err := numberPlate.Get(env); err == nil {
+			alert.WatchlistID = resNumberPlate.WatchlistID
+		}
+		alert.AddField("Number Plate", resVisit.Plate)
+		alert.AddField("Arrived", resVisit.EntryTime)
+		alert.AddField("Dwell Time", dwellTime.String())
+		sendAlert(alert, env)
+	}
+}
+
+// getDwellTime returns how long a vehicle can stay on site before alerting, using its watchlist's
+// dwell time if it has one, or 0 if it shouldn't alert.
+func getDwellTime(env *models.Env, visit models.Visit) (time.Duration, error) {
+	globalMinutes, _ := strconv.Atoi(env.Config.DwellMinutes)
+	if visit.NumberPlateID == 0 {
+		return time.Duration(globalMinutes) * time.Minute, nil
+	}
+	numberPlate := models.NumberPlate{ID: visit.NumberPlateID}
+	resNumberPlate, err := numberPlate.Get(env)
+	if err != nil && !errors.Is(err, env.DB.ErrRecordNotFound) {
+		return 0, err
+	}
+	if resNumberPlate != nil {
+		watchlist, err := findWatchlist(env, resNumberPlate.WatchlistID)
+		if err != nil {
+			return 0, err
+		}
+		if watchlist != nil && watchlist.DwellMinutes > 0 {
+			return time.Duration(watchlist.DwellMinutes) * time.Minute, nil
+		}
+	}
+	if env.Config.DwellUnknownOnly == "true" {
+		return 0, nil
+	}
+	return time.Duration(globalMinutes) * time.Minute, nil
+}
+
+// checkExpectedArrivals marks pending expected arrivals as arrived once their number plate has been
+// read, and as missed with an alert once their deadline passes without a read.
+func checkExpectedArrivals(env *models.Env) {
+	var expectedArrival models.ExpectedArrival
+	resExpectedArrivals, _, err := expectedArrival.Find(env, "AND", []models.WhereFields{{"status", "=", "pending"}}, 0, 1)
+	if err != nil {
+		env.Logger.Printf("Error finding expected arrivals: %s\n", err)
+		return
+	}
+	now := time.Now()
+	for _, resExpectedArrival := range *resExpectedArrivals {
+		expectedBy, err := time.ParseInLocation(models.DateTimeFormat, resExpectedArrival.ExpectedBy, time.Local)
+		if err != nil {
+			continue
+		}
+		until := now
+		if expectedBy.Before(now) {
+			until = expectedBy
+		}
+		detection, err := resExpectedArrival.FindArrival(env, until)
+		if err != nil {
+			env.Logger.Printf("Error checking expected arrival of %s: %s\n", resExpectedArrival.Plate, err)
+			continue
+		}
+		switch {
+		case detection != nil:
+			resExpectedArrival.Status = "arrived"
+			resExpectedArrival.ArrivedDetectionID = detection.ID
+		case expectedBy.Before(now):
+			resExpectedArrival.Status = "missed"
+		default:
+			continue
+		}
+		if _, err := resExpectedArrival.Update(env); err != nil {
+			env.Logger.Printf("Error updating expected arrival of %s: %s\n", resExpectedArrival.Plate, err)
+			continue
+		}
+		if resExpectedArrival.Status == "missed" {
+			vehicle := resExpectedArrival.Plate
+			if resExpectedArrival.Description != "" {
+				vehicle = fmt.Sprintf("%s (%s)", resExpectedArrival.Plate, resExpectedArrival.Description)
+			}
+			alert := models.Notification{
+				Title:   fmt.Sprintf("ANPR Alert: %s", resExpectedArrival.Plate),
+				Message: fmt.Sprintf("Expected vehicle %s hadn't arrived by %s.", vehicle, resExpectedArrival.ExpectedBy),
+				Link:    externalLink(env, "/arrivals?filter=missed"),
+				Plate:   resExpectedArrival.Plate,
+			}
+			alert.AddField("Number Plate", resExpectedArrival.Plate)
+			alert.AddField("Description", resExpectedArrival.Description)
+			alert.AddField("Expected From", resExpectedArrival.ExpectedFrom)
+			alert.AddField("Expected By", resExpectedArrival.ExpectedBy)
+			sendAlert(alert, env)
+		}
+	}
+}
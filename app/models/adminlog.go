@@ -2,6 +2,7 @@ package models
 
 import (
 	"database/sql"
+	"strings"
 )
 
 // AdminLog struct
@@ -71,7 +72,7 @@ func (l *AdminLog) Migrate(env *Env) (sql.Result, error) {
 	res, err := env.DB.Exec(`
 	CREATE TABLE IF NOT EXISTS admin_logs (
 		id INTEGER NOT NULL PRIMARY KEY,
-		type TEXT NOT NULL UNIQUE,
+		type TEXT NOT NULL,
 		details text NOT NULL,
 		user_id INTEGER NOT NULL DEFAULT 0,
 		time INTEGER NOT NULL DEFAULT 0
@@ -80,5 +81,31 @@ func (l *AdminLog) Migrate(env *Env) (sql.Result, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Rebuild the table without the unique constraint on type it was created with, which only allowed
+	// one log of each type
+	var tableSQL []string
+	err = env.DB.Query(&tableSQL, "SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'admin_logs'")
+	if err != nil {
+		return nil, err
+	}
+	if len(tableSQL) > 0 && strings.Contains(tableSQL[0], "UNIQUE") {
+		res, err = env.DB.Exec(`
+		BEGIN;
+		ALTER TABLE admin_logs RENAME TO admin_logs_old;
+		CREATE TABLE admin_logs (
+			id INTEGER NOT NULL PRIMARY KEY,
+			type TEXT NOT NULL,
+			details text NOT NULL,
+			user_id INTEGER NOT NULL DEFAULT 0,
+			time INTEGER NOT NULL DEFAULT 0
+		);
+		INSERT INTO admin_logs (id, type, details, user_id, time) SELECT id, type, details, user_id, time FROM admin_logs_old;
+		DROP TABLE admin_logs_old;
+		COMMIT;
+		`)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return res, nil
 }
@@ -0,0 +1,192 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AlertStates are the display names of alert states
+var AlertStates = map[string]string{"new": "New", "acknowledged": "Acknowledged", "resolved": "Resolved"}
+
+// Alert is the record of an alert sent, which operators acknowledge and resolve
+type Alert struct {
+	ID                 int    `json:"id"`
+	Title              string `json:"title"`
+	Message            string `json:"message"`
+	DetectionID        int    `json:"detectionID" db:"detection_id"`
+	NumberPlateID      int    `json:"numberPlateID" db:"number_plate_id"`
+	Plate              string `json:"plate"`
+	CameraID           int    `json:"cameraID" db:"camera_id"`
+	WatchlistID        int    `json:"watchlistID" db:"watchlist_id"`
+	RuleID             int    `json:"ruleID" db:"rule_id"`
+	State              string `json:"state"`
+	AcknowledgedBy     int    `json:"acknowledgedBy" db:"acknowledged_by"`
+	AcknowledgedAt     string `json:"acknowledgedAt" db:"acknowledged_at"`
+	ResolvedBy         int    `json:"resolvedBy" db:"resolved_by"`
+	ResolvedAt         string `json:"resolvedAt" db:"resolved_at"`
+	Note               string `json:"note"`
+	EscalationPolicyID int    `json:"escalationPolicyID" db:"escalation_policy_id"`
+	EscalationLevel    int    `json:"escalationLevel" db:"escalation_level"`
+	CreatedAt          string `json:"createdAt" db:"created_at"`
+	UpdatedAt          string `json:"updatedAt" db:"updated_at"`
+}
+
+// Add alert
+func (e *Alert) Add(env *Env) (int64, error) {
+	if e.State == "" {
+		e.State = "new"
+	}
+	// Add to database
+	res, err := env.DB.Exec(
+		"INSERT INTO alerts (title, message, detection_id, number_plate_id, plate, camera_id, watchlist_id, rule_id, state, acknowledged_by, acknowledged_at, resolved_by, resolved_at, note, escalation_policy_id, escalation_level, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, DATETIME('now', 'localtime'), DATETIME('now', 'localtime'))",
+		&e.Title, &e.Message, &e.DetectionID, &e.NumberPlateID, &e.Plate, &e.CameraID, &e.WatchlistID, &e.RuleID, &e.State, &e.AcknowledgedBy, &e.AcknowledgedAt, &e.ResolvedBy, &e.ResolvedAt, &e.Note, &e.EscalationPolicyID, &e.EscalationLevel,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	e.ID = int(id)
+	return res.RowsAffected()
+}
+
+// Get alert by ID provided
+func (e *Alert) Get(env *Env) (*Alert, error) {
+	// Get from database
+	var alerts []Alert
+	err := env.DB.Query(&alerts, "SELECT * FROM alerts WHERE id = ? LIMIT 1", &e.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(alerts) == 0 {
+		return nil, env.DB.ErrRecordNotFound
+	}
+	return &alerts[0], nil
+}
+
+// Find alerts by fields provided
+func (e *Alert) Find(env *Env, operator string, fields []WhereFields, perPage int, pageNumber int) (*[]Alert, int, error) {
+	resCount := 0
+	// Where
+	whereSQL, values := env.DB.WhereSQL(operator, fields)
+	// Limit
+	limitSQL := env.DB.LimitSQL(perPage, pageNumber)
+	if limitSQL != "" {
+		// Get count from database
+		var alerts []Alert
+		err := env.DB.Query(&alerts, "SELECT id FROM alerts"+whereSQL, values...)
+		if err != nil {
+			return nil, 0, err
+		}
+		resCount = len(alerts)
+	}
+	// Get from database
+	var alerts []Alert
+	err := env.DB.Query(&alerts, "SELECT * FROM alerts"+whereSQL+" ORDER BY id DESC"+limitSQL, values...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resCount == 0 {
+		resCount = len(alerts)
+	}
+	return &alerts, resCount, nil
+}
+
+// Update alert state
+func (e *Alert) Update(env *Env) (int64, error) {
+	// Update database
+	res, err := env.DB.Exec(
+		"UPDATE alerts SET state = ?, acknowledged_by = ?, acknowledged_at = ?, resolved_by = ?, resolved_at = ?, note = ?, updated_at = DATETIME('now', 'localtime') WHERE id = ?",
+		&e.State, &e.AcknowledgedBy, &e.AcknowledgedAt, &e.ResolvedBy, &e.ResolvedAt, &e.Note, &e.ID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Acknowledge marks the alert as acknowledged by the user provided with their note, only new alerts
+// can be acknowledged
+func (e *Alert) Acknowledge(env *Env, userID int, note string) (int64, error) {
+	if e.State != "new" {
+		return 0, fmt.Errorf("alert has already been %s", e.State)
+	}
+	e.State = "acknowledged"
+	e.AcknowledgedBy = userID
+	e.AcknowledgedAt = time.Now().Format(DateTimeFormat)
+	e.Note = note
+	return e.Update(env)
+}
+
+// Resolve marks the alert as resolved by the user provided with their note, acknowledging it too if it
+// hadn't been
+func (e *Alert) Resolve(env *Env, userID int, note string) (int64, error) {
+	if e.State == "resolved" {
+		return 0, errors.New("alert has already been resolved")
+	}
+	now := time.Now().Format(DateTimeFormat)
+	if e.AcknowledgedBy == 0 {
+		e.AcknowledgedBy = userID
+		e.AcknowledgedAt = now
+	}
+	e.State = "resolved"
+	e.ResolvedBy = userID
+	e.ResolvedAt = now
+	e.Note = note
+	return e.Update(env)
+}
+
+// SetEscalationLevel records the number of escalation steps the alert has been sent to
+func (e *Alert) SetEscalationLevel(env *Env, level int) (int64, error) {
+	e.EscalationLevel = level
+	// Update database
+	res, err := env.DB.Exec("UPDATE alerts SET escalation_level = ? WHERE id = ?", &e.EscalationLevel, &e.ID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Migrate alerts
+func (e *Alert) Migrate(env *Env) (sql.Result, error) {
+	// Create table if not exists
+	res, err := env.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS alerts (
+		id INTEGER NOT NULL PRIMARY KEY,
+		title TEXT NOT NULL,
+		message TEXT NOT NULL DEFAULT '',
+		detection_id INTEGER NOT NULL DEFAULT 0,
+		number_plate_id INTEGER NOT NULL DEFAULT 0,
+		plate TEXT NOT NULL DEFAULT '',
+		camera_id INTEGER NOT NULL DEFAULT 0,
+		watchlist_id INTEGER NOT NULL DEFAULT 0,
+		rule_id INTEGER NOT NULL DEFAULT 0,
+		state TEXT NOT NULL DEFAULT 'new',
+		acknowledged_by INTEGER NOT NULL DEFAULT 0,
+		acknowledged_at TEXT NOT NULL DEFAULT '',
+		resolved_by INTEGER NOT NULL DEFAULT 0,
+		resolved_at TEXT NOT NULL DEFAULT '',
+		note TEXT NOT NULL DEFAULT '',
+		escalation_policy_id INTEGER NOT NULL DEFAULT 0,
+		escalation_level INTEGER NOT NULL DEFAULT 0,
+		created_at TEXT NOT NULL DEFAULT 0,
+		updated_at TEXT NOT NULL DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS alerts_state ON alerts (state);
+	`)
+	if err != nil {
+		return nil, err
+	}
+	// Add columns missing from older databases
+	if err := env.DB.AddColumn("alerts", "escalation_policy_id", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	if err := env.DB.AddColumn("alerts", "escalation_level", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
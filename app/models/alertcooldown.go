@@ -0,0 +1,89 @@
+package models
+
+import (
+	"database/sql"
+)
+
+// AlertCooldown is the cooldown of a recipient's alerts for a plate at a camera, with the reads it's
+// suppressed, stored so cooldowns carry on after a restart
+type AlertCooldown struct {
+	ID                    int    `json:"id"`
+	Recipient             string `json:"recipient"`
+	Plate                 string `json:"plate"`
+	CameraID              int    `json:"cameraID" db:"camera_id"`
+	EndsAt                string `json:"endsAt" db:"ends_at"`
+	DetectionID           int    `json:"detectionID" db:"detection_id"`
+	Suppressed            int    `json:"suppressed"`
+	SuppressedDetectionID int    `json:"suppressedDetectionID" db:"suppressed_detection_id"`
+}
+
+// Get alert cooldown by recipient, plate and camera provided
+func (e *AlertCooldown) Get(env *Env) (*AlertCooldown, error) {
+	// Get from database
+	var alertCooldowns []AlertCooldown
+	err := env.DB.Query(&alertCooldowns, "SELECT * FROM alert_cooldowns WHERE recipient = ? AND plate = ? AND camera_id = ? LIMIT 1", &e.Recipient, &e.Plate, &e.CameraID)
+	if err != nil {
+		return nil, err
+	}
+	if len(alertCooldowns) == 0 {
+		return nil, env.DB.ErrRecordNotFound
+	}
+	return &alertCooldowns[0], nil
+}
+
+// Save alert cooldown, adding it if it hasn't been yet
+func (e *AlertCooldown) Save(env *Env) (int64, error) {
+	if e.ID == 0 {
+		// Add to database
+		res, err := env.DB.Exec(
+			"INSERT INTO alert_cooldowns (recipient, plate, camera_id, ends_at, detection_id, suppressed, suppressed_detection_id) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			&e.Recipient, &e.Plate, &e.CameraID, &e.EndsAt, &e.DetectionID, &e.Suppressed, &e.SuppressedDetectionID,
+		)
+		if err != nil {
+			return 0, err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return 0, err
+		}
+		e.ID = int(id)
+		return res.RowsAffected()
+	}
+	// Update database
+	res, err := env.DB.Exec(
+		"UPDATE alert_cooldowns SET ends_at = ?, detection_id = ?, suppressed = ?, suppressed_detection_id = ? WHERE id = ?",
+		&e.EndsAt, &e.DetectionID, &e.Suppressed, &e.SuppressedDetectionID, &e.ID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteExpired deletes cooldowns that ended before the time provided without suppressing anything
+func (e *AlertCooldown) DeleteExpired(env *Env, now string) (int64, error) {
+	// Delete from database
+	res, err := env.DB.Exec("DELETE FROM alert_cooldowns WHERE suppressed = 0 AND ends_at < ?", &now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Migrate alert cooldowns
+func (e *AlertCooldown) Migrate(env *Env) (sql.Result, error) {
+	// Create table if not exists
+	return env.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS alert_cooldowns (
+		id INTEGER NOT NULL PRIMARY KEY,
+		recipient TEXT NOT NULL,
+		plate TEXT NOT NULL,
+		camera_id INTEGER NOT NULL DEFAULT 0,
+		ends_at TEXT NOT NULL DEFAULT '',
+		detection_id INTEGER NOT NULL DEFAULT 0,
+		suppressed INTEGER NOT NULL DEFAULT 0,
+		suppressed_detection_id INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS alert_cooldowns_key ON alert_cooldowns (recipient, plate, camera_id);
+	`)
+}
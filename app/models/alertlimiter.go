@@ -0,0 +1,144 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AlertLimiter cools down repeated alerts for the same plate at the same camera and rate limits the
+// alerts sent to each recipient, counting the alerts it suppresses so they can be summarised in the
+// next alert that gets through. Its state is stored in the database so it carries on after a restart.
+type AlertLimiter struct {
+	Cooldown  time.Duration // Cooldown is how long to suppress repeat alerts for the same plate and camera, 0 for no cooldown
+	RateLimit int           // RateLimit is the maximum number of alerts per recipient per hour, 0 for no limit
+	mu        sync.Mutex
+}
+
+// NewAlertLimiter returns an alert limiter using the cooldown and rate limit provided
+func NewAlertLimiter(cooldown time.Duration, rateLimit int) *AlertLimiter {
+	return &AlertLimiter{Cooldown: cooldown, RateLimit: rateLimit}
+}
+
+// Allow checks if the notification can be sent to the recipient provided, returning a summary of the
+// alerts suppressed since the last one sent to the recipient if it can. The cooldown minutes provided
+// override the limiter's cooldown if they aren't 0. Alerts for the same detection are always allowed
+// together so each match is sent, and escalations are always allowed and aren't counted towards the
+// rate limit.
+func (e *AlertLimiter) Allow(env *Env, recipient string, notification *Notification, cooldownMinutes int, now time.Time) (bool, string, error) {
+	if e == nil || notification.Escalation {
+		return true, "", nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	nowTime := now.Format(DateTimeFormat)
+
+	// Check the cooldown for the plate at the camera
+	cooldownFor := e.Cooldown
+	if cooldownMinutes > 0 {
+		cooldownFor = time.Duration(cooldownMinutes) * time.Minute
+	}
+	var cooldown *AlertCooldown
+	if cooldownFor > 0 && notification.Plate != "" && notification.DetectionID != 0 {
+		alertCooldown := AlertCooldown{Recipient: recipient, Plate: notification.Plate, CameraID: notification.CameraID}
+		resCooldown, err := alertCooldown.Get(env)
+		if err != nil && !errors.Is(err, env.DB.ErrRecordNotFound) {
+			return false, "", err
+		}
+		if resCooldown == nil {
+			resCooldown = &alertCooldown
+		}
+		cooldown = resCooldown
+		if nowTime < cooldown.EndsAt && cooldown.DetectionID != notification.DetectionID {
+			if cooldown.SuppressedDetectionID != notification.DetectionID {
+				cooldown.SuppressedDetectionID = notification.DetectionID
+				cooldown.Suppressed++
+				if _, err := cooldown.Save(env); err != nil {
+					return false, "", err
+				}
+			}
+			return false, "", nil
+		}
+	}
+
+	// Check the rate limit for the recipient
+	var rate *AlertRate
+	if e.RateLimit > 0 {
+		alertRate := AlertRate{Recipient: recipient}
+		resRate, err := alertRate.Get(env)
+		if err != nil && !errors.Is(err, env.DB.ErrRecordNotFound) {
+			return false, "", err
+		}
+		if resRate == nil {
+			resRate = &alertRate
+		}
+		rate = resRate
+		hourAgo := now.Add(-time.Hour).Format(DateTimeFormat)
+		var sent []string
+		for _, sentAt := range strings.Split(rate.SentTimes, "\n") {
+			if sentAt != "" && sentAt > hourAgo {
+				sent = append(sent, sentAt)
+			}
+		}
+		if len(sent) >= e.RateLimit {
+			rate.Suppressed++
+			if _, err := rate.Save(env); err != nil {
+				return false, "", err
+			}
+			return false, "", nil
+		}
+		rate.SentTimes = strings.Join(append(sent, nowTime), "\n")
+		rate.ExpiresAt = now.Add(time.Hour).Format(DateTimeFormat)
+	}
+
+	// Summarise the suppressed alerts
+	var summary []string
+	if cooldown != nil {
+		if cooldown.Suppressed > 0 {
+			summary = append(summary, fmt.Sprintf("+%d more %s of %s", cooldown.Suppressed, plural(cooldown.Suppressed, "read", "reads"), notification.Plate))
+		}
+		if cooldown.DetectionID != notification.DetectionID {
+			cooldown.EndsAt = now.Add(cooldownFor).Format(DateTimeFormat)
+			cooldown.DetectionID = notification.DetectionID
+		}
+		cooldown.Suppressed = 0
+		if _, err := cooldown.Save(env); err != nil {
+			return false, "", err
+		}
+	}
+	if rate != nil {
+		if rate.Suppressed > 0 {
+			summary = append(summary, fmt.Sprintf("+%d more %s over the rate limit", rate.Suppressed, plural(rate.Suppressed, "alert", "alerts")))
+		}
+		rate.Suppressed = 0
+		if _, err := rate.Save(env); err != nil {
+			return false, "", err
+		}
+	}
+	if err := e.prune(env, nowTime); err != nil {
+		return false, "", err
+	}
+	return true, strings.Join(summary, ", "), nil
+}
+
+// prune deletes cooldowns that have expired without suppressing anything and rates with nothing to
+// limit or summarise
+func (e *AlertLimiter) prune(env *Env, now string) error {
+	var alertCooldown AlertCooldown
+	if _, err := alertCooldown.DeleteExpired(env, now); err != nil {
+		return err
+	}
+	var alertRate AlertRate
+	_, err := alertRate.DeleteExpired(env, now)
+	return err
+}
+
+// plural returns the singular or plural word depending on the count provided
+func plural(count int, singular, plural string) string {
+	if count == 1 {
+		return singular
+	}
+	return plural
+}
@@ -0,0 +1,182 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAlertLimiterAllow(t *testing.T) {
+	// alertLimiterCall is an alert sent at an offset from the start of the test and whether it's expected
+	// to be allowed and with what summary
+	type alertLimiterCall struct {
+		at          time.Duration
+		recipient   string
+		plate       string
+		cameraID    int
+		detectionID int
+		allow       bool
+		summary     string
+	}
+	tests := []struct {
+		name      string
+		cooldown  time.Duration
+		rateLimit int
+		calls     []alertLimiterCall
+	}{
+		{"no limits", 0, 0, []alertLimiterCall{
+			{0, "a@example.com", "AB12CDE", 1, 1, true, ""},
+			{time.Second, "a@example.com", "AB12CDE", 1, 2, true, ""},
+		}},
+		{"cooldown suppresses repeat reads", 5 * time.Minute, 0, []alertLimiterCall{
+			{0, "a@example.com", "AB12CDE", 1, 1, true, ""},
+			{time.Minute, "a@example.com", "AB12CDE", 1, 2, false, ""},
+			{2 * time.Minute, "a@example.com", "AB12CDE", 1, 3, false, ""},
+			{6 * time.Minute, "a@example.com", "AB12CDE", 1, 4, true, "+2 more reads of AB12CDE"},
+			{7 * time.Minute, "a@example.com", "AB12CDE", 1, 5, false, ""},
+		}},
+		{"cooldown allows every match of a read", 5 * time.Minute, 0, []alertLimiterCall{
+			{0, "a@example.com", "AB12CDE", 1, 1, true, ""},
+			{0, "a@example.com", "AB12CDE", 1, 1, true, ""},
+			{time.Minute, "a@example.com", "AB12CDE", 1, 2, false, ""},
+			{time.Minute, "a@example.com", "AB12CDE", 1, 2, false, ""},
+			{6 * time.Minute, "a@example.com", "AB12CDE", 1, 3, true, "+1 more read of AB12CDE"},
+		}},
+		{"cooldown is per plate, camera and recipient", 5 * time.Minute, 0, []alertLimiterCall{
+			{0, "a@example.com", "AB12CDE", 1, 1, true, ""},
+			{time.Second, "a@example.com", "XY34ZZZ", 1, 2, true, ""},
+			{2 * time.Second, "a@example.com", "AB12CDE", 2, 3, true, ""},
+			{3 * time.Second, "b@example.com", "AB12CDE", 1, 4, true, ""},
+		}},
+		{"cooldown ignores alerts without a read", 5 * time.Minute, 0, []alertLimiterCall{
+			{0, "a@example.com", "", 1, 0, true, ""},
+			{time.Second, "a@example.com", "", 1, 0, true, ""},
+		}},
+		{"rate limit", 0, 2, []alertLimiterCall{
+			{0, "a@example.com", "AB12CDE", 1, 1, true, ""},
+			{time.Minute, "a@example.com", "XY34ZZZ", 1, 2, true, ""},
+			{2 * time.Minute, "a@example.com", "CD56EFG", 1, 3, false, ""},
+			{3 * time.Minute, "b@example.com", "CD56EFG", 1, 3, true, ""},
+			{59 * time.Minute, "a@example.com", "GH78IJK", 1, 4, false, ""},
+			{60 * time.Minute, "a@example.com", "KL90MNO", 1, 5, true, "+2 more alerts over the rate limit"},
+		}},
+		{"cooldown and rate limit", 5 * time.Minute, 1, []alertLimiterCall{
+			{0, "a@example.com", "AB12CDE", 1, 1, true, ""},
+			{time.Minute, "a@example.com", "AB12CDE", 1, 2, false, ""},
+			{2 * time.Minute, "a@example.com", "XY34ZZZ", 1, 3, false, ""},
+			{61 * time.Minute, "a@example.com", "AB12CDE", 1, 4, true, "+1 more read of AB12CDE, +1 more alert over the rate limit"},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			limiter := NewAlertLimiter(test.cooldown, test.rateLimit)
+			start := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+			for i, call := range test.calls {
+				notification := Notification{Plate: call.plate, CameraID: call.cameraID, DetectionID: call.detectionID}
+				allow, summary, err := limiter.Allow(env, call.recipient, &notification, 0, start.Add(call.at))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if allow != call.allow || summary != call.summary {
+					t.Errorf("call %d: Allow() = %v, %q, want %v, %q", i, allow, summary, call.allow, call.summary)
+				}
+			}
+		})
+	}
+
+	t.Run("cooldown override", func(t *testing.T) {
+		env := newTestEnv(t)
+		limiter := NewAlertLimiter(5*time.Minute, 0)
+		start := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+		calls := []struct {
+			at              time.Duration
+			cooldownMinutes int
+			detectionID     int
+			allow           bool
+			summary         string
+		}{
+			{0, 30, 1, true, ""},
+			{10 * time.Minute, 30, 2, false, ""},
+			{31 * time.Minute, 30, 3, true, "+1 more read of AB12CDE"},
+			// The limiter's cooldown is used without an override
+			{62 * time.Minute, 0, 4, true, ""},
+			{63 * time.Minute, 0, 5, false, ""},
+			{68 * time.Minute, 0, 6, true, "+1 more read of AB12CDE"},
+		}
+		for i, call := range calls {
+			notification := Notification{Plate: "AB12CDE", CameraID: 1, DetectionID: call.detectionID}
+			allow, summary, err := limiter.Allow(env, "a@example.com", &notification, call.cooldownMinutes, start.Add(call.at))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if allow != call.allow || summary != call.summary {
+				t.Errorf("call %d: Allow() = %v, %q, want %v, %q", i, allow, summary, call.allow, call.summary)
+			}
+		}
+	})
+
+	t.Run("state is kept after a restart", func(t *testing.T) {
+		env := newTestEnv(t)
+		start := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+		limiter := NewAlertLimiter(5*time.Minute, 2)
+		for i, allowed := range []bool{true, false} {
+			notification := Notification{Plate: "AB12CDE", CameraID: 1, DetectionID: i + 1}
+			if allow, _, err := limiter.Allow(env, "a@example.com", &notification, 0, start.Add(time.Duration(i)*time.Minute)); err != nil || allow != allowed {
+				t.Fatalf("call %d: Allow() = %v, %v, want %v", i, allow, err, allowed)
+			}
+		}
+		notification := Notification{Plate: "XY34ZZZ", CameraID: 1, DetectionID: 3}
+		if allow, _, err := limiter.Allow(env, "a@example.com", &notification, 0, start.Add(2*time.Minute)); err != nil || !allow {
+			t.Fatalf("second plate: Allow() = %v, %v, want true", allow, err)
+		}
+
+		// A new limiter still has the cooldown, rate limit and suppressed reads
+		limiter = NewAlertLimiter(5*time.Minute, 2)
+		notification = Notification{Plate: "AB12CDE", CameraID: 1, DetectionID: 4}
+		if allow, _, err := limiter.Allow(env, "a@example.com", &notification, 0, start.Add(3*time.Minute)); err != nil || allow {
+			t.Errorf("read during cooldown: Allow() = %v, %v, want false", allow, err)
+		}
+		notification = Notification{Plate: "CD56EFG", CameraID: 1, DetectionID: 5}
+		if allow, _, err := limiter.Allow(env, "a@example.com", &notification, 0, start.Add(4*time.Minute)); err != nil || allow {
+			t.Errorf("read over rate limit: Allow() = %v, %v, want false", allow, err)
+		}
+		notification = Notification{Plate: "AB12CDE", CameraID: 1, DetectionID: 6}
+		allow, summary, err := limiter.Allow(env, "a@example.com", &notification, 0, start.Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "+2 more reads of AB12CDE, +1 more alert over the rate limit"; !allow || summary != want {
+			t.Errorf("read after cooldown: Allow() = %v, %q, want true, %q", allow, summary, want)
+		}
+	})
+
+	t.Run("escalations", func(t *testing.T) {
+		env := newTestEnv(t)
+		limiter := NewAlertLimiter(5*time.Minute, 1)
+		start := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+		read := Notification{Plate: "AB12CDE", CameraID: 1, DetectionID: 1}
+		escalation := read
+		escalation.Escalation = true
+		if allow, _, _ := limiter.Allow(env, "a@example.com", &read, 0, start); !allow {
+			t.Fatal("first alert wasn't allowed")
+		}
+		// Escalations get through during the cooldown and over the rate limit
+		for _, at := range []time.Duration{time.Minute, 2 * time.Minute} {
+			if allow, summary, _ := limiter.Allow(env, "a@example.com", &escalation, 0, start.Add(at)); !allow || summary != "" {
+				t.Errorf("escalation after %s: Allow() = %v, %q, want true", at, allow, summary)
+			}
+		}
+		// and they don't count towards the rate limit or get summarised
+		next := Notification{Plate: "XY34ZZZ", CameraID: 1, DetectionID: 2}
+		if allow, summary, _ := limiter.Allow(env, "a@example.com", &next, 0, start.Add(time.Hour)); !allow || summary != "" {
+			t.Errorf("alert after escalations: Allow() = %v, %q, want true", allow, summary)
+		}
+	})
+
+	t.Run("nil limiter", func(t *testing.T) {
+		var limiter *AlertLimiter
+		if allow, _, _ := limiter.Allow(nil, "a@example.com", &Notification{}, 0, time.Now()); !allow {
+			t.Error("nil limiter didn't allow the alert")
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"database/sql"
+)
+
+// AlertRate is the alerts sent to a recipient in the last hour and the number suppressed over the rate
+// limit since the last one sent, stored so the rate limit carries on after a restart
+type AlertRate struct {
+	ID         int    `json:"id"`
+	Recipient  string `json:"recipient"`
+	SentTimes  string `json:"sentTimes" db:"sent_times"` // SentTimes is the times alerts were sent, one per line, oldest first
+	Suppressed int    `json:"suppressed"`
+	ExpiresAt  string `json:"expiresAt" db:"expires_at"` // ExpiresAt is an hour after the last alert was sent
+}
+
+// Get alert rate by recipient provided
+func (e *AlertRate) Get(env *Env) (*AlertRate, error) {
+	// Get from database
+	var alertRates []AlertRate
+	err := env.DB.Query(&alertRates, "SELECT * FROM alert_rates WHERE recipient = ? LIMIT 1", &e.Recipient)
+	if err != nil {
+		return nil, err
+	}
+	if len(alertRates) == 0 {
+		return nil, env.DB.ErrRecordNotFound
+	}
+	return &alertRates[0], nil
+}
+
+// Save alert rate, adding it if it hasn't been yet
+func (e *AlertRate) Save(env *Env) (int64, error) {
+	if e.ID == 0 {
+		// Add to database
+		res, err := env.DB.Exec(
+			"INSERT INTO alert_rates (recipient, sent_times, suppressed, expires_at) VALUES (?, ?, ?, ?)",
+			&e.Recipient, &e.SentTimes, &e.Suppressed, &e.ExpiresAt,
+		)
+		if err != nil {
+			return 0, err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return 0, err
+		}
+		e.ID = int(id)
+		return res.RowsAffected()
+	}
+	// Update database
+	res, err := env.DB.Exec(
+		"UPDATE alert_rates SET sent_times = ?, suppressed = ?, expires_at = ? WHERE id = ?",
+		&e.SentTimes, &e.Suppressed, &e.ExpiresAt, &e.ID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteExpired deletes rates with no alerts sent in the hour before the time provided and nothing
+// suppressed
+func (e *AlertRate) DeleteExpired(env *Env, now string) (int64, error) {
+	// Delete from database
+	res, err := env.DB.Exec("DELETE FROM alert_rates WHERE suppressed = 0 AND expires_at <= ?", &now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Migrate alert rates
+func (e *AlertRate) Migrate(env *Env) (sql.Result, error) {
+	// Create table if not exists
+	return env.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS alert_rates (
+		id INTEGER NOT NULL PRIMARY KEY,
+		recipient TEXT NOT NULL UNIQUE,
+		sent_times TEXT NOT NULL DEFAULT '',
+		suppressed INTEGER NOT NULL DEFAULT 0,
+		expires_at TEXT NOT NULL DEFAULT ''
+	);
+	`)
+}
@@ -2,29 +2,47 @@ package models
 
 import (
 	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
 )
 
 // Camera struct
 type Camera struct {
-	ID        int    `json:"id"`
-	Name      string `json:"name"`
-	IPAddress string `json:"ipAddress" validate:"required" db:"ip_address"`
-	Username  string `json:"username" validate:"required"`
-	Password  string `json:"password" validate:"required"`
-	CreatedAt string `json:"createdAt" db:"created_at"`
-	UpdatedAt string `json:"updatedAt" db:"updated_at"`
+	ID              int    `json:"id"`
+	Name            string `json:"name"`
+	IPAddress       string `json:"ipAddress" validate:"required" db:"ip_address"`
+	Username        string `json:"username" validate:"required"`
+	Password        string `json:"password" validate:"required"`
+	ScheduleID      int    `json:"scheduleID" db:"schedule_id"`
+	CameraGroupID   int    `json:"cameraGroupID" db:"camera_group_id"`
+	MinConfidence   int    `json:"minConfidence" db:"min_confidence" validate:"min=0,max=100"`
+	ForwardIs       string `json:"forwardIs" db:"forward_is" validate:"omitempty,oneof=entry exit"`
+	LoiterCount     int    `json:"loiterCount" db:"loiter_count" validate:"min=0"`
+	LoiterMinutes   int    `json:"loiterMinutes" db:"loiter_minutes" validate:"min=0"`
+	TailgateSeconds int    `json:"tailgateSeconds" db:"tailgate_seconds" validate:"min=0"`
+	CooldownMinutes int    `json:"cooldownMinutes" db:"cooldown_minutes" validate:"min=0"`
+	CreatedAt       string `json:"createdAt" db:"created_at"`
+	UpdatedAt       string `json:"updatedAt" db:"updated_at"`
 }
 
 // Add number plate
 func (e *Camera) Add(env *Env) (int64, error) {
 	// Add to database
 	res, err := env.DB.Exec(
-		"INSERT INTO cameras (name, ip_address, username, password, created_at, updated_at) VALUES (?, ?, ?, ?, DATE(), DATE())",
-		&e.Name, &e.IPAddress, &e.Username, &e.Password,
+		"INSERT INTO cameras (name, ip_address, username, password, schedule_id, camera_group_id, min_confidence, forward_is, loiter_count, loiter_minutes, tailgate_seconds, cooldown_minutes, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, DATE(), DATE())",
+		&e.Name, &e.IPAddress, &e.Username, &e.Password, &e.ScheduleID, &e.CameraGroupID, &e.MinConfidence, &e.ForwardIs, &e.LoiterCount, &e.LoiterMinutes, &e.TailgateSeconds, &e.CooldownMinutes,
 	)
 	if err != nil {
 		return 0, err
 	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	e.ID = int(id)
 	return res.RowsAffected()
 }
 
@@ -36,6 +54,9 @@ func (e *Camera) Get(env *Env) (*Camera, error) {
 	if err != nil {
 		return nil, err
 	}
+	if len(cameras) == 0 {
+		return nil, env.DB.ErrRecordNotFound
+	}
 	return &cameras[0], nil
 }
 
@@ -71,8 +92,8 @@ func (e *Camera) Find(env *Env, operator string, fields []WhereFields, perPage i
 func (e *Camera) Update(env *Env) (int64, error) {
 	// Update database
 	res, err := env.DB.Exec(
-		"UPDATE cameras SET name = ?, ip_address = ?, username = ?, password = ?, updated_at = DATE() WHERE id = ?",
-		&e.Name, &e.IPAddress, &e.Username, &e.Password, &e.ID,
+		"UPDATE cameras SET name = ?, ip_address = ?, username = ?, password = ?, schedule_id = ?, camera_group_id = ?, min_confidence = ?, forward_is = ?, loiter_count = ?, loiter_minutes = ?, tailgate_seconds = ?, cooldown_minutes = ?, updated_at = DATE() WHERE id = ?",
+		&e.Name, &e.IPAddress, &e.Username, &e.Password, &e.ScheduleID, &e.CameraGroupID, &e.MinConfidence, &e.ForwardIs, &e.LoiterCount, &e.LoiterMinutes, &e.TailgateSeconds, &e.CooldownMinutes, &e.ID,
 	)
 	if err != nil {
 		return 0, err
@@ -87,9 +108,100 @@ func (e *Camera) Delete(env *Env) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
+	// Remove the camera from any camera scopes
+	_, err = env.DB.Exec("DELETE FROM camera_scopes WHERE camera_id = ?", &e.ID)
+	if err != nil {
+		return 0, err
+	}
+	// Delete subscriptions
+	var subscription Subscription
+	if err := subscription.DeleteBy(env, []WhereFields{{"subject_type", "=", "camera"}, {"subject_id", "=", e.ID}}); err != nil {
+		return 0, err
+	}
+	// Delete outages
+	_, err = env.DB.Exec("DELETE FROM camera_outages WHERE camera_id = ?", &e.ID)
+	if err != nil {
+		return 0, err
+	}
 	return res.RowsAffected()
 }
 
+// DisplayName returns the camera's name, falling back to its IP address if it doesn't have one
+func (e *Camera) DisplayName() string {
+	if e.Name != "" {
+		return e.Name
+	}
+	return e.IPAddress
+}
+
+// Direction maps the forward or reverse direction reported by the camera to entry or exit, returning
+// an empty string if the camera isn't set up as an entry or exit camera
+func (e *Camera) Direction(direction string) string {
+	switch strings.ToLower(direction) {
+	case "forward":
+		return e.ForwardIs
+	case "reverse":
+		switch e.ForwardIs {
+		case "entry":
+			return "exit"
+		case "exit":
+			return "entry"
+		}
+	}
+	return ""
+}
+
+// TriggerOutput triggers one of the camera's alarm outputs using ISAPI
+func (e *Camera) TriggerOutput(outputID int) error {
+	body := `<IOPortData version="1.0" xmlns="http://www.hikvision.com/ver20/XMLSchema"><outputState>high</outputState></IOPortData>`
+	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf("http://%s/ISAPI/System/IO/outputs/%d/trigger", e.IPAddress, outputID), strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(e.Username, e.Password)
+	req.Header.Set("Content-Type", "application/xml")
+	client := http.Client{Timeout: 10 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("camera %s returned %s triggering output %d", e.IPAddress, res.Status, outputID)
+	}
+	return nil
+}
+
+// Snapshot gets a JPEG snapshot from one of the camera's channels using ISAPI
+func (e *Camera) Snapshot(channelID int) ([]byte, error) {
+	if channelID == 0 {
+		channelID = 1
+	}
+	return e.Picture(fmt.Sprintf("/ISAPI/Streaming/channels/%d01/picture", channelID))
+}
+
+// Picture gets a JPEG picture stored on the camera from the URL or path provided
+func (e *Camera) Picture(pictureURL string) ([]byte, error) {
+	if !strings.HasPrefix(pictureURL, "http://") && !strings.HasPrefix(pictureURL, "https://") {
+		pictureURL = fmt.Sprintf("http://%s/%s", e.IPAddress, strings.TrimLeft(pictureURL, "/"))
+	}
+	req, err := http.NewRequest(http.MethodGet, pictureURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(e.Username, e.Password)
+	client := http.Client{Timeout: 10 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("camera %s returned %s getting a picture", e.IPAddress, res.Status)
+	}
+	return io.ReadAll(io.LimitReader(res.Body, 10<<20))
+}
+
 // Migrate number plates
 func (e *Camera) Migrate(env *Env) (sql.Result, error) {
 	// Create table if not exists
@@ -100,6 +212,14 @@ func (e *Camera) Migrate(env *Env) (sql.Result, error) {
 		ip_address TEXT NOT NULL UNIQUE,
 		username TEXT NOT NULL,
 		password TEXT NOT NULL,
+		schedule_id INTEGER NOT NULL DEFAULT 0,
+		camera_group_id INTEGER NOT NULL DEFAULT 0,
+		min_confidence INTEGER NOT NULL DEFAULT 0,
+		forward_is TEXT NOT NULL DEFAULT '',
+		loiter_count INTEGER NOT NULL DEFAULT 0,
+		loiter_minutes INTEGER NOT NULL DEFAULT 0,
+		tailgate_seconds INTEGER NOT NULL DEFAULT 0,
+		cooldown_minutes INTEGER NOT NULL DEFAULT 0,
 		created_at TEXT NOT NULL DEFAULT 0,
 		updated_at TEXT NOT NULL DEFAULT 0
 	);
@@ -107,5 +227,30 @@ func (e *Camera) Migrate(env *Env) (sql.Result, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Add columns missing from older databases
+	if err := env.DB.AddColumn("cameras", "schedule_id", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	if err := env.DB.AddColumn("cameras", "camera_group_id", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	if err := env.DB.AddColumn("cameras", "min_confidence", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	if err := env.DB.AddColumn("cameras", "forward_is", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return nil, err
+	}
+	if err := env.DB.AddColumn("cameras", "loiter_count", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	if err := env.DB.AddColumn("cameras", "loiter_minutes", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	if err := env.DB.AddColumn("cameras", "tailgate_seconds", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	if err := env.DB.AddColumn("cameras", "cooldown_minutes", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
 	return res, nil
 }
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"database/sql"
+)
+
+// CameraGroup struct
+type CameraGroup struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name" validate:"required"`
+	LoiterCount   int    `json:"loiterCount" db:"loiter_count" validate:"min=0"`
+	LoiterMinutes int    `json:"loiterMinutes" db:"loiter_minutes" validate:"min=0"`
+	CreatedAt     string `json:"createdAt" db:"created_at"`
+	UpdatedAt     string `json:"updatedAt" db:"updated_at"`
+}
+
+// Add camera group
+func (e *CameraGroup) Add(env *Env) (int64, error) {
+	// Add to database
+	res, err := env.DB.Exec(
+		"INSERT INTO camera_groups (name, loiter_count, loiter_minutes, created_at, updated_at) VALUES (?, ?, ?, DATETIME('now', 'localtime'), DATETIME('now', 'localtime'))",
+		&e.Name, &e.LoiterCount, &e.LoiterMinutes,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	e.ID = int(id)
+	return res.RowsAffected()
+}
+
+// Get camera group by ID provided
+func (e *CameraGroup) Get(env *Env) (*CameraGroup, error) {
+	// Get from database
+	var cameraGroups []CameraGroup
+	err := env.DB.Query(&cameraGroups, "SELECT * FROM camera_groups WHERE id = ? LIMIT 1", &e.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(cameraGroups) == 0 {
+		return nil, env.DB.ErrRecordNotFound
+	}
+	return &cameraGroups[0], nil
+}
+
+// Find camera groups by fields provided
+func (e *CameraGroup) Find(env *Env, operator string, fields []WhereFields, perPage int, pageNumber int) (*[]CameraGroup, int, error) {
+	resCount := 0
+	// Where
+	whereSQL, values := env.DB.WhereSQL(operator, fields)
+	// Limit
+	limitSQL := env.DB.LimitSQL(perPage, pageNumber)
+	if limitSQL != "" {
+		// Get count from database
+		var cameraGroups []CameraGroup
+		err := env.DB.Query(&cameraGroups, "SELECT id FROM camera_groups"+whereSQL, values...)
+		if err != nil {
+			return nil, 0, err
+		}
+		resCount = len(cameraGroups)
+	}
+	// Get from database
+	var cameraGroups []CameraGroup
+	err := env.DB.Query(&cameraGroups, "SELECT * FROM camera_groups"+whereSQL+" ORDER BY name"+limitSQL, values...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resCount == 0 {
+		resCount = len(cameraGroups)
+	}
+	return &cameraGroups, resCount, nil
+}
+
+// Update camera group
+func (e *CameraGroup) Update(env *Env) (int64, error) {
+	// Update database
+	res, err := env.DB.Exec(
+		"UPDATE camera_groups SET name = ?, loiter_count = ?, loiter_minutes = ?, updated_at = DATETIME('now', 'localtime') WHERE id = ?",
+		&e.Name, &e.LoiterCount, &e.LoiterMinutes, &e.ID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Delete camera group
+func (e *CameraGroup) Delete(env *Env) (int64, error) {
+	// Delete from database
+	res, err := env.DB.Exec("DELETE FROM camera_groups WHERE id = ?", &e.ID)
+	if err != nil {
+		return 0, err
+	}
+	// Remove cameras from the camera group
+	_, err = env.DB.Exec("UPDATE cameras SET camera_group_id = 0 WHERE camera_group_id = ?", &e.ID)
+	if err != nil {
+		return 0, err
+	}
+	// Remove the camera group from any camera scopes
+	_, err = env.DB.Exec("DELETE FROM camera_scopes WHERE camera_group_id = ?", &e.ID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Migrate camera groups
+func (e *CameraGroup) Migrate(env *Env) (sql.Result, error) {
+	// Create table if not exists
+	res, err := env.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS camera_groups (
+		id INTEGER NOT NULL PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		loiter_count INTEGER NOT NULL DEFAULT 0,
+		loiter_minutes INTEGER NOT NULL DEFAULT 0,
+		created_at TEXT NOT NULL DEFAULT 0,
+		updated_at TEXT NOT NULL DEFAULT 0
+	);
+	`)
+	if err != nil {
+		return nil, err
+	}
+	// Add columns missing from older databases
+	if err := env.DB.AddColumn("camera_groups", "loiter_count", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	if err := env.DB.AddColumn("camera_groups", "loiter_minutes", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// CameraOutage is a period a camera was disconnected, which is open until it reconnects
+type CameraOutage struct {
+	ID        int    `json:"id"`
+	CameraID  int    `json:"cameraID" db:"camera_id"`
+	Error     string `json:"error"`
+	StartedAt string `json:"startedAt" db:"started_at"`
+	EndedAt   string `json:"endedAt" db:"ended_at"`
+}
+
+// Start records the camera provided going offline unless it already has an open outage
+func (e *CameraOutage) Start(env *Env, cameraID int, message string) error {
+	_, err := env.DB.Exec(
+		"INSERT INTO camera_outages (camera_id, error, started_at, ended_at) SELECT ?, ?, DATETIME('now', 'localtime'), '' WHERE NOT EXISTS (SELECT 1 FROM camera_outages WHERE camera_id = ? AND ended_at = '')",
+		cameraID, message, cameraID,
+	)
+	return err
+}
+
+// End closes the camera provided's open outage when it comes back online
+func (e *CameraOutage) End(env *Env, cameraID int) error {
+	_, err := env.DB.Exec("UPDATE camera_outages SET ended_at = DATETIME('now', 'localtime') WHERE camera_id = ? AND ended_at = ''", cameraID)
+	return err
+}
+
+// Downtime returns how long each camera was offline between the times provided, by camera ID
+func (e *CameraOutage) Downtime(env *Env, from, until time.Time) (map[int]time.Duration, error) {
+	var cameraOutages []CameraOutage
+	err := env.DB.Query(&cameraOutages, "SELECT * FROM camera_outages WHERE started_at < ? AND (ended_at = '' OR ended_at > ?)", until.Format(DateTimeFormat), from.Format(DateTimeFormat))
+	if err != nil {
+		return nil, err
+	}
+	downtime := map[int]time.Duration{}
+	for _, cameraOutage := range cameraOutages {
+		started, err := time.ParseInLocation(DateTimeFormat, cameraOutage.StartedAt, time.Local)
+		if err != nil {
+			continue
+		}
+		ended := time.Now()
+		if cameraOutage.EndedAt != "" {
+			if ended, err = time.ParseInLocation(DateTimeFormat, cameraOutage.EndedAt, time.Local); err != nil {
+				continue
+			}
+		}
+		if started.Before(from) {
+			started = from
+		}
+		if ended.After(until) {
+			ended = until
+		}
+		if ended.After(started) {
+			downtime[cameraOutage.CameraID] += ended.Sub(started)
+		}
+	}
+	return downtime, nil
+}
+
+// Migrate camera outages
+func (e *CameraOutage) Migrate(env *Env) (sql.Result, error) {
+	// Create table if not exists
+	res, err := env.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS camera_outages (
+		id INTEGER NOT NULL PRIMARY KEY,
+		camera_id INTEGER NOT NULL,
+		error TEXT NOT NULL DEFAULT '',
+		started_at TEXT NOT NULL DEFAULT 0,
+		ended_at TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS camera_outages_camera_id ON camera_outages (camera_id, ended_at);
+	`)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CameraScope struct
+type CameraScope struct {
+	ID            int    `json:"id"`
+	OwnerType     string `json:"ownerType" db:"owner_type"`
+	OwnerID       int    `json:"ownerID" db:"owner_id"`
+	CameraID      int    `json:"cameraID" db:"camera_id"`
+	CameraGroupID int    `json:"cameraGroupID" db:"camera_group_id"`
+}
+
+// Find camera scopes for the owner type and ID provided
+func (e *CameraScope) Find(env *Env, ownerType string, ownerID int) ([]CameraScope, error) {
+	// Get from database
+	var cameraScopes []CameraScope
+	err := env.DB.Query(&cameraScopes, "SELECT * FROM camera_scopes WHERE owner_type = ? AND owner_id = ?", ownerType, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	return cameraScopes, nil
+}
+
+// Set replaces the camera scopes for the owner type and ID provided
+func (e *CameraScope) Set(env *Env, ownerType string, ownerID int, cameraScopes []CameraScope) error {
+	// Delete existing scopes from database
+	if err := e.Delete(env, ownerType, ownerID); err != nil {
+		return err
+	}
+	// Add to database
+	for _, cameraScope := range cameraScopes {
+		_, err := env.DB.Exec(
+			"INSERT INTO camera_scopes (owner_type, owner_id, camera_id, camera_group_id) VALUES (?, ?, ?, ?)",
+			ownerType, ownerID, cameraScope.CameraID, cameraScope.CameraGroupID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete camera scopes for the owner type and ID provided
+func (e *CameraScope) Delete(env *Env, ownerType string, ownerID int) error {
+	_, err := env.DB.Exec("DELETE FROM camera_scopes WHERE owner_type = ? AND owner_id = ?", ownerType, ownerID)
+	return err
+}
+
+// Key returns the camera scope as a string in the format "camera:1" or "group:1"
+func (e *CameraScope) Key() string {
+	if e.CameraGroupID != 0 {
+		return fmt.Sprintf("group:%d", e.CameraGroupID)
+	}
+	return fmt.Sprintf("camera:%d", e.CameraID)
+}
+
+// ParseCameraScopes parses camera scopes in the format returned by Key
+func ParseCameraScopes(keys []string) []CameraScope {
+	var cameraScopes []CameraScope
+	for _, key := range keys {
+		scopeType, scopeID, _ := strings.Cut(key, ":")
+		id, err := strconv.Atoi(scopeID)
+		if err != nil || id == 0 {
+			continue
+		}
+		switch scopeType {
+		case "camera":
+			cameraScopes = append(cameraScopes, CameraScope{CameraID: id})
+		case "group":
+			cameraScopes = append(cameraScopes, CameraScope{CameraGroupID: id})
+		}
+	}
+	return cameraScopes
+}
+
+// CameraInScope checks if a camera is in the camera scopes for the owner type and ID provided,
+// treating no camera scopes as all cameras
+func CameraInScope(env *Env, ownerType string, ownerID int, cam Camera) (bool, error) {
+	var cameraScope CameraScope
+	cameraScopes, err := cameraScope.Find(env, ownerType, ownerID)
+	if err != nil {
+		return false, err
+	}
+	if len(cameraScopes) == 0 {
+		return true, nil
+	}
+	for _, s := range cameraScopes {
+		if s.CameraID != 0 && s.CameraID == cam.ID {
+			return true, nil
+		}
+		if s.CameraGroupID != 0 && s.CameraGroupID == cam.CameraGroupID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Migrate camera scopes
+func (e *CameraScope) Migrate(env *Env) (sql.Result, error) {
+	// Create table if not exists
+	res, err := env.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS camera_scopes (
+		id INTEGER NOT NULL PRIMARY KEY,
+		owner_type TEXT NOT NULL,
+		owner_id INTEGER NOT NULL,
+		camera_id INTEGER NOT NULL DEFAULT 0,
+		camera_group_id INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS camera_scopes_owner ON camera_scopes (owner_type, owner_id);
+	`)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
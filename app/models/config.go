@@ -2,17 +2,32 @@ package models
 
 // Config struct
 type Config struct {
-	HTTPHost          string
-	HTTPPort          string
-	ExternalURL       string
-	SessionKey        string // SessionKey must be 16, 24 or 32 bytes long (AES-128, AES-192 or AES-256)
-	SessionCookieName string
-	PerPage           string
-	SMTPHost          string
-	SMTPPort          string
-	SMTPUser          string
-	SMTPPass          string
-	SMTPAuth          string
-	SMTPFrom          string
-	DBFile            string
+	HTTPHost             string
+	HTTPPort             string
+	ExternalURL          string
+	SessionKey           string // SessionKey must be 16, 24 or 32 bytes long (AES-128, AES-192 or AES-256)
+	SessionCookieName    string
+	PerPage              string
+	SMTPHost             string
+	SMTPPort             string
+	SMTPUser             string
+	SMTPPass             string
+	SMTPAuth             string
+	SMTPFrom             string
+	DBFile               string
+	ExpiredPlates        string // ExpiredPlates must be keep, archive or delete
+	PlateExpiryDays      string
+	VisitTimeoutHours    string
+	DwellMinutes         string
+	DwellUnknownOnly     string // DwellUnknownOnly must be true or false
+	AlertCooldownMinutes string
+	AlertRateLimit       string // AlertRateLimit is the maximum number of alerts per recipient per hour, 0 for no limit
+	WebhookRetentionDays string // WebhookRetentionDays is how long to keep finished webhook deliveries, 0 to keep them forever
+	OutboxRetentionDays  string // OutboxRetentionDays is how long to keep sent and failed outbox messages, 0 to keep them forever
+	MQTTBroker           string // MQTTBroker is the broker URL, e.g. tcp://localhost:1883, or empty to disable MQTT
+	MQTTUser             string
+	MQTTPass             string
+	MQTTClientID         string
+	MQTTTopicPrefix      string
+	MQTTDiscoveryPrefix  string
 }
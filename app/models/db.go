@@ -14,6 +14,9 @@ import (
 	"time"
 )
 
+// DateTimeFormat is the layout date times are stored in the database with
+const DateTimeFormat = "2006-01-02 15:04:05"
+
 type DB struct {
 	Conn              *sqlx.DB
 	cache             cachego.Cache
@@ -124,6 +127,20 @@ func (d *DB) LimitSQL(perPage int, pageNumber int) string {
 	return limitSQL
 }
 
+// AddColumn adds a column to an existing table if it does not already exist.
+func (d *DB) AddColumn(table, column, definition string) error {
+	var columns []string
+	err := d.Query(&columns, "SELECT name FROM pragma_table_info(?) WHERE name = ?", table, column)
+	if err != nil {
+		return err
+	}
+	if len(columns) > 0 {
+		return nil
+	}
+	_, err = d.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
 func (d *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
 	d.logger.Println(query)
 	return d.Conn.Exec(query, args...)
@@ -191,6 +208,113 @@ func (d *DB) Migrate(env *Env) error {
 	if _, err := adminLog.Migrate(env); err != nil {
 		return err
 	}
+	// Tables from here on store created_at and updated_at with a time of day in local time, like
+	// detection event times, as they're compared with event times and used to time escalations, digests
+	// and retention rather than only shown
+	schedule := Schedule{}
+	if _, err := schedule.Migrate(env); err != nil {
+		return err
+	}
+	watchlist := Watchlist{}
+	if _, err := watchlist.Migrate(env); err != nil {
+		return err
+	}
+	cameraGroup := CameraGroup{}
+	if _, err := cameraGroup.Migrate(env); err != nil {
+		return err
+	}
+	cameraScope := CameraScope{}
+	if _, err := cameraScope.Migrate(env); err != nil {
+		return err
+	}
+	detection := Detection{}
+	if _, err := detection.Migrate(env); err != nil {
+		return err
+	}
+	vehicleWatch := VehicleWatch{}
+	if _, err := vehicleWatch.Migrate(env); err != nil {
+		return err
+	}
+	rule := Rule{}
+	if _, err := rule.Migrate(env); err != nil {
+		return err
+	}
+	ruleHit := RuleHit{}
+	if _, err := ruleHit.Migrate(env); err != nil {
+		return err
+	}
+	visit := Visit{}
+	if _, err := visit.Migrate(env); err != nil {
+		return err
+	}
+	expectedArrival := ExpectedArrival{}
+	if _, err := expectedArrival.Migrate(env); err != nil {
+		return err
+	}
+	notificationChannel := NotificationChannel{}
+	if _, err := notificationChannel.Migrate(env); err != nil {
+		return err
+	}
+	notificationTarget := NotificationTarget{}
+	if _, err := notificationTarget.Migrate(env); err != nil {
+		return err
+	}
+	subscription := Subscription{}
+	if _, err := subscription.Migrate(env); err != nil {
+		return err
+	}
+	webhook := Webhook{}
+	if _, err := webhook.Migrate(env); err != nil {
+		return err
+	}
+	webhookDelivery := WebhookDelivery{}
+	if _, err := webhookDelivery.Migrate(env); err != nil {
+		return err
+	}
+	outboxMessage := OutboxMessage{}
+	if _, err := outboxMessage.Migrate(env); err != nil {
+		return err
+	}
+	alert := Alert{}
+	if _, err := alert.Migrate(env); err != nil {
+		return err
+	}
+	escalationPolicy := EscalationPolicy{}
+	if _, err := escalationPolicy.Migrate(env); err != nil {
+		return err
+	}
+	escalationStep := EscalationStep{}
+	if _, err := escalationStep.Migrate(env); err != nil {
+		return err
+	}
+	escalationStepUser := EscalationStepUser{}
+	if _, err := escalationStepUser.Migrate(env); err != nil {
+		return err
+	}
+	cameraOutage := CameraOutage{}
+	if _, err := cameraOutage.Migrate(env); err != nil {
+		return err
+	}
+	quietPeriod := QuietPeriod{}
+	if _, err := quietPeriod.Migrate(env); err != nil {
+		return err
+	}
+	messageTemplate := MessageTemplate{}
+	if _, err := messageTemplate.Migrate(env); err != nil {
+		return err
+	}
+	snapshot := Snapshot{}
+	if _, err := snapshot.Migrate(env); err != nil {
+		return err
+	}
+	alertCooldown := AlertCooldown{}
+	if _, err := alertCooldown.Migrate(env); err != nil {
+		return err
+	}
+	alertRate := AlertRate{}
+	if _, err := alertRate.Migrate(env); err != nil {
+		return err
+	}
 
 	// Add default user if none exist
 	_, resCount, err := user.Find(env, "AND", []WhereFields{}, 0, 1)
@@ -0,0 +1,178 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Detection struct
+type Detection struct {
+	ID            int    `json:"id"`
+	CameraID      int    `json:"cameraID" db:"camera_id"`
+	NumberPlateID int    `json:"numberPlateID" db:"number_plate_id"`
+	Plate         string `json:"plate"`
+	Confidence    int    `json:"confidence"`
+	Direction     string `json:"direction"`
+	EventTime     string `json:"eventTime" db:"event_time"`
+	LowConfidence bool   `json:"lowConfidence" db:"low_confidence"`
+	Reviewed      bool   `json:"reviewed"`
+	Alerted       bool   `json:"alerted"`
+	Tags          string `json:"tags"`
+	VehicleAttributes
+	CreatedAt string `json:"createdAt" db:"created_at"`
+}
+
+// Add detection
+func (e *Detection) Add(env *Env) (int64, error) {
+	// Add to database
+	res, err := env.DB.Exec(
+		"INSERT INTO detections (camera_id, number_plate_id, plate, confidence, direction, event_time, low_confidence, reviewed, alerted, tags, vehicle_type, colour, brand, model, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, DATETIME('now', 'localtime'))",
+		&e.CameraID, &e.NumberPlateID, &e.Plate, &e.Confidence, &e.Direction, &e.EventTime, &e.LowConfidence, &e.Reviewed, &e.Alerted, &e.Tags, &e.VehicleType, &e.Colour, &e.Brand, &e.Model,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	e.ID = int(id)
+	return res.RowsAffected()
+}
+
+// Get detection by ID provided
+func (e *Detection) Get(env *Env) (*Detection, error) {
+	// Get from database
+	var detections []Detection
+	err := env.DB.Query(&detections, "SELECT * FROM detections WHERE id = ? LIMIT 1", &e.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(detections) == 0 {
+		return nil, env.DB.ErrRecordNotFound
+	}
+	return &detections[0], nil
+}
+
+// Find detections by fields provided
+func (e *Detection) Find(env *Env, operator string, fields []WhereFields, perPage int, pageNumber int) (*[]Detection, int, error) {
+	resCount := 0
+	// Where
+	whereSQL, values := env.DB.WhereSQL(operator, fields)
+	// Limit
+	limitSQL := env.DB.LimitSQL(perPage, pageNumber)
+	if limitSQL != "" {
+		// Get count from database
+		var detections []Detection
+		err := env.DB.Query(&detections, "SELECT id FROM detections"+whereSQL, values...)
+		if err != nil {
+			return nil, 0, err
+		}
+		resCount = len(detections)
+	}
+	// Get from database
+	var detections []Detection
+	err := env.DB.Query(&detections, "SELECT * FROM detections"+whereSQL+" ORDER BY event_time DESC, id DESC"+limitSQL, values...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resCount == 0 {
+		resCount = len(detections)
+	}
+	return &detections, resCount, nil
+}
+
+// Review marks a detection as reviewed
+func (e *Detection) Review(env *Env) (int64, error) {
+	// Update database
+	res, err := env.DB.Exec("UPDATE detections SET reviewed = 1 WHERE id = ?", &e.ID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// CountByPlate counts the detections of the number plate provided since the time provided
+func (e *Detection) CountByPlate(env *Env, plate string, since time.Time) (int, error) {
+	var counts []int
+	err := env.DB.Query(&counts, "SELECT COUNT(*) FROM detections WHERE plate = ? AND event_time >= ?", plate, since.Format(DateTimeFormat))
+	if err != nil || len(counts) == 0 {
+		return 0, err
+	}
+	return counts[0], nil
+}
+
+// CountByPlateAtCameras counts the detections of the number plate provided at the cameras provided
+// since the time provided, excluding low confidence reads
+func (e *Detection) CountByPlateAtCameras(env *Env, plate string, cameraIDs []int, since time.Time) (int, error) {
+	var counts []int
+	err := env.DB.Query(&counts, "SELECT COUNT(*) FROM detections WHERE plate = ? AND camera_id IN (?) AND event_time >= ? AND low_confidence = 0", plate, cameraIDs, since.Format(DateTimeFormat))
+	if err != nil || len(counts) == 0 {
+		return 0, err
+	}
+	return counts[0], nil
+}
+
+// AddTag adds a tag to the detection's comma separated tags if it doesn't already have it
+func (e *Detection) AddTag(tag string) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return
+	}
+	tags := e.TagList()
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return
+		}
+	}
+	e.Tags = strings.Join(append(tags, tag), ",")
+}
+
+// TagList returns the detection's tags as a list
+func (e *Detection) TagList() []string {
+	var tags []string
+	for _, tag := range strings.Split(e.Tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// Migrate detections
+func (e *Detection) Migrate(env *Env) (sql.Result, error) {
+	// Create table if not exists
+	res, err := env.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS detections (
+		id INTEGER NOT NULL PRIMARY KEY,
+		camera_id INTEGER NOT NULL DEFAULT 0,
+		number_plate_id INTEGER NOT NULL DEFAULT 0,
+		plate TEXT NOT NULL,
+		confidence INTEGER NOT NULL DEFAULT 0,
+		direction TEXT NOT NULL DEFAULT '',
+		event_time TEXT NOT NULL DEFAULT 0,
+		low_confidence INTEGER NOT NULL DEFAULT 0,
+		reviewed INTEGER NOT NULL DEFAULT 0,
+		alerted INTEGER NOT NULL DEFAULT 0,
+		tags TEXT NOT NULL DEFAULT '',
+		vehicle_type TEXT NOT NULL DEFAULT '',
+		colour TEXT NOT NULL DEFAULT '',
+		brand TEXT NOT NULL DEFAULT '',
+		model TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS detections_event_time ON detections (event_time);
+	CREATE INDEX IF NOT EXISTS detections_plate ON detections (plate, event_time);
+	`)
+	if err != nil {
+		return nil, err
+	}
+	// Add columns missing from older databases
+	for _, column := range []string{"direction", "tags", "vehicle_type", "colour", "brand", "model"} {
+		if err := env.DB.AddColumn("detections", column, "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,283 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/matcornic/hermes/v2"
+	"strings"
+	"time"
+)
+
+// digestTopPlates is the number of plates listed for each of the digest's plate summaries
+const digestTopPlates = 10
+
+// Digest summarises the detections, alerts and camera downtime for a digest subscription's period
+type Digest struct {
+	Title         string
+	From          time.Time
+	Until         time.Time
+	Cameras       []DigestCamera
+	Detections    int
+	Alerts        int
+	MatchedPlates []DigestPlate
+	UnknownReads  int
+	UnknownPlates []DigestPlate
+}
+
+// DigestCamera is a camera's detection count and downtime in a digest
+type DigestCamera struct {
+	Name       string
+	Detections int
+	Downtime   time.Duration
+}
+
+// DigestPlate is a number plate and how many times it was counted in a digest
+type DigestPlate struct {
+	Plate string `db:"plate"`
+	Count int    `db:"count"`
+}
+
+// digestCameraCount is the number of detections by a camera
+type digestCameraCount struct {
+	CameraID int `db:"camera_id"`
+	Count    int `db:"count"`
+}
+
+// DigestPeriod returns the start and end of the subscription's latest digest period to have ended by the
+// time provided
+func (e *Subscription) DigestPeriod(now time.Time) (time.Time, time.Time) {
+	until := time.Date(now.Year(), now.Month(), now.Day(), e.DigestHour, 0, 0, 0, now.Location())
+	if until.After(now) {
+		until = until.AddDate(0, 0, -1)
+	}
+	if e.Frequency == "weekly" {
+		for until.Weekday() != time.Monday {
+			until = until.AddDate(0, 0, -1)
+		}
+		return until.AddDate(0, 0, -7), until
+	}
+	return until.AddDate(0, 0, -1), until
+}
+
+// Digest builds the subscription's digest for the period provided
+func (e *Subscription) Digest(env *Env, from, until time.Time) (*Digest, error) {
+	digest := Digest{From: from, Until: until}
+	title, err := e.digestTitle(env)
+	if err != nil {
+		return nil, err
+	}
+	digest.Title = title
+	fromTime, untilTime := from.Format(DateTimeFormat), until.Format(DateTimeFormat)
+	// Only include the subscribed camera's detections
+	detectionSQL := " WHERE event_time >= ? AND event_time < ?"
+	detectionValues := []interface{}{fromTime, untilTime}
+	if e.SubjectType == "camera" {
+		detectionSQL += " AND camera_id = ?"
+		detectionValues = append(detectionValues, e.SubjectID)
+	}
+	// Count detections by camera
+	var cameraCounts []digestCameraCount
+	err = env.DB.Query(&cameraCounts, "SELECT camera_id, COUNT(*) AS count FROM detections"+detectionSQL+" GROUP BY camera_id", detectionValues...)
+	if err != nil {
+		return nil, err
+	}
+	counts := map[int]int{}
+	for _, cameraCount := range cameraCounts {
+		counts[cameraCount.CameraID] = cameraCount.Count
+		digest.Detections += cameraCount.Count
+	}
+	var cameraOutage CameraOutage
+	downtime, err := cameraOutage.Downtime(env, from, until)
+	if err != nil {
+		return nil, err
+	}
+	var camera Camera
+	resCameras, _, err := camera.Find(env, "AND", []WhereFields{}, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, resCamera := range *resCameras {
+		if e.SubjectType == "camera" && resCamera.ID != e.SubjectID {
+			continue
+		}
+		digest.Cameras = append(digest.Cameras, DigestCamera{Name: resCamera.DisplayName(), Detections: counts[resCamera.ID], Downtime: downtime[resCamera.ID]})
+	}
+	// Count the reads of plates that aren't registered
+	var unknownPlates []DigestPlate
+	err = env.DB.Query(&unknownPlates, "SELECT plate, COUNT(*) AS count FROM detections"+detectionSQL+" AND number_plate_id = 0 AND low_confidence = 0 GROUP BY plate ORDER BY count DESC, plate", detectionValues...)
+	if err != nil {
+		return nil, err
+	}
+	for _, unknownPlate := range unknownPlates {
+		digest.UnknownReads += unknownPlate.Count
+	}
+	digest.UnknownPlates = unknownPlates
+	// Count the alerts for the subscribed watchlist, camera or rule by plate
+	alertSQL := " WHERE created_at >= ? AND created_at < ?"
+	alertValues := []interface{}{fromTime, untilTime}
+	if e.SubjectType != "all" {
+		alertSQL += fmt.Sprintf(" AND %s_id = ?", e.SubjectType)
+		alertValues = append(alertValues, e.SubjectID)
+	}
+	var alertCounts []int
+	err = env.DB.Query(&alertCounts, "SELECT COUNT(*) FROM alerts"+alertSQL, alertValues...)
+	if err != nil {
+		return nil, err
+	}
+	if len(alertCounts) > 0 {
+		digest.Alerts = alertCounts[0]
+	}
+	err = env.DB.Query(&digest.MatchedPlates, "SELECT plate, COUNT(*) AS count FROM alerts"+alertSQL+" AND plate != '' GROUP BY plate ORDER BY count DESC, plate", alertValues...)
+	if err != nil {
+		return nil, err
+	}
+	return &digest, nil
+}
+
+// digestTitle returns the title of the subscription's digest, including the watchlist, camera or rule
+// it's for
+func (e *Subscription) digestTitle(env *Env) (string, error) {
+	title := SubscriptionFrequencies[e.Frequency]
+	var name string
+	switch e.SubjectType {
+	case "watchlist":
+		watchlist := Watchlist{ID: e.SubjectID}
+		resWatchlist, err := watchlist.Get(env)
+		if err != nil {
+			return "", err
+		}
+		name = "watchlist " + resWatchlist.Name
+	case "camera":
+		camera := Camera{ID: e.SubjectID}
+		resCamera, err := camera.Get(env)
+		if err != nil {
+			return "", err
+		}
+		name = resCamera.DisplayName()
+	case "rule":
+		rule := Rule{ID: e.SubjectID}
+		resRule, err := rule.Get(env)
+		if err != nil {
+			return "", err
+		}
+		name = "rule " + resRule.Name
+	}
+	if name != "" {
+		title += " for " + name
+	}
+	return title, nil
+}
+
+// Email returns the digest as an email to the address provided
+func (e *Digest) Email(env *Env, to string) Email {
+	period := fmt.Sprintf("%s to %s", e.From.Format("Mon 2 Jan 15:04"), e.Until.Format("Mon 2 Jan 15:04"))
+	body := hermes.Body{
+		Title:     e.Title,
+		Intros:    []string{fmt.Sprintf("Here's what the cameras saw from %s.", period)},
+		Signature: "Thanks",
+	}
+	for _, camera := range e.Cameras {
+		downtime := "None"
+		if camera.Downtime > 0 {
+			minutes := int(camera.Downtime.Round(time.Minute).Minutes())
+			downtime = fmt.Sprintf("%dh %02dm", minutes/60, minutes%60)
+		}
+		body.Table.Data = append(body.Table.Data, []hermes.Entry{
+			{Key: "Camera", Value: camera.Name},
+			{Key: "Detections", Value: fmt.Sprint(camera.Detections)},
+			{Key: "Downtime", Value: downtime},
+		})
+	}
+	body.Dictionary = []hermes.Entry{
+		{Key: "Detections", Value: fmt.Sprint(e.Detections)},
+		{Key: "Alerts", Value: fmt.Sprint(e.Alerts)},
+		{Key: "Matched Plates", Value: digestPlates(e.MatchedPlates)},
+		{Key: "Unknown Plates", Value: fmt.Sprintf("%d %s of %d %s", e.UnknownReads, plural(e.UnknownReads, "read", "reads"), len(e.UnknownPlates), plural(len(e.UnknownPlates), "plate", "plates"))},
+		{Key: "Most Read Unknown Plates", Value: digestPlates(e.UnknownPlates)},
+	}
+	if env.Config.ExternalURL != "" {
+		body.Actions = []hermes.Action{
+			{
+				Instructions: "To see the detections, click here:",
+				Button: hermes.Button{
+					Color:     "#4285f4",
+					TextColor: "#fff",
+					Text:      "View in Hikvision ANPR Alerts",
+					Link:      strings.TrimRight(env.Config.ExternalURL, "/") + "/detections",
+				},
+			},
+		}
+	}
+	return Email{
+		To:      to,
+		Subject: fmt.Sprintf("%s: %s", e.Title, period),
+		Body:    body,
+	}
+}
+
+// digestPlates lists the most counted plates provided with their counts
+func digestPlates(plates []DigestPlate) string {
+	if len(plates) == 0 {
+		return "None"
+	}
+	var list []string
+	for i, plate := range plates {
+		if i == digestTopPlates {
+			list = append(list, fmt.Sprintf("and %d more", len(plates)-i))
+			break
+		}
+		list = append(list, fmt.Sprintf("%s (%d)", plate.Plate, plate.Count))
+	}
+	return strings.Join(list, ", ")
+}
+
+// SendDigests emails each digest subscription's digest once its period has ended. A new subscription's
+// first digest is for the period after it was added.
+func SendDigests(env *Env) error {
+	if env.Config.SMTPHost == "" || env.Config.SMTPFrom == "" {
+		return nil
+	}
+	var subscription Subscription
+	resSubscriptions, _, err := subscription.Find(env, "AND", []WhereFields{{"frequency", "!=", ""}}, 0, 1)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var digestErrors []error
+	for _, resSubscription := range *resSubscriptions {
+		from, until := resSubscription.DigestPeriod(now)
+		if resSubscription.LastDigestAt >= until.Format(DateTimeFormat) {
+			continue
+		}
+		if resSubscription.LastDigestAt != "" {
+			if err := resSubscription.sendDigest(env, from, until); err != nil {
+				digestErrors = append(digestErrors, fmt.Errorf("error sending digest for subscription %d: %w", resSubscription.ID, err))
+				continue
+			}
+		}
+		resSubscription.LastDigestAt = until.Format(DateTimeFormat)
+		if _, err := resSubscription.Update(env); err != nil {
+			digestErrors = append(digestErrors, err)
+		}
+	}
+	return errors.Join(digestErrors...)
+}
+
+// sendDigest emails the subscription's digest for the period provided to its user
+func (e *Subscription) sendDigest(env *Env, from, until time.Time) error {
+	user := User{ID: e.UserID}
+	resUser, err := user.Get(env)
+	if err != nil {
+		return err
+	}
+	digest, err := e.Digest(env, from, until)
+	if err != nil {
+		return err
+	}
+	email := digest.Email(env, resUser.Email)
+	if err := email.Send(env); err != nil {
+		return err
+	}
+	env.Logger.Printf("Sent %q to %s\n", email.Subject, resUser.Email)
+	return nil
+}
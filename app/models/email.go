@@ -1,56 +1,251 @@
 package models
 
 import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
 	"errors"
 	"fmt"
-	"github.com/containrrr/shoutrrr"
-	"github.com/containrrr/shoutrrr/pkg/types"
 	"github.com/matcornic/hermes/v2"
-	"net/url"
+	"html"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
 	"time"
 )
 
+// EmailSnapshot is the HTML to show an email's snapshot, which is attached inline
+const EmailSnapshot = `<p><img src="cid:snapshot" alt="Snapshot" width="100%" /></p>`
+
 // Email struct
 type Email struct {
 	To      string
 	Subject string
 	Body    hermes.Body
+	// Snapshot is a JPEG attached inline, the body should include EmailSnapshot to show it
+	Snapshot []byte
 }
 
 // Send Email
 func (e *Email) Send(env *Env) error {
-	if env.Config.SMTPHost != "" && env.Config.SMTPFrom != "" {
-		// Configure hermes by setting the header & footer of e-mails
-		h := hermes.Hermes{
-			Product: hermes.Product{
-				Name:      "Hikvision ANPR Alerts",
-				Link:      env.Config.ExternalURL,
-				Copyright: fmt.Sprintf("Copyright © %s Hikvision ANPR Alerts. All rights reserved.", time.Now().Format("2006")),
-			},
-		}
-		// Generate an HTML email
-		emailBody, err := h.GenerateHTML(hermes.Email{Body: e.Body})
+	if env.Config.SMTPHost == "" || env.Config.SMTPFrom == "" {
+		return errors.New("no SMTP configured")
+	}
+	message, err := e.message(env)
+	if err != nil {
+		return err
+	}
+	client, err := smtpClient(env)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err := client.Mail(env.Config.SMTPFrom); err != nil {
+		return fmt.Errorf("error setting sender: %w", err)
+	}
+	if err := client.Rcpt(e.To); err != nil {
+		return fmt.Errorf("error setting recipient: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("error opening data stream: %w", err)
+	}
+	if _, err := w.Write(message); err != nil {
+		return fmt.Errorf("error writing message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("error sending message: %w", err)
+	}
+	return client.Quit()
+}
+
+// message returns the email as a MIME message with HTML and plain text versions and the snapshot
+// attached inline
+func (e *Email) message(env *Env) ([]byte, error) {
+	// Configure hermes by setting the header & footer of e-mails
+	h := hermes.Hermes{
+		Product: hermes.Product{
+			Name:      "Hikvision ANPR Alerts",
+			Link:      env.Config.ExternalURL,
+			Copyright: fmt.Sprintf("Copyright © %s Hikvision ANPR Alerts. All rights reserved.", time.Now().Format("2006")),
+		},
+	}
+	htmlBody, err := h.GenerateHTML(hermes.Email{Body: e.Body})
+	if err != nil {
+		return nil, err
+	}
+	textBody, err := h.GeneratePlainText(hermes.Email{Body: e.Body})
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	from := mail.Address{Name: "Hikvision ANPR Alerts", Address: env.Config.SMTPFrom}
+	fmt.Fprintf(&b, "From: %s\r\n", from.String())
+	fmt.Fprintf(&b, "To: %s\r\n", e.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", e.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	alternative := multipart.NewWriter(&b)
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", alternative.Boundary())
+
+	if err := writeQuotedPrintablePart(alternative, "text/plain; charset=utf-8", textBody); err != nil {
+		return nil, err
+	}
+	if len(e.Snapshot) == 0 {
+		if err := writeQuotedPrintablePart(alternative, "text/html; charset=utf-8", htmlBody); err != nil {
+			return nil, err
+		}
+	} else {
+		// Attach the snapshot inline as mail clients block images in data URIs
+		var related bytes.Buffer
+		relatedWriter := multipart.NewWriter(&related)
+		if err := writeQuotedPrintablePart(relatedWriter, "text/html; charset=utf-8", htmlBody); err != nil {
+			return nil, err
+		}
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", "image/jpeg")
+		header.Set("Content-Transfer-Encoding", "base64")
+		header.Set("Content-ID", "<snapshot>")
+		header.Set("Content-Disposition", `inline; filename="snapshot.jpg"`)
+		part, err := relatedWriter.CreatePart(header)
 		if err != nil {
-			return err
-		}
-		// Create sender
-		senderURL := fmt.Sprintf("%s:%s", env.Config.SMTPHost, env.Config.SMTPPort)
-		if env.Config.SMTPUser != "" {
-			if env.Config.SMTPPass != "" {
-				senderURL = fmt.Sprintf("%s:%s@%s", env.Config.SMTPUser, env.Config.SMTPPass, senderURL)
-			} else {
-				senderURL = fmt.Sprintf("%s@%s", env.Config.SMTPUser, senderURL)
-			}
+			return nil, err
 		}
-		senderURL = fmt.Sprintf("smtp://%s/?usehtml=yes&auth=%s&fromaddress=%s&fromname=%s&subject=%s&toaddresses=%s", senderURL, url.QueryEscape(env.Config.SMTPAuth), url.QueryEscape(env.Config.SMTPFrom), url.QueryEscape("Hikvision ANPR Alerts"), url.QueryEscape(e.Subject), url.QueryEscape(e.To))
-		sender, err := shoutrrr.NewSender(env.Logger, senderURL)
+		encoded := base64.StdEncoding.EncodeToString(e.Snapshot)
+		for len(encoded) > 76 {
+			fmt.Fprintf(part, "%s\r\n", encoded[:76])
+			encoded = encoded[76:]
+		}
+		fmt.Fprintf(part, "%s\r\n", encoded)
+		if err := relatedWriter.Close(); err != nil {
+			return nil, err
+		}
+		header = textproto.MIMEHeader{}
+		header.Set("Content-Type", fmt.Sprintf("multipart/related; boundary=%s", relatedWriter.Boundary()))
+		part, err = alternative.CreatePart(header)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		// Send email instantly
-		sender.Send(emailBody, (*types.Params)(&map[string]string{"title": e.Subject}))
+		if _, err := part.Write(related.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+	if err := alternative.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// writeQuotedPrintablePart writes a quoted-printable part with the content type provided
+func writeQuotedPrintablePart(w *multipart.Writer, contentType, content string) error {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write([]byte(content)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// smtpClient connects and authenticates to the SMTP server in the config, using implicit TLS on port
+// 465 and STARTTLS when the server supports it on other ports
+func smtpClient(env *Env) (*smtp.Client, error) {
+	host := env.Config.SMTPHost
+	addr := net.JoinHostPort(host, env.Config.SMTPPort)
+	var conn net.Conn
+	var err error
+	if env.Config.SMTPPort == "465" {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", addr, &tls.Config{ServerName: host})
 	} else {
-		return errors.New("no SMTP configured")
+		conn, err = net.DialTimeout("tcp", addr, 30*time.Second)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to server: %w", err)
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error creating SMTP client: %w", err)
+	}
+	if env.Config.SMTPPort != "465" {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+				client.Close()
+				return nil, fmt.Errorf("error enabling STARTTLS: %w", err)
+			}
+		}
+	}
+	if auth := smtpAuth(env.Config); auth != nil {
+		if err := client.Auth(auth); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("error authenticating: %w", err)
+		}
+	}
+	return client, nil
+}
+
+// smtpAuth returns the authentication mechanism for the SMTP auth in the config, or nil to not
+// authenticate
+func smtpAuth(config Config) smtp.Auth {
+	switch strings.ToLower(config.SMTPAuth) {
+	case "none":
+		return nil
+	case "crammd5":
+		return smtp.CRAMMD5Auth(config.SMTPUser, config.SMTPPass)
+	case "oauth2":
+		return oauth2Auth{username: config.SMTPUser, token: config.SMTPPass}
+	}
+	// Plain, or unknown which uses plain if there's a user
+	if config.SMTPUser == "" {
+		return nil
+	}
+	return smtp.PlainAuth("", config.SMTPUser, config.SMTPPass, config.SMTPHost)
+}
+
+// oauth2Auth implements the XOAUTH2 SMTP authentication mechanism
+type oauth2Auth struct {
+	username string
+	token    string
+}
+
+// Start begins XOAUTH2 authentication with the server
+func (a oauth2Auth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	return "XOAUTH2", []byte(fmt.Sprintf("user=%s\x01auth=Bearer %s\x01\x01", a.username, a.token)), nil
+}
+
+// Next continues XOAUTH2 authentication, returning an error if the server sent a challenge
+func (a oauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		return nil, fmt.Errorf("unexpected server challenge: %s", fromServer)
+	}
+	return nil, nil
+}
+
+// emailButton returns the HTML for a button linking to the URL provided, styled like the buttons of
+// hermes actions, which aren't shown in emails with a markdown body
+func emailButton(text, link string) string {
+	return fmt.Sprintf(`<table class="body-action" align="center" width="100%%" cellpadding="0" cellspacing="0"><tr><td align="center"><div><a href="%s" class="button" style="background-color: #4285f4; color: #fff; width: %dpx;" target="_blank">%s</a></div></td></tr></table>`, html.EscapeString(link), len(text)*9+20, html.EscapeString(text))
+}
+
+// escapeMarkdown escapes text so it's shown as is in markdown, including any HTML
+func escapeMarkdown(text string) string {
+	var b strings.Builder
+	for _, r := range html.EscapeString(text) {
+		if strings.ContainsRune("\\`*_{}[]()#+-.!|~", r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
 	}
-	return nil
+	return b.String()
 }
@@ -0,0 +1,343 @@
+package models
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"github.com/matcornic/hermes/v2"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// smtpServer is a fake SMTP server that accepts one connection and records the commands, authentication
+// and message it receives
+type smtpServer struct {
+	host     string
+	port     string
+	commands []string
+	auth     []string
+	data     []byte
+	done     chan struct{}
+}
+
+// newSMTPServer starts a fake SMTP server which advertises the auth mechanisms provided, its fields
+// shouldn't be read until done is closed
+func newSMTPServer(t *testing.T, auth string) *smtpServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	server := &smtpServer{host: host, port: port, done: make(chan struct{})}
+	go func() {
+		defer close(server.done)
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		c := textproto.NewConn(conn)
+		c.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := c.ReadLine()
+			if err != nil {
+				return
+			}
+			server.commands = append(server.commands, line)
+			command := strings.ToUpper(strings.Fields(line)[0])
+			switch {
+			case command == "EHLO":
+				c.PrintfLine("250-localhost")
+				c.PrintfLine("250 AUTH %s", auth)
+			case command == "AUTH" && line == "AUTH CRAM-MD5":
+				c.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(cramMD5Challenge)))
+				response, _ := c.ReadLine()
+				server.auth = append(server.auth, line, response)
+				c.PrintfLine("235 Authenticated")
+			case command == "AUTH":
+				server.auth = append(server.auth, line)
+				c.PrintfLine("235 Authenticated")
+			case command == "DATA":
+				c.PrintfLine("354 Go ahead")
+				server.data, _ = c.ReadDotBytes()
+				c.PrintfLine("250 Queued")
+			case command == "QUIT":
+				c.PrintfLine("221 Bye")
+				return
+			default:
+				c.PrintfLine("250 OK")
+			}
+		}
+	}()
+	return server
+}
+
+// cramMD5Challenge is the challenge sent by the fake SMTP server for CRAM-MD5 authentication
+const cramMD5Challenge = "<1@localhost>"
+
+func TestEmailSend(t *testing.T) {
+	digest := hmac.New(md5.New, []byte("secret"))
+	digest.Write([]byte(cramMD5Challenge))
+	tests := []struct {
+		name     string
+		user     string
+		pass     string
+		smtpAuth string
+		auth     []string
+	}{
+		{"no user", "", "", "", nil},
+		{"plain", "anpr", "secret", "plain", []string{"AUTH PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00anpr\x00secret"))}},
+		{"default is plain", "anpr", "secret", "", []string{"AUTH PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00anpr\x00secret"))}},
+		{"none", "anpr", "secret", "None", nil},
+		{"oauth2", "anpr@example.com", "token", "OAuth2", []string{"AUTH XOAUTH2 " + base64.StdEncoding.EncodeToString([]byte("user=anpr@example.com\x01auth=Bearer token\x01\x01"))}},
+		{"cram-md5", "anpr", "secret", "CRAMMD5", []string{"AUTH CRAM-MD5", base64.StdEncoding.EncodeToString([]byte("anpr " + hex.EncodeToString(digest.Sum(nil))))}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newSMTPServer(t, "PLAIN CRAM-MD5 XOAUTH2")
+			env := &Env{Config: Config{SMTPHost: server.host, SMTPPort: server.port, SMTPUser: test.user, SMTPPass: test.pass, SMTPAuth: test.smtpAuth, SMTPFrom: "anpr@example.com"}}
+			email := Email{To: "a@example.com", Subject: "ANPR Alert: AB12CDE", Body: hermes.Body{Title: "ANPR Alert: AB12CDE"}}
+			if err := email.Send(env); err != nil {
+				t.Fatal(err)
+			}
+			<-server.done
+			if !reflect.DeepEqual(server.auth, test.auth) {
+				t.Errorf("auth = %q, want %q", server.auth, test.auth)
+			}
+			for _, want := range []string{"MAIL FROM:<anpr@example.com>", "RCPT TO:<a@example.com>"} {
+				if !strings.Contains(strings.Join(server.commands, "\n"), want) {
+					t.Errorf("commands = %q, want %q", server.commands, want)
+				}
+			}
+			if !bytes.Contains(server.data, []byte("Subject: ANPR Alert: AB12CDE")) {
+				t.Errorf("message = %s, want the subject", server.data)
+			}
+		})
+	}
+}
+
+func TestSMTPAuth(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		mechanism string
+	}{
+		{"no user", Config{SMTPHost: "smtp.example.com"}, ""},
+		{"plain", Config{SMTPHost: "smtp.example.com", SMTPUser: "anpr", SMTPAuth: "Plain"}, "PLAIN"},
+		{"plain without a user", Config{SMTPHost: "smtp.example.com", SMTPAuth: "Plain"}, ""},
+		{"unknown uses plain", Config{SMTPHost: "smtp.example.com", SMTPUser: "anpr", SMTPAuth: "Login"}, "PLAIN"},
+		{"none", Config{SMTPHost: "smtp.example.com", SMTPUser: "anpr", SMTPAuth: "None"}, ""},
+		{"cram-md5", Config{SMTPHost: "smtp.example.com", SMTPUser: "anpr", SMTPAuth: "CRAMMD5"}, "CRAM-MD5"},
+		{"oauth2", Config{SMTPHost: "smtp.example.com", SMTPUser: "anpr", SMTPAuth: "OAuth2"}, "XOAUTH2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			auth := smtpAuth(test.config)
+			if auth == nil {
+				if test.mechanism != "" {
+					t.Errorf("smtpAuth() = nil, want %s", test.mechanism)
+				}
+				return
+			}
+			mechanism, _, err := auth.Start(&smtp.ServerInfo{Name: "smtp.example.com", TLS: true, Auth: []string{"PLAIN", "CRAM-MD5", "XOAUTH2"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mechanism != test.mechanism {
+				t.Errorf("smtpAuth() mechanism = %q, want %q", mechanism, test.mechanism)
+			}
+		})
+	}
+
+	t.Run("oauth2 challenge", func(t *testing.T) {
+		if _, err := (oauth2Auth{}).Next([]byte(`{"status":"401"}`), true); err == nil {
+			t.Error("Next didn't return an error for a challenge")
+		}
+	})
+}
+
+// emailPart is a part of a MIME message with its raw and decoded body
+type emailPart struct {
+	mediaType string
+	header    textproto.MIMEHeader
+	raw       []byte
+	body      []byte
+}
+
+// readEmailMessage generates the email's message and parses it, returning the message and its parts in
+// order, with multipart parts before the parts they contain
+func readEmailMessage(t *testing.T, email Email) (*mail.Message, []emailPart) {
+	t.Helper()
+	env := &Env{Config: Config{SMTPFrom: "anpr@example.com", ExternalURL: "https://anpr.example.com"}}
+	message, err := email.message(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parts []emailPart
+	var read func(contentType string, r io.Reader)
+	read = func(contentType string, r io.Reader) {
+		mediaType, params, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if params["boundary"] == "" {
+			t.Fatalf("%s has no boundary", mediaType)
+		}
+		reader := multipart.NewReader(r, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			partType, _, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.HasPrefix(partType, "multipart/") {
+				parts = append(parts, emailPart{mediaType: partType, header: part.Header})
+				read(part.Header.Get("Content-Type"), part)
+				continue
+			}
+			raw, err := io.ReadAll(part)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var body io.Reader = bytes.NewReader(raw)
+			switch part.Header.Get("Content-Transfer-Encoding") {
+			case "quoted-printable":
+				body = quotedprintable.NewReader(body)
+			case "base64":
+				body = base64.NewDecoder(base64.StdEncoding, body)
+			}
+			decoded, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("error decoding %s: %s", partType, err)
+			}
+			parts = append(parts, emailPart{mediaType: partType, header: part.Header, raw: raw, body: decoded})
+		}
+	}
+	read(msg.Header.Get("Content-Type"), msg.Body)
+	return msg, parts
+}
+
+// mediaTypes returns the media types of the parts in order
+func mediaTypes(parts []emailPart) []string {
+	var mediaTypes []string
+	for _, part := range parts {
+		mediaTypes = append(mediaTypes, part.mediaType)
+	}
+	return mediaTypes
+}
+
+func TestEmailMessage(t *testing.T) {
+	email := Email{
+		To:      "a@example.com",
+		Subject: "ANPR Alert: AB12CDE at Café Gate",
+		Body:    hermes.Body{Title: "ANPR Alert: AB12CDE", Intros: []string{"Number plate AB12CDE was read at Café Gate."}},
+	}
+	msg, parts := readEmailMessage(t, email)
+	var decoder mime.WordDecoder
+	subject, err := decoder.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for header, want := range map[string]string{
+		"From":         `"Hikvision ANPR Alerts" <anpr@example.com>`,
+		"To":           "a@example.com",
+		"Subject":      email.Subject,
+		"MIME-Version": "1.0",
+	} {
+		got := msg.Header.Get(header)
+		if header == "Subject" {
+			got = subject
+		}
+		if got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+	if _, err := msg.Header.Date(); err != nil {
+		t.Errorf("invalid Date: %s", err)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(msg.Header.Get("Content-Type")); mediaType != "multipart/alternative" {
+		t.Errorf("Content-Type = %q, want multipart/alternative", mediaType)
+	}
+	if got, want := mediaTypes(parts), []string{"text/plain", "text/html"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("parts = %q, want %q", got, want)
+	}
+	for _, part := range parts {
+		if encoding := part.header.Get("Content-Transfer-Encoding"); encoding != "quoted-printable" {
+			t.Errorf("%s encoding = %q, want quoted-printable", part.mediaType, encoding)
+		}
+		if !bytes.Contains(part.body, []byte("Number plate AB12CDE was read at Café Gate.")) {
+			t.Errorf("%s body = %s, want the intro", part.mediaType, part.body)
+		}
+	}
+}
+
+func TestEmailMessageSnapshot(t *testing.T) {
+	// Long enough to be split over several lines
+	snapshot := bytes.Repeat([]byte{0xff, 0xd8, 0xff, 0xe0}, 100)
+	email := Email{
+		To:       "a@example.com",
+		Subject:  "ANPR Alert: AB12CDE",
+		Body:     hermes.Body{Title: "ANPR Alert: AB12CDE", FreeMarkdown: hermes.Markdown("Number plate AB12CDE\n\n" + EmailSnapshot)},
+		Snapshot: snapshot,
+	}
+	_, parts := readEmailMessage(t, email)
+	// The HTML and snapshot are related parts in the HTML alternative
+	if got, want := mediaTypes(parts), []string{"text/plain", "multipart/related", "text/html", "image/jpeg"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("parts = %q, want %q", got, want)
+	}
+	htmlPart, image := parts[2], parts[3]
+	src := regexp.MustCompile(`src="cid:([^"]+)"`).FindStringSubmatch(EmailSnapshot)
+	if !bytes.Contains(htmlPart.body, []byte(src[0])) {
+		t.Errorf("HTML body = %s, want the snapshot", htmlPart.body)
+	}
+	if bytes.Contains(htmlPart.body, []byte("data:image")) {
+		t.Error("HTML body embeds the snapshot in a data URI")
+	}
+	// The image's Content-ID matches the cid the body refers to
+	cid := src[1]
+	for header, want := range map[string]string{
+		"Content-ID":                "<" + cid + ">",
+		"Content-Transfer-Encoding": "base64",
+		"Content-Disposition":       `inline; filename="snapshot.jpg"`,
+	} {
+		if got := image.header.Get(header); got != want {
+			t.Errorf("image %s = %q, want %q", header, got, want)
+		}
+	}
+	if !bytes.Equal(image.body, snapshot) {
+		t.Errorf("image = %x, want %x", image.body, snapshot)
+	}
+	lines := strings.Split(strings.TrimRight(string(image.raw), "\r\n"), "\r\n")
+	if len(lines) < 2 {
+		t.Errorf("image is encoded on %d line, want it split", len(lines))
+	}
+	for _, line := range lines {
+		if len(line) > 76 {
+			t.Errorf("image line is %d characters, want at most 76", len(line))
+		}
+	}
+}
@@ -21,4 +21,6 @@ type Env struct {
 	ValidatorTranslator ut.Translator
 	Templates           *template.Template
 	EmbedFS             *embed.FS
+	MQTT                *MQTT
+	AlertLimiter        *AlertLimiter
 }
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"io"
+	"log"
+	"path/filepath"
+	"testing"
+)
+
+// newTestEnv returns an environment with a migrated database in a temporary directory, which is closed
+// when the test finishes
+func newTestEnv(t *testing.T) *Env {
+	t.Helper()
+	logger := log.New(io.Discard, "", 0)
+	env := &Env{
+		Config: Config{DBFile: filepath.Join(t.TempDir(), "test.db")},
+		Logger: logger,
+		DB:     &DB{},
+	}
+	if err := env.DB.Init(env.Config, nil, logger); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { env.DB.Conn.Close() })
+	if err := env.DB.Migrate(env); err != nil {
+		t.Fatal(err)
+	}
+	return env
+}
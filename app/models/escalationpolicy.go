@@ -0,0 +1,251 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// EscalationPolicy is a set of steps sending an alert to more recipients while it hasn't been acknowledged
+type EscalationPolicy struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name" validate:"required"`
+	CreatedAt string `json:"createdAt" db:"created_at"`
+	UpdatedAt string `json:"updatedAt" db:"updated_at"`
+}
+
+// Add escalation policy
+func (e *EscalationPolicy) Add(env *Env) (int64, error) {
+	// Add to database
+	res, err := env.DB.Exec(
+		"INSERT INTO escalation_policies (name, created_at, updated_at) VALUES (?, DATETIME('now', 'localtime'), DATETIME('now', 'localtime'))",
+		&e.Name,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	e.ID = int(id)
+	return res.RowsAffected()
+}
+
+// Get escalation policy by ID provided
+func (e *EscalationPolicy) Get(env *Env) (*EscalationPolicy, error) {
+	// Get from database
+	var escalationPolicies []EscalationPolicy
+	err := env.DB.Query(&escalationPolicies, "SELECT * FROM escalation_policies WHERE id = ? LIMIT 1", &e.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(escalationPolicies) == 0 {
+		return nil, env.DB.ErrRecordNotFound
+	}
+	return &escalationPolicies[0], nil
+}
+
+// Find escalation policies by fields provided
+func (e *EscalationPolicy) Find(env *Env, operator string, fields []WhereFields, perPage int, pageNumber int) (*[]EscalationPolicy, int, error) {
+	resCount := 0
+	// Where
+	whereSQL, values := env.DB.WhereSQL(operator, fields)
+	// Limit
+	limitSQL := env.DB.LimitSQL(perPage, pageNumber)
+	if limitSQL != "" {
+		// Get count from database
+		var escalationPolicies []EscalationPolicy
+		err := env.DB.Query(&escalationPolicies, "SELECT id FROM escalation_policies"+whereSQL, values...)
+		if err != nil {
+			return nil, 0, err
+		}
+		resCount = len(escalationPolicies)
+	}
+	// Get from database
+	var escalationPolicies []EscalationPolicy
+	err := env.DB.Query(&escalationPolicies, "SELECT * FROM escalation_policies"+whereSQL+" ORDER BY name"+limitSQL, values...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resCount == 0 {
+		resCount = len(escalationPolicies)
+	}
+	return &escalationPolicies, resCount, nil
+}
+
+// Update escalation policy
+func (e *EscalationPolicy) Update(env *Env) (int64, error) {
+	// Update database
+	res, err := env.DB.Exec(
+		"UPDATE escalation_policies SET name = ?, updated_at = DATETIME('now', 'localtime') WHERE id = ?",
+		&e.Name, &e.ID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Delete escalation policy
+func (e *EscalationPolicy) Delete(env *Env) (int64, error) {
+	// Delete from database
+	res, err := env.DB.Exec("DELETE FROM escalation_policies WHERE id = ?", &e.ID)
+	if err != nil {
+		return 0, err
+	}
+	// Delete steps
+	var escalationStep EscalationStep
+	resEscalationSteps, _, err := escalationStep.Find(env, "AND", []WhereFields{{"escalation_policy_id", "=", e.ID}}, 0, 1)
+	if err != nil {
+		return 0, err
+	}
+	for _, resEscalationStep := range *resEscalationSteps {
+		if _, err := resEscalationStep.Delete(env); err != nil {
+			return 0, err
+		}
+	}
+	// Detach escalation policy from anything using it
+	for _, table := range []string{"watchlists", "rules"} {
+		_, err = env.DB.Exec("UPDATE "+table+" SET escalation_policy_id = 0 WHERE escalation_policy_id = ?", &e.ID)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return res.RowsAffected()
+}
+
+// Steps returns the escalation policy's steps in the order they're sent
+func (e *EscalationPolicy) Steps(env *Env) ([]EscalationStep, error) {
+	var escalationStep EscalationStep
+	resEscalationSteps, _, err := escalationStep.Find(env, "AND", []WhereFields{{"escalation_policy_id", "=", e.ID}}, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	return *resEscalationSteps, nil
+}
+
+// EscalationPolicyFor returns the ID of the escalation policy for an alert raised by the rule or
+// watchlist provided, with the rule's taking precedence
+func EscalationPolicyFor(env *Env, ruleID int, watchlistID int) (int, error) {
+	if ruleID != 0 {
+		rule := Rule{ID: ruleID}
+		resRule, err := rule.Get(env)
+		if err != nil && !errors.Is(err, env.DB.ErrRecordNotFound) {
+			return 0, err
+		}
+		if resRule != nil && resRule.EscalationPolicyID != 0 {
+			return resRule.EscalationPolicyID, nil
+		}
+	}
+	if watchlistID != 0 {
+		watchlist := Watchlist{ID: watchlistID}
+		resWatchlist, err := watchlist.Get(env)
+		if err != nil && !errors.Is(err, env.DB.ErrRecordNotFound) {
+			return 0, err
+		}
+		if resWatchlist != nil {
+			return resWatchlist.EscalationPolicyID, nil
+		}
+	}
+	return 0, nil
+}
+
+// EscalateAlerts sends each alert that hasn't been acknowledged to the escalation steps whose delay
+// has passed since it was raised. Acknowledging or resolving an alert stops any further steps.
+func EscalateAlerts(env *Env) error {
+	var alert Alert
+	resAlerts, _, err := alert.Find(env, "AND", []WhereFields{{"state", "=", "new"}, {"escalation_policy_id", "!=", 0}}, 0, 1)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var escalateErrors []error
+	for _, resAlert := range *resAlerts {
+		if err := resAlert.escalate(env, now); err != nil {
+			escalateErrors = append(escalateErrors, fmt.Errorf("error escalating alert %d: %w", resAlert.ID, err))
+		}
+	}
+	return errors.Join(escalateErrors...)
+}
+
+// escalate sends the alert to any of its escalation policy's steps that are due
+func (e *Alert) escalate(env *Env, now time.Time) error {
+	escalationPolicy := EscalationPolicy{ID: e.EscalationPolicyID}
+	steps, err := escalationPolicy.Steps(env)
+	if err != nil {
+		return err
+	}
+	raised, err := time.ParseInLocation(DateTimeFormat, e.CreatedAt, time.Local)
+	if err != nil {
+		return err
+	}
+	level := e.EscalationLevel
+	for level < len(steps) && now.Sub(raised) >= time.Duration(steps[level].DelayMinutes)*time.Minute {
+		step := steps[level]
+		level++
+		emails, channelIDs, err := step.Recipients(env)
+		if err != nil {
+			return err
+		}
+		notification := e.escalationNotification(env, step.DelayMinutes)
+		if err := notification.DispatchTo(env, emails, channelIDs); err != nil {
+			env.Logger.Println(err)
+		}
+		env.Logger.Printf("Escalated alert %q to step %d\n", e.Title, level)
+	}
+	if level == e.EscalationLevel {
+		return nil
+	}
+	_, err = e.SetEscalationLevel(env, level)
+	return err
+}
+
+// escalationNotification returns the notification sent when the alert is escalated, which is the
+// one originally sent if it was queued in the outbox. The title and message are built from the alert
+// as the earliest message queued may itself be an escalation.
+func (e *Alert) escalationNotification(env *Env, delayMinutes int) Notification {
+	notification := Notification{
+		Title:         e.Title,
+		Message:       e.Message,
+		WatchlistID:   e.WatchlistID,
+		CameraID:      e.CameraID,
+		RuleID:        e.RuleID,
+		Plate:         e.Plate,
+		NumberPlateID: e.NumberPlateID,
+		DetectionID:   e.DetectionID,
+	}
+	var outboxMessage OutboxMessage
+	resOutboxMessages, _, err := outboxMessage.Find(env, "AND", []WhereFields{{"alert_id", "=", e.ID}}, 0, 1)
+	if err == nil && len(*resOutboxMessages) > 0 {
+		// Messages are ordered newest first
+		original := (*resOutboxMessages)[len(*resOutboxMessages)-1]
+		if err := json.Unmarshal([]byte(original.Notification), &notification); err != nil {
+			env.Logger.Printf("Error reading notification for alert %d: %s\n", e.ID, err)
+		}
+	}
+	notification.AlertID = e.ID
+	notification.Escalation = true
+	notification.Title = "Escalated: " + e.Title
+	notification.Message = fmt.Sprintf("Not acknowledged within %d %s.\n\n%s", delayMinutes, plural(delayMinutes, "minute", "minutes"), e.Message)
+	return notification
+}
+
+// Migrate escalation policies
+func (e *EscalationPolicy) Migrate(env *Env) (sql.Result, error) {
+	// Create table if not exists
+	res, err := env.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS escalation_policies (
+		id INTEGER NOT NULL PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		created_at TEXT NOT NULL DEFAULT 0,
+		updated_at TEXT NOT NULL DEFAULT 0
+	);
+	`)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestEscalationNotification(t *testing.T) {
+	env := newTestEnv(t)
+	alert := Alert{Title: "ANPR Alert: AB12CDE", Message: "Number plate AB12CDE was read at Front Gate.", DetectionID: 7, Plate: "AB12CDE", CameraID: 1}
+	if _, err := alert.Add(env); err != nil {
+		t.Fatal(err)
+	}
+	original := Notification{Title: alert.Title, Message: alert.Message, Plate: "AB12CDE", CameraID: 1, DetectionID: 7, AlertID: alert.ID, Snapshot: []byte{0xff, 0xd8}}
+	original.AddField("Camera", "Front Gate")
+	var outboxMessage OutboxMessage
+	if err := outboxMessage.Queue(env, original, "a@example.com", 0, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for step, delayMinutes := range []int{1, 15} {
+		notification := alert.escalationNotification(env, delayMinutes)
+		if notification.Title != "Escalated: ANPR Alert: AB12CDE" {
+			t.Errorf("step %d: title = %q", step, notification.Title)
+		}
+		if !notification.Escalation || notification.DetectionID != 7 || notification.AlertID != alert.ID {
+			t.Errorf("step %d: escalation = %v, detection = %d, alert = %d", step, notification.Escalation, notification.DetectionID, notification.AlertID)
+		}
+		if len(notification.Fields) != 1 || notification.Fields[0].Value != "Front Gate" {
+			t.Errorf("step %d: fields = %v, want the original fields", step, notification.Fields)
+		}
+		// The snapshot stored for the original is sent with the escalation
+		if err := notification.loadSnapshot(env); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(notification.Snapshot, original.Snapshot) {
+			t.Errorf("step %d: snapshot = %x, want %x", step, notification.Snapshot, original.Snapshot)
+		}
+		if err := outboxMessage.Queue(env, notification, "b@example.com", 0, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// EscalationStep sends an alert to more users and notification channels if it hasn't been acknowledged
+// within the step's delay
+type EscalationStep struct {
+	ID                 int    `json:"id"`
+	EscalationPolicyID int    `json:"escalationPolicyID" db:"escalation_policy_id"`
+	DelayMinutes       int    `json:"delayMinutes" db:"delay_minutes" validate:"min=1"`
+	UserIDs            []int  `json:"userIDs" db:"-"`
+	CreatedAt          string `json:"createdAt" db:"created_at"`
+	UpdatedAt          string `json:"updatedAt" db:"updated_at"`
+}
+
+// escalationStepColumns are the columns of escalation steps, listed as older databases have a user_ids
+// column that's been replaced by escalation step users
+const escalationStepColumns = "id, escalation_policy_id, delay_minutes, created_at, updated_at"
+
+// Add escalation step
+func (e *EscalationStep) Add(env *Env) (int64, error) {
+	// Add to database
+	res, err := env.DB.Exec(
+		"INSERT INTO escalation_steps (escalation_policy_id, delay_minutes, created_at, updated_at) VALUES (?, ?, DATETIME('now', 'localtime'), DATETIME('now', 'localtime'))",
+		&e.EscalationPolicyID, &e.DelayMinutes,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	e.ID = int(id)
+	// Add users
+	var escalationStepUser EscalationStepUser
+	if err := escalationStepUser.Set(env, e.ID, e.UserIDs); err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Get escalation step by ID provided
+func (e *EscalationStep) Get(env *Env) (*EscalationStep, error) {
+	// Get from database
+	var escalationSteps []EscalationStep
+	err := env.DB.Query(&escalationSteps, "SELECT "+escalationStepColumns+" FROM escalation_steps WHERE id = ? LIMIT 1", &e.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(escalationSteps) == 0 {
+		return nil, env.DB.ErrRecordNotFound
+	}
+	if err := escalationSteps[0].loadUserIDs(env); err != nil {
+		return nil, err
+	}
+	return &escalationSteps[0], nil
+}
+
+// Find escalation steps by fields provided
+func (e *EscalationStep) Find(env *Env, operator string, fields []WhereFields, perPage int, pageNumber int) (*[]EscalationStep, int, error) {
+	resCount := 0
+	// Where
+	whereSQL, values := env.DB.WhereSQL(operator, fields)
+	// Limit
+	limitSQL := env.DB.LimitSQL(perPage, pageNumber)
+	if limitSQL != "" {
+		// Get count from database
+		var escalationSteps []EscalationStep
+		err := env.DB.Query(&escalationSteps, "SELECT id FROM escalation_steps"+whereSQL, values...)
+		if err != nil {
+			return nil, 0, err
+		}
+		resCount = len(escalationSteps)
+	}
+	// Get from database
+	var escalationSteps []EscalationStep
+	err := env.DB.Query(&escalationSteps, "SELECT "+escalationStepColumns+" FROM escalation_steps"+whereSQL+" ORDER BY delay_minutes, id"+limitSQL, values...)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range escalationSteps {
+		if err := escalationSteps[i].loadUserIDs(env); err != nil {
+			return nil, 0, err
+		}
+	}
+	if resCount == 0 {
+		resCount = len(escalationSteps)
+	}
+	return &escalationSteps, resCount, nil
+}
+
+// Update escalation step
+func (e *EscalationStep) Update(env *Env) (int64, error) {
+	// Update database
+	res, err := env.DB.Exec(
+		"UPDATE escalation_steps SET delay_minutes = ?, updated_at = DATETIME('now', 'localtime') WHERE id = ?",
+		&e.DelayMinutes, &e.ID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	// Update users
+	var escalationStepUser EscalationStepUser
+	if err := escalationStepUser.Set(env, e.ID, e.UserIDs); err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Delete escalation step
+func (e *EscalationStep) Delete(env *Env) (int64, error) {
+	// Delete from database
+	res, err := env.DB.Exec("DELETE FROM escalation_steps WHERE id = ?", &e.ID)
+	if err != nil {
+		return 0, err
+	}
+	// Delete users
+	var escalationStepUser EscalationStepUser
+	if err := escalationStepUser.Delete(env, e.ID); err != nil {
+		return 0, err
+	}
+	// Delete notification channel targets
+	var notificationTarget NotificationTarget
+	if err := notificationTarget.Delete(env, "escalationstep", e.ID); err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// loadUserIDs loads the IDs of the users the step sends the alert to
+func (e *EscalationStep) loadUserIDs(env *Env) error {
+	var escalationStepUser EscalationStepUser
+	userIDs, err := escalationStepUser.Find(env, e.ID)
+	if err != nil {
+		return err
+	}
+	e.UserIDs = userIDs
+	return nil
+}
+
+// Recipients returns the email addresses and notification channels the step sends the alert to
+func (e *EscalationStep) Recipients(env *Env) ([]string, []int, error) {
+	var emails []string
+	for _, userID := range e.UserIDs {
+		user := User{ID: userID}
+		resUser, err := user.Get(env)
+		if err != nil {
+			if errors.Is(err, env.DB.ErrRecordNotFound) {
+				continue
+			}
+			return nil, nil, err
+		}
+		if resUser.Email != "" {
+			emails = append(emails, resUser.Email)
+		}
+	}
+	var notificationTarget NotificationTarget
+	channelIDs, err := notificationTarget.Find(env, "escalationstep", e.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return emails, channelIDs, nil
+}
+
+// Migrate escalation steps
+func (e *EscalationStep) Migrate(env *Env) (sql.Result, error) {
+	// Create table if not exists
+	res, err := env.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS escalation_steps (
+		id INTEGER NOT NULL PRIMARY KEY,
+		escalation_policy_id INTEGER NOT NULL,
+		delay_minutes INTEGER NOT NULL DEFAULT 0,
+		created_at TEXT NOT NULL DEFAULT 0,
+		updated_at TEXT NOT NULL DEFAULT 0
+	);
+	`)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// EscalationStepUser is a user an escalation step sends alerts to
+type EscalationStepUser struct {
+	ID               int `json:"id"`
+	EscalationStepID int `json:"escalationStepID" db:"escalation_step_id"`
+	UserID           int `json:"userID" db:"user_id"`
+}
+
+// Find the IDs of the users the escalation step provided sends alerts to
+func (e *EscalationStepUser) Find(env *Env, escalationStepID int) ([]int, error) {
+	// Get from database
+	var escalationStepUsers []EscalationStepUser
+	err := env.DB.Query(&escalationStepUsers, "SELECT * FROM escalation_step_users WHERE escalation_step_id = ? ORDER BY id", escalationStepID)
+	if err != nil {
+		return nil, err
+	}
+	var userIDs []int
+	for _, escalationStepUser := range escalationStepUsers {
+		userIDs = append(userIDs, escalationStepUser.UserID)
+	}
+	return userIDs, nil
+}
+
+// Set replaces the users the escalation step provided sends alerts to
+func (e *EscalationStepUser) Set(env *Env, escalationStepID int, userIDs []int) error {
+	// Delete existing users from database
+	if err := e.Delete(env, escalationStepID); err != nil {
+		return err
+	}
+	// Add to database
+	for _, userID := range userIDs {
+		_, err := env.DB.Exec(
+			"INSERT INTO escalation_step_users (escalation_step_id, user_id) VALUES (?, ?)",
+			escalationStepID, userID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete the users of the escalation step provided
+func (e *EscalationStepUser) Delete(env *Env, escalationStepID int) error {
+	_, err := env.DB.Exec("DELETE FROM escalation_step_users WHERE escalation_step_id = ?", escalationStepID)
+	return err
+}
+
+// Migrate escalation step users
+func (e *EscalationStepUser) Migrate(env *Env) (sql.Result, error) {
+	// Create table if not exists
+	res, err := env.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS escalation_step_users (
+		id INTEGER NOT NULL PRIMARY KEY,
+		escalation_step_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS escalation_step_users_escalation_step_id ON escalation_step_users (escalation_step_id);
+	`)
+	if err != nil {
+		return nil, err
+	}
+	// Move users from the comma separated user_ids column of older databases
+	var columns []string
+	err = env.DB.Query(&columns, "SELECT name FROM pragma_table_info('escalation_steps') WHERE name = 'user_ids'")
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return res, nil
+	}
+	var escalationSteps []struct {
+		ID      int
+		UserIDs string `db:"user_ids"`
+	}
+	err = env.DB.Query(&escalationSteps, "SELECT id, user_ids FROM escalation_steps WHERE user_ids != ''")
+	if err != nil {
+		return nil, err
+	}
+	for _, escalationStep := range escalationSteps {
+		var userIDs []int
+		for _, value := range strings.Split(escalationStep.UserIDs, ",") {
+			if userID, err := strconv.Atoi(value); err == nil && userID > 0 {
+				userIDs = append(userIDs, userID)
+			}
+		}
+		if err := e.Set(env, escalationStep.ID, userIDs); err != nil {
+			return nil, err
+		}
+		if _, err := env.DB.Exec("UPDATE escalation_steps SET user_ids = '' WHERE id = ?", escalationStep.ID); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
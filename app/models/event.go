@@ -0,0 +1,156 @@
+package models
+
+import (
+	"encoding/xml"
+	"strings"
+	"time"
+)
+
+// Event struct
+type Event struct {
+	IPAddress string `xml:"ipAddress"`
+	ChannelID int    `xml:"channelID"`
+	DateTime  string `xml:"dateTime"`
+	EventType string `xml:"eventType"`
+	ANPR      struct {
+		LicensePlate    string `xml:"licensePlate"`
+		ConfidenceLevel int    `xml:"confidenceLevel"`
+		Direction       string `xml:"direction"`
+		VehicleType     string `xml:"vehicleType"`
+		VehicleInfo     struct {
+			Color            string `xml:"color"`
+			VehicleLogoRecog string `xml:"vehicleLogoRecog"`
+			VehicleModel     string `xml:"vehileModel"`
+		} `xml:"vehicleInfo"`
+		PictureInfoList struct {
+			PictureInfo []EventPicture `xml:"pictureInfo"`
+		} `xml:"pictureInfoList"`
+	} `xml:"ANPR"`
+}
+
+// EventPicture is a picture of a number plate read, which has a URL when the camera stores it
+type EventPicture struct {
+	FileName   string `xml:"fileName"`
+	Type       string `xml:"type"`
+	PictureURL string `xml:"pictureURL"`
+}
+
+// VehicleAttributes struct
+type VehicleAttributes struct {
+	VehicleType string `json:"vehicleType" db:"vehicle_type"`
+	Colour      string `json:"colour"`
+	Brand       string `json:"brand"`
+	Model       string `json:"model"`
+}
+
+// ParseEvent parses an ISAPI event notification alert
+func ParseEvent(msg []byte) (*Event, error) {
+	event := Event{}
+	err := xml.Unmarshal(msg, &event)
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// IsANPR checks if the event is a number plate read
+func (e *Event) IsANPR() bool {
+	return strings.EqualFold(e.EventType, "ANPR") && e.Plate() != ""
+}
+
+// Plate returns the normalised number plate read by the event
+func (e *Event) Plate() string {
+	return NormalisePlate(e.ANPR.LicensePlate)
+}
+
+// Time returns the time of the event in local time, falling back to now if the camera didn't provide
+// one. Times with an offset are converted as they're stored and compared as local times.
+func (e *Event) Time() time.Time {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05"} {
+		if t, err := time.ParseInLocation(layout, e.DateTime, time.Local); err == nil {
+			return t.In(time.Local)
+		}
+	}
+	return time.Now()
+}
+
+// PictureURL returns the URL of the event's scene picture, or its number plate picture if it doesn't
+// have one, or an empty string if the camera didn't provide either
+func (e *Event) PictureURL() string {
+	for _, pictureType := range []string{"detectionPicture", "licensePlatePicture"} {
+		for _, picture := range e.ANPR.PictureInfoList.PictureInfo {
+			if picture.Type == pictureType && picture.PictureURL != "" {
+				return picture.PictureURL
+			}
+		}
+	}
+	return ""
+}
+
+// Vehicle returns the normalised vehicle attributes reported by the event
+func (e *Event) Vehicle() VehicleAttributes {
+	return VehicleAttributes{
+		VehicleType: normaliseAttribute(e.ANPR.VehicleType),
+		Colour:      normaliseAttribute(e.ANPR.VehicleInfo.Color),
+		Brand:       normaliseAttribute(e.ANPR.VehicleInfo.VehicleLogoRecog),
+		Model:       normaliseAttribute(e.ANPR.VehicleInfo.VehicleModel),
+	}
+}
+
+// Matches checks all of the attributes set are the same as the observed attributes provided
+func (v VehicleAttributes) Matches(observed VehicleAttributes) bool {
+	return attributeMatches(v.VehicleType, observed.VehicleType) &&
+		attributeMatches(v.Colour, observed.Colour) &&
+		attributeMatches(v.Brand, observed.Brand) &&
+		attributeMatches(v.Model, observed.Model)
+}
+
+// Differs checks if any of the attributes set are known to be different to the observed attributes provided
+func (v VehicleAttributes) Differs(observed VehicleAttributes) bool {
+	return attributeDiffers(v.VehicleType, observed.VehicleType) ||
+		attributeDiffers(v.Colour, observed.Colour) ||
+		attributeDiffers(v.Brand, observed.Brand) ||
+		attributeDiffers(v.Model, observed.Model)
+}
+
+// IsEmpty checks if none of the attributes are set
+func (v VehicleAttributes) IsEmpty() bool {
+	return v == VehicleAttributes{}
+}
+
+// String returns the attributes set as a description of the vehicle, e.g. "white ford transit van"
+func (v VehicleAttributes) String() string {
+	var parts []string
+	for _, value := range []string{v.Colour, v.Brand, v.Model, v.VehicleType} {
+		if value != "" {
+			parts = append(parts, value)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// normaliseAttribute lowercases a vehicle attribute, treating unknown values as empty
+func normaliseAttribute(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "unknown" || value == "0" {
+		return ""
+	}
+	return value
+}
+
+// attributeMatches checks an attribute is either not set or the same as the observed attribute
+func attributeMatches(value, observed string) bool {
+	value = normaliseAttribute(value)
+	return value == "" || value == normaliseAttribute(observed)
+}
+
+// attributeDiffers checks an attribute and the observed attribute are both known and different
+func attributeDiffers(value, observed string) bool {
+	value, observed = normaliseAttribute(value), normaliseAttribute(observed)
+	return value != "" && observed != "" && value != observed
+}
+
+// NormalisePlate uppercases a number plate and removes any spaces
+func NormalisePlate(plate string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(plate), " ", ""))
+}
@@ -0,0 +1,140 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ExpectedArrival struct
+type ExpectedArrival struct {
+	ID                 int    `json:"id"`
+	Plate              string `json:"plate" validate:"required"`
+	Description        string `json:"description"`
+	ExpectedFrom       string `json:"expectedFrom" db:"expected_from" validate:"omitempty,datetime=2006-01-02 15:04:05"`
+	ExpectedBy         string `json:"expectedBy" db:"expected_by" validate:"required,datetime=2006-01-02 15:04:05"`
+	Status             string `json:"status" validate:"oneof=pending arrived missed"`
+	ArrivedDetectionID int    `json:"arrivedDetectionID" db:"arrived_detection_id"`
+	CreatedAt          string `json:"createdAt" db:"created_at"`
+	UpdatedAt          string `json:"updatedAt" db:"updated_at"`
+}
+
+// Add expected arrival
+func (e *ExpectedArrival) Add(env *Env) (int64, error) {
+	// Add to database
+	res, err := env.DB.Exec(
+		"INSERT INTO expected_arrivals (plate, description, expected_from, expected_by, status, arrived_detection_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, DATETIME('now', 'localtime'), DATETIME('now', 'localtime'))",
+		&e.Plate, &e.Description, &e.ExpectedFrom, &e.ExpectedBy, &e.Status, &e.ArrivedDetectionID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	e.ID = int(id)
+	return res.RowsAffected()
+}
+
+// Get expected arrival by ID provided
+func (e *ExpectedArrival) Get(env *Env) (*ExpectedArrival, error) {
+	// Get from database
+	var expectedArrivals []ExpectedArrival
+	err := env.DB.Query(&expectedArrivals, "SELECT * FROM expected_arrivals WHERE id = ? LIMIT 1", &e.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(expectedArrivals) == 0 {
+		return nil, env.DB.ErrRecordNotFound
+	}
+	return &expectedArrivals[0], nil
+}
+
+// Find expected arrivals by fields provided
+func (e *ExpectedArrival) Find(env *Env, operator string, fields []WhereFields, perPage int, pageNumber int) (*[]ExpectedArrival, int, error) {
+	resCount := 0
+	// Where
+	whereSQL, values := env.DB.WhereSQL(operator, fields)
+	// Limit
+	limitSQL := env.DB.LimitSQL(perPage, pageNumber)
+	if limitSQL != "" {
+		// Get count from database
+		var expectedArrivals []ExpectedArrival
+		err := env.DB.Query(&expectedArrivals, "SELECT id FROM expected_arrivals"+whereSQL, values...)
+		if err != nil {
+			return nil, 0, err
+		}
+		resCount = len(expectedArrivals)
+	}
+	// Get from database
+	var expectedArrivals []ExpectedArrival
+	err := env.DB.Query(&expectedArrivals, "SELECT * FROM expected_arrivals"+whereSQL+" ORDER BY expected_by DESC, id DESC"+limitSQL, values...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resCount == 0 {
+		resCount = len(expectedArrivals)
+	}
+	return &expectedArrivals, resCount, nil
+}
+
+// Update expected arrival
+func (e *ExpectedArrival) Update(env *Env) (int64, error) {
+	// Update database
+	res, err := env.DB.Exec(
+		"UPDATE expected_arrivals SET plate = ?, description = ?, expected_from = ?, expected_by = ?, status = ?, arrived_detection_id = ?, updated_at = DATETIME('now', 'localtime') WHERE id = ?",
+		&e.Plate, &e.Description, &e.ExpectedFrom, &e.ExpectedBy, &e.Status, &e.ArrivedDetectionID, &e.ID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Delete expected arrival
+func (e *ExpectedArrival) Delete(env *Env) (int64, error) {
+	// Delete from database
+	res, err := env.DB.Exec("DELETE FROM expected_arrivals WHERE id = ?", &e.ID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// FindArrival finds the first confident detection of the expected number plate between the expected
+// from time, or when the expected arrival was added if it doesn't have one, and the time provided
+func (e *ExpectedArrival) FindArrival(env *Env, until time.Time) (*Detection, error) {
+	from := e.ExpectedFrom
+	if from == "" {
+		from = e.CreatedAt
+	}
+	var detections []Detection
+	err := env.DB.Query(&detections, "SELECT * FROM detections WHERE plate = ? AND event_time >= ? AND event_time <= ? AND low_confidence = 0 ORDER BY event_time LIMIT 1", e.Plate, from, until.Format(DateTimeFormat))
+	if err != nil || len(detections) == 0 {
+		return nil, err
+	}
+	return &detections[0], nil
+}
+
+// Migrate expected arrivals
+func (e *ExpectedArrival) Migrate(env *Env) (sql.Result, error) {
+	// Create table if not exists, created_at needs a time of day as FindArrival looks for reads after it
+	res, err := env.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS expected_arrivals (
+		id INTEGER NOT NULL PRIMARY KEY,
+		plate TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		expected_from TEXT NOT NULL DEFAULT '',
+		expected_by TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		arrived_detection_id INTEGER NOT NULL DEFAULT 0,
+		created_at TEXT NOT NULL DEFAULT 0,
+		updated_at TEXT NOT NULL DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS expected_arrivals_status ON expected_arrivals (status, expected_by);
+	`)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpectedArrivalFindArrival(t *testing.T) {
+	env := newTestEnv(t)
+	expectedArrival := ExpectedArrival{Plate: "AB12CDE", ExpectedBy: time.Now().Add(time.Hour).Format(DateTimeFormat)}
+	if _, err := expectedArrival.Add(env); err != nil {
+		t.Fatal(err)
+	}
+	resExpectedArrival, err := expectedArrival.Get(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without an expected from time reads are looked for after it was added, so it needs a time of day
+	added, err := time.ParseInLocation(DateTimeFormat, resExpectedArrival.CreatedAt, time.Local)
+	if err != nil {
+		t.Fatalf("created at %q isn't a date time: %s", resExpectedArrival.CreatedAt, err)
+	}
+	if since := time.Since(added); since < -time.Second || since > time.Minute {
+		t.Fatalf("created at %s isn't the local time now", resExpectedArrival.CreatedAt)
+	}
+
+	before := Detection{Plate: "AB12CDE", Confidence: 90, EventTime: added.Add(-time.Second).Format(DateTimeFormat)}
+	if _, err := before.Add(env); err != nil {
+		t.Fatal(err)
+	}
+	detection, err := resExpectedArrival.FindArrival(env, added.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if detection != nil {
+		t.Errorf("FindArrival() found read %d from before the arrival was added", detection.ID)
+	}
+
+	after := Detection{Plate: "AB12CDE", Confidence: 90, EventTime: added.Add(time.Minute).Format(DateTimeFormat)}
+	if _, err := after.Add(env); err != nil {
+		t.Fatal(err)
+	}
+	detection, err = resExpectedArrival.FindArrival(env, added.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if detection == nil || detection.ID != after.ID {
+		t.Errorf("FindArrival() = %v, want read %d", detection, after.ID)
+	}
+}
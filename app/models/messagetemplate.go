@@ -0,0 +1,320 @@
+package models
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/matcornic/hermes/v2"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// MessageTemplateFormats are the display names of message template formats. Text templates are used
+// for plain text and markdown and HTML templates escape values for HTML.
+var MessageTemplateFormats = map[string]string{"text": "Text or markdown", "html": "HTML"}
+
+// MessageTemplate is a Go template for the title and body of alerts sent to a notification channel or
+// for a watchlist
+type MessageTemplate struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name" validate:"required"`
+	Format    string `json:"format" validate:"oneof=text html"`
+	Title     string `json:"title"`
+	Body      string `json:"body" validate:"required"`
+	CreatedAt string `json:"createdAt" db:"created_at"`
+	UpdatedAt string `json:"updatedAt" db:"updated_at"`
+}
+
+// MessageData is the data available to message templates
+type MessageData struct {
+	Title   string
+	Message string
+	Fields  []NotificationField
+	Field   map[string]string
+	Link    string
+	Plate   string
+}
+
+// Add message template
+func (e *MessageTemplate) Add(env *Env) (int64, error) {
+	// Add to database
+	res, err := env.DB.Exec(
+		"INSERT INTO message_templates (name, format, title, body, created_at, updated_at) VALUES (?, ?, ?, ?, DATETIME('now', 'localtime'), DATETIME('now', 'localtime'))",
+		&e.Name, &e.Format, &e.Title, &e.Body,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	e.ID = int(id)
+	return res.RowsAffected()
+}
+
+// Get message template by ID provided
+func (e *MessageTemplate) Get(env *Env) (*MessageTemplate, error) {
+	// Get from database
+	var messageTemplates []MessageTemplate
+	err := env.DB.Query(&messageTemplates, "SELECT * FROM message_templates WHERE id = ? LIMIT 1", &e.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(messageTemplates) == 0 {
+		return nil, env.DB.ErrRecordNotFound
+	}
+	return &messageTemplates[0], nil
+}
+
+// Find message templates by fields provided
+func (e *MessageTemplate) Find(env *Env, operator string, fields []WhereFields, perPage int, pageNumber int) (*[]MessageTemplate, int, error) {
+	resCount := 0
+	// Where
+	whereSQL, values := env.DB.WhereSQL(operator, fields)
+	// Limit
+	limitSQL := env.DB.LimitSQL(perPage, pageNumber)
+	if limitSQL != "" {
+		// Get count from database
+		var messageTemplates []MessageTemplate
+		err := env.DB.Query(&messageTemplates, "SELECT id FROM message_templates"+whereSQL, values...)
+		if err != nil {
+			return nil, 0, err
+		}
+		resCount = len(messageTemplates)
+	}
+	// Get from database
+	var messageTemplates []MessageTemplate
+	err := env.DB.Query(&messageTemplates, "SELECT * FROM message_templates"+whereSQL+" ORDER BY name"+limitSQL, values...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resCount == 0 {
+		resCount = len(messageTemplates)
+	}
+	return &messageTemplates, resCount, nil
+}
+
+// Update message template
+func (e *MessageTemplate) Update(env *Env) (int64, error) {
+	// Update database
+	res, err := env.DB.Exec(
+		"UPDATE message_templates SET name = ?, format = ?, title = ?, body = ?, updated_at = DATETIME('now', 'localtime') WHERE id = ?",
+		&e.Name, &e.Format, &e.Title, &e.Body, &e.ID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Delete message template
+func (e *MessageTemplate) Delete(env *Env) (int64, error) {
+	// Delete from database
+	res, err := env.DB.Exec("DELETE FROM message_templates WHERE id = ?", &e.ID)
+	if err != nil {
+		return 0, err
+	}
+	// Detach message template from anything using it
+	for _, table := range []string{"notification_channels", "watchlists"} {
+		_, err = env.DB.Exec("UPDATE "+table+" SET template_id = 0 WHERE template_id = ?", &e.ID)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return res.RowsAffected()
+}
+
+// Check checks the message template's title and body can be parsed
+func (e *MessageTemplate) Check() error {
+	if _, err := texttemplate.New("title").Parse(e.Title); err != nil {
+		return fmt.Errorf("invalid title template: %w", err)
+	}
+	if _, err := e.parse("body", e.Body); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Render renders the message template's title and body for the notification provided, using the
+// notification's title if the template doesn't have one
+func (e *MessageTemplate) Render(notification Notification) (string, string, error) {
+	return e.render(notification, nil)
+}
+
+// render renders the message template like Render, escaping the values used in the body with the
+// function provided if it isn't nil
+func (e *MessageTemplate) render(notification Notification, escape func(string) string) (string, string, error) {
+	data := messageData(notification, nil)
+	title := notification.Title
+	if strings.TrimSpace(e.Title) != "" {
+		// Titles are never HTML
+		titleTemplate, err := texttemplate.New("title").Parse(e.Title)
+		if err != nil {
+			return "", "", err
+		}
+		var b bytes.Buffer
+		if err := titleTemplate.Execute(&b, data); err != nil {
+			return "", "", err
+		}
+		title = strings.TrimSpace(b.String())
+	}
+	bodyTemplate, err := e.parse("body", e.Body)
+	if err != nil {
+		return "", "", err
+	}
+	var b bytes.Buffer
+	if err := bodyTemplate.Execute(&b, messageData(notification, escape)); err != nil {
+		return "", "", err
+	}
+	return title, strings.TrimSpace(b.String()), nil
+}
+
+// Email returns the notification rendered with the message template as an email to the address
+// provided, with its snapshot. Values in text templates are escaped as the body is markdown.
+func (e *MessageTemplate) Email(notification Notification, to string) (Email, error) {
+	var escape func(string) string
+	if e.Format != "html" {
+		escape = escapeMarkdown
+	}
+	title, body, err := e.render(notification, escape)
+	if err != nil {
+		return Email{}, err
+	}
+	if len(notification.Snapshot) > 0 {
+		body += "\n\n" + EmailSnapshot
+	}
+	return Email{
+		To:      to,
+		Subject: title,
+		Body: hermes.Body{
+			Title:        title,
+			FreeMarkdown: hermes.Markdown(body),
+			Signature:    "Thanks",
+		},
+		Snapshot: notification.Snapshot,
+	}, nil
+}
+
+// messageData returns the data for message templates from the notification, escaping the values with
+// the function provided if it isn't nil. The link is left as is so it can be used in markdown links.
+func messageData(notification Notification, escape func(string) string) MessageData {
+	if escape == nil {
+		escape = func(s string) string { return s }
+	}
+	data := MessageData{
+		Title:   escape(notification.Title),
+		Message: escape(notification.Message),
+		Field:   map[string]string{},
+		Link:    notification.Link,
+		Plate:   escape(notification.Plate),
+	}
+	for _, field := range notification.Fields {
+		data.Fields = append(data.Fields, NotificationField{Name: escape(field.Name), Value: escape(field.Value)})
+		data.Field[field.Name] = escape(field.Value)
+	}
+	return data
+}
+
+// messageTemplate returns the message template with the ID provided, or the template for the
+// notification's watchlist if the ID is 0. It returns nil if there's no template to use.
+func (e *Notification) messageTemplate(env *Env, templateID int) (*MessageTemplate, error) {
+	if templateID == 0 && e.WatchlistID != 0 {
+		watchlist := Watchlist{ID: e.WatchlistID}
+		resWatchlist, err := watchlist.Get(env)
+		if err != nil && !errors.Is(err, env.DB.ErrRecordNotFound) {
+			return nil, err
+		}
+		if resWatchlist != nil {
+			templateID = resWatchlist.TemplateID
+		}
+	}
+	if templateID == 0 {
+		return nil, nil
+	}
+	messageTemplate := MessageTemplate{ID: templateID}
+	resMessageTemplate, err := messageTemplate.Get(env)
+	if err != nil {
+		if errors.Is(err, env.DB.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return resMessageTemplate, nil
+}
+
+// SampleNotification returns an example alert for previewing message templates, with the same fields
+// as alerts for number plate reads
+func SampleNotification(env *Env) Notification {
+	notification := Notification{
+		Title:   "ANPR Alert: AB12CDE",
+		Message: "Number plate AB12CDE was read at Front Gate.",
+		Plate:   "AB12CDE",
+	}
+	if env.Config.ExternalURL != "" {
+		notification.Link = strings.TrimRight(env.Config.ExternalURL, "/") + "/detections"
+	}
+	notification.AddField("Number Plate", "AB12CDE")
+	notification.AddField("Registered To", "John Smith")
+	notification.AddField("Watchlist", "Visitors")
+	notification.AddField("Camera", "Front Gate")
+	notification.AddField("Direction", "Entry")
+	notification.AddField("Time", time.Now().Format(DateTimeFormat))
+	notification.AddField("Confidence", "94%")
+	notification.AddField("Vehicle", "Blue Ford Focus")
+	return notification
+}
+
+// TestNotification returns a sample alert marked as a test, for checking notification channels and
+// the SMTP settings
+func TestNotification(env *Env) Notification {
+	notification := SampleNotification(env)
+	notification.Title = "Test " + notification.Title
+	notification.Message = "This is a test alert from Hikvision ANPR Alerts. " + notification.Message
+	return notification
+}
+
+// messageTemplateExecutor is a parsed text or HTML template
+type messageTemplateExecutor interface {
+	Execute(wr io.Writer, data any) error
+}
+
+// parse parses the template text provided in the message template's format
+func (e *MessageTemplate) parse(name, text string) (messageTemplateExecutor, error) {
+	if e.Format == "html" {
+		t, err := htmltemplate.New(name).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s template: %w", name, err)
+		}
+		return t, nil
+	}
+	t, err := texttemplate.New(name).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	return t, nil
+}
+
+// Migrate message templates
+func (e *MessageTemplate) Migrate(env *Env) (sql.Result, error) {
+	// Create table if not exists
+	res, err := env.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS message_templates (
+		id INTEGER NOT NULL PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		format TEXT NOT NULL DEFAULT 'text',
+		title TEXT NOT NULL DEFAULT '',
+		body TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL DEFAULT 0,
+		updated_at TEXT NOT NULL DEFAULT 0
+	);
+	`)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
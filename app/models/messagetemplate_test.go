@@ -0,0 +1,81 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestMessageTemplateRender(t *testing.T) {
+	notification := Notification{
+		Title:   "ANPR Alert: AB12CDE",
+		Message: "Number plate AB12CDE was read at Front Gate.",
+		Plate:   "AB12CDE",
+		Link:    "https://anpr.example.com/detections/1",
+	}
+	notification.AddField("Camera", "Front Gate")
+	notification.AddField("Registered To", "<b>Smith & Sons</b>")
+	tests := []struct {
+		name          string
+		template      MessageTemplate
+		title         string
+		body          string
+		expectedError bool
+	}{
+		{"notification title without a title template", MessageTemplate{Format: "text", Body: "{{.Message}}"}, "ANPR Alert: AB12CDE", "Number plate AB12CDE was read at Front Gate.", false},
+		{"blank title template", MessageTemplate{Format: "text", Title: "  ", Body: "{{.Plate}}"}, "ANPR Alert: AB12CDE", "AB12CDE", false},
+		{"title template", MessageTemplate{Format: "text", Title: " {{.Plate}} at {{index .Field \"Camera\"}} ", Body: "{{.Link}}"}, "AB12CDE at Front Gate", "https://anpr.example.com/detections/1", false},
+		{"fields", MessageTemplate{Format: "text", Body: "{{range .Fields}}{{.Name}}: {{.Value}}\n{{end}}"}, "ANPR Alert: AB12CDE", "Camera: Front Gate\nRegistered To: <b>Smith & Sons</b>", false},
+		{"missing field", MessageTemplate{Format: "text", Body: "Vehicle: {{index .Field \"Vehicle\"}}"}, "ANPR Alert: AB12CDE", "Vehicle:", false},
+		{"html escapes values", MessageTemplate{Format: "html", Body: "<p>{{index .Field \"Registered To\"}}</p>"}, "ANPR Alert: AB12CDE", "<p>&lt;b&gt;Smith &amp; Sons&lt;/b&gt;</p>", false},
+		{"html titles aren't escaped", MessageTemplate{Format: "html", Title: "{{index .Field \"Registered To\"}}", Body: "{{.Plate}}"}, "<b>Smith & Sons</b>", "AB12CDE", false},
+		{"invalid body", MessageTemplate{Format: "text", Body: "{{.Plate"}, "", "", true},
+		{"invalid title", MessageTemplate{Format: "text", Title: "{{end}}", Body: "{{.Plate}}"}, "", "", true},
+		{"unknown value", MessageTemplate{Format: "text", Body: "{{.Camera}}"}, "", "", true},
+		{"execution error", MessageTemplate{Format: "text", Body: "{{index .Fields 5}}"}, "", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			title, body, err := test.template.Render(notification)
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("Render() = %q, %q, want an error", title, body)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if title != test.title || body != test.body {
+				t.Errorf("Render() = %q, %q, want %q, %q", title, body, test.title, test.body)
+			}
+		})
+	}
+}
+
+func TestMessageTemplateEmail(t *testing.T) {
+	notification := Notification{Title: "ANPR Alert: AB12CDE", Plate: "AB12CDE", Link: "https://anpr.example.com/detections/1"}
+	notification.AddField("Registered To", "<b>*Smith*</b> [1]")
+	tests := []struct {
+		name     string
+		template MessageTemplate
+		body     string
+	}{
+		{"text values are escaped for markdown", MessageTemplate{Format: "text", Body: "{{index .Field \"Registered To\"}}"}, `&lt;b&gt;\*Smith\*&lt;/b&gt; \[1\]`},
+		{"text links aren't escaped", MessageTemplate{Format: "text", Body: "[View]({{.Link}})"}, "[View](https://anpr.example.com/detections/1)"},
+		{"text template markdown is kept", MessageTemplate{Format: "text", Body: "**{{.Plate}}**"}, "**AB12CDE**"},
+		{"html values are escaped for HTML", MessageTemplate{Format: "html", Body: "<p>{{index .Field \"Registered To\"}}</p>"}, "<p>&lt;b&gt;*Smith*&lt;/b&gt; [1]</p>"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			email, err := test.template.Email(notification, "a@example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if body := string(email.Body.FreeMarkdown); body != test.body {
+				t.Errorf("body = %q, want %q", body, test.body)
+			}
+			if email.Subject != "ANPR Alert: AB12CDE" {
+				t.Errorf("subject = %q, want the notification title", email.Subject)
+			}
+		})
+	}
+}
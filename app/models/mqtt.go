@@ -0,0 +1,205 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"regexp"
+	"time"
+)
+
+// MQTT publishes detections, alerts and camera health to an MQTT broker along with Home Assistant
+// discovery config for each camera. A nil MQTT doesn't publish anything so it can be used when no
+// broker is configured.
+type MQTT struct {
+	client          mqtt.Client
+	env             *Env
+	topicPrefix     string
+	discoveryPrefix string
+	nodeID          string
+}
+
+// mqttNodeIDInvalid matches characters that can't be used in a Home Assistant discovery node ID
+var mqttNodeIDInvalid = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// NewMQTT connects to the MQTT broker in the config, returning nil if no broker is configured. The
+// connection is retried in the background if the broker can't be reached within a few seconds.
+func NewMQTT(env *Env) *MQTT {
+	if env.Config.MQTTBroker == "" {
+		return nil
+	}
+	e := &MQTT{
+		env:             env,
+		topicPrefix:     env.Config.MQTTTopicPrefix,
+		discoveryPrefix: env.Config.MQTTDiscoveryPrefix,
+		nodeID:          mqttNodeIDInvalid.ReplaceAllString(env.Config.MQTTClientID, "_"),
+	}
+	opts := mqtt.NewClientOptions().
+		AddBroker(env.Config.MQTTBroker).
+		SetClientID(env.Config.MQTTClientID).
+		SetUsername(env.Config.MQTTUser).
+		SetPassword(env.Config.MQTTPass).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetWill(e.topic("status"), "offline", 1, true).
+		SetOnConnectHandler(e.onConnect).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			env.Logger.Printf("Lost connection to MQTT broker: %s\n", err)
+		})
+	e.client = mqtt.NewClient(opts)
+	// Give the broker a few seconds to connect so events at startup, like cameras connecting, are published
+	if !e.client.Connect().WaitTimeout(5 * time.Second) {
+		env.Logger.Printf("Couldn't connect to MQTT broker %s, retrying in the background\n", env.Config.MQTTBroker)
+	}
+	return e
+}
+
+// onConnect marks the app as online and publishes the discovery config, republishing it whenever
+// Home Assistant restarts
+func (e *MQTT) onConnect(client mqtt.Client) {
+	e.env.Logger.Println("Connected to MQTT broker")
+	e.publish(e.topic("status"), true, "online")
+	e.PublishDiscovery()
+	client.Subscribe(e.discoveryPrefix+"/status", 1, func(_ mqtt.Client, msg mqtt.Message) {
+		if string(msg.Payload()) == "online" {
+			e.PublishDiscovery()
+		}
+	})
+}
+
+// Close marks the app as offline and disconnects from the broker
+func (e *MQTT) Close() {
+	if e == nil {
+		return
+	}
+	e.client.Publish(e.topic("status"), 1, true, "offline").WaitTimeout(time.Second)
+	e.client.Disconnect(250)
+}
+
+// PublishDetection publishes a detection, updating its camera's last plate unless it's low confidence
+func (e *MQTT) PublishDetection(detection WebhookDetection) {
+	if e == nil {
+		return
+	}
+	e.publish(e.topic("detections"), false, detection)
+	if !detection.Detection.LowConfidence {
+		e.publish(e.cameraTopic(detection.Camera.ID, "last_plate"), true, detection.Detection)
+	}
+}
+
+// PublishAlert publishes an alert
+func (e *MQTT) PublishAlert(alert WebhookAlert) {
+	if e == nil {
+		return
+	}
+	e.publish(e.topic("alerts"), false, alert)
+}
+
+// PublishCameraHealth publishes a camera's status
+func (e *MQTT) PublishCameraHealth(health WebhookCameraHealth) {
+	if e == nil {
+		return
+	}
+	e.publish(e.cameraTopic(health.Camera.ID, "status"), true, health.Status)
+}
+
+// PublishDiscovery publishes the Home Assistant discovery config for every camera
+func (e *MQTT) PublishDiscovery() {
+	if e == nil {
+		return
+	}
+	var camera Camera
+	resCameras, _, err := camera.Find(e.env, "AND", []WhereFields{}, 0, 1)
+	if err != nil {
+		e.env.Logger.Printf("Error finding cameras for MQTT discovery: %s\n", err)
+		return
+	}
+	for _, resCamera := range *resCameras {
+		e.PublishCamera(resCamera)
+	}
+}
+
+// PublishCamera publishes the Home Assistant discovery config for a camera, a device with a status
+// sensor and a last plate sensor
+func (e *MQTT) PublishCamera(camera Camera) {
+	if e == nil {
+		return
+	}
+	device := map[string]interface{}{
+		"identifiers":  []string{fmt.Sprintf("%s_camera_%d", e.nodeID, camera.ID)},
+		"name":         camera.DisplayName(),
+		"manufacturer": "Hikvision",
+		"model":        "ANPR Camera",
+	}
+	availability := []map[string]string{{"topic": e.topic("status")}}
+	e.publish(e.discoveryTopic("binary_sensor", camera.ID, "status"), true, map[string]interface{}{
+		"name":         "Status",
+		"unique_id":    fmt.Sprintf("%s_camera_%d_status", e.nodeID, camera.ID),
+		"state_topic":  e.cameraTopic(camera.ID, "status"),
+		"payload_on":   "online",
+		"payload_off":  "offline",
+		"device_class": "connectivity",
+		"availability": availability,
+		"device":       device,
+	})
+	e.publish(e.discoveryTopic("sensor", camera.ID, "last_plate"), true, map[string]interface{}{
+		"name":                  "Last Plate",
+		"unique_id":             fmt.Sprintf("%s_camera_%d_last_plate", e.nodeID, camera.ID),
+		"state_topic":           e.cameraTopic(camera.ID, "last_plate"),
+		"value_template":        "{{ value_json.plate }}",
+		"json_attributes_topic": e.cameraTopic(camera.ID, "last_plate"),
+		"icon":                  "mdi:car",
+		"availability":          availability,
+		"device":                device,
+	})
+}
+
+// RemoveCamera removes a camera's Home Assistant discovery config and retained state
+func (e *MQTT) RemoveCamera(cameraID int) {
+	if e == nil {
+		return
+	}
+	e.publish(e.discoveryTopic("binary_sensor", cameraID, "status"), true, "")
+	e.publish(e.discoveryTopic("sensor", cameraID, "last_plate"), true, "")
+	e.publish(e.cameraTopic(cameraID, "status"), true, "")
+	e.publish(e.cameraTopic(cameraID, "last_plate"), true, "")
+}
+
+// topic returns the topic under the topic prefix
+func (e *MQTT) topic(name string) string {
+	return e.topicPrefix + "/" + name
+}
+
+// cameraTopic returns the topic for a camera's state
+func (e *MQTT) cameraTopic(cameraID int, name string) string {
+	return e.topic(fmt.Sprintf("cameras/%d/%s", cameraID, name))
+}
+
+// discoveryTopic returns the Home Assistant discovery config topic for a camera's entity
+func (e *MQTT) discoveryTopic(component string, cameraID int, name string) string {
+	return fmt.Sprintf("%s/%s/%s/camera_%d_%s/config", e.discoveryPrefix, component, e.nodeID, cameraID, name)
+}
+
+// publish publishes the payload to the topic, encoding it as JSON unless it's a string, and logs any
+// error in the background
+func (e *MQTT) publish(topic string, retained bool, payload interface{}) {
+	var body []byte
+	if s, ok := payload.(string); ok {
+		body = []byte(s)
+	} else {
+		var err error
+		body, err = json.Marshal(payload)
+		if err != nil {
+			e.env.Logger.Printf("Error encoding MQTT payload for %s: %s\n", topic, err)
+			return
+		}
+	}
+	token := e.client.Publish(topic, 1, retained, body)
+	go func() {
+		if !token.WaitTimeout(10 * time.Second) {
+			e.env.Logger.Printf("Timed out publishing to MQTT topic %s\n", topic)
+		} else if err := token.Error(); err != nil {
+			e.env.Logger.Printf("Error publishing to MQTT topic %s: %s\n", topic, err)
+		}
+	}()
+}
@@ -0,0 +1,202 @@
+package models
+
+import (
+	"encoding/json"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	server "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"io"
+	"log"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mqttMessages records the messages received on each topic
+type mqttMessages struct {
+	mu       sync.Mutex
+	messages map[string]mqtt.Message
+}
+
+// wait returns the last message received on the topic, failing the test if none arrives in time
+func (m *mqttMessages) wait(t *testing.T, topic string) mqtt.Message {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		m.mu.Lock()
+		msg, ok := m.messages[topic]
+		m.mu.Unlock()
+		if ok {
+			return msg
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no message published to %s", topic)
+	return nil
+}
+
+func TestMQTTPublishes(t *testing.T) {
+	// Start an in-process broker
+	broker := server.New(&server.Options{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	if err := broker.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	listener := listeners.NewTCP("test", "127.0.0.1:0", nil)
+	if err := broker.AddListener(listener); err != nil {
+		t.Fatal(err)
+	}
+	if err := broker.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Close()
+	brokerURL := "tcp://" + listener.Address()
+
+	// Set up a database with a camera for the discovery config
+	logger := log.New(io.Discard, "", 0)
+	env := &Env{
+		Config: Config{
+			DBFile:              filepath.Join(t.TempDir(), "test.db"),
+			MQTTBroker:          brokerURL,
+			MQTTClientID:        "anpr-test",
+			MQTTTopicPrefix:     "anpr",
+			MQTTDiscoveryPrefix: "homeassistant",
+		},
+		Logger: logger,
+		DB:     &DB{},
+	}
+	if err := env.DB.Init(env.Config, nil, logger); err != nil {
+		t.Fatal(err)
+	}
+	defer env.DB.Conn.Close()
+	camera := Camera{Name: "Gate", IPAddress: "192.0.2.1", Username: "admin", Password: "password"}
+	if _, err := camera.Migrate(env); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := camera.Add(env); err != nil {
+		t.Fatal(err)
+	}
+
+	// Record everything published
+	received := &mqttMessages{messages: map[string]mqtt.Message{}}
+	subscriber := mqtt.NewClient(mqtt.NewClientOptions().AddBroker(brokerURL).SetClientID("subscriber"))
+	if token := subscriber.Connect(); !token.WaitTimeout(5*time.Second) || token.Error() != nil {
+		t.Fatalf("error connecting subscriber: %v", token.Error())
+	}
+	defer subscriber.Disconnect(250)
+	token := subscriber.Subscribe("#", 1, func(_ mqtt.Client, msg mqtt.Message) {
+		received.mu.Lock()
+		received.messages[msg.Topic()] = msg
+		received.mu.Unlock()
+	})
+	if !token.WaitTimeout(5*time.Second) || token.Error() != nil {
+		t.Fatalf("error subscribing: %v", token.Error())
+	}
+
+	publisher := NewMQTT(env)
+	if publisher == nil {
+		t.Fatal("NewMQTT returned nil with a broker configured")
+	}
+	defer publisher.Close()
+
+	if msg := received.wait(t, "anpr/status"); string(msg.Payload()) != "online" {
+		t.Errorf("status = %q, want online", msg.Payload())
+	}
+
+	// Home Assistant discovery config is published on connect
+	var status map[string]interface{}
+	if err := json.Unmarshal(received.wait(t, "homeassistant/binary_sensor/anpr-test/camera_1_status/config").Payload(), &status); err != nil {
+		t.Fatalf("error decoding status config: %s", err)
+	}
+	for key, want := range map[string]string{
+		"unique_id":   "anpr-test_camera_1_status",
+		"state_topic": "anpr/cameras/1/status",
+		"payload_on":  "online",
+		"payload_off": "offline",
+	} {
+		if status[key] != want {
+			t.Errorf("status config %s = %v, want %s", key, status[key], want)
+		}
+	}
+	var lastPlate map[string]interface{}
+	if err := json.Unmarshal(received.wait(t, "homeassistant/sensor/anpr-test/camera_1_last_plate/config").Payload(), &lastPlate); err != nil {
+		t.Fatalf("error decoding last plate config: %s", err)
+	}
+	for key, want := range map[string]string{
+		"unique_id":      "anpr-test_camera_1_last_plate",
+		"state_topic":    "anpr/cameras/1/last_plate",
+		"value_template": "{{ value_json.plate }}",
+	} {
+		if lastPlate[key] != want {
+			t.Errorf("last plate config %s = %v, want %s", key, lastPlate[key], want)
+		}
+	}
+	if device, _ := lastPlate["device"].(map[string]interface{}); device["name"] != "Gate" {
+		t.Errorf("last plate config device = %v, want name Gate", lastPlate["device"])
+	}
+
+	// Detections are published along with the camera's last plate
+	publisher.PublishDetection(WebhookDetection{
+		Detection: Detection{ID: 5, CameraID: 1, Plate: "AB12CDE", Confidence: 90},
+		Camera:    WebhookCamera{ID: 1, Name: "Gate"},
+	})
+	var detection WebhookDetection
+	if err := json.Unmarshal(received.wait(t, "anpr/detections").Payload(), &detection); err != nil {
+		t.Fatalf("error decoding detection: %s", err)
+	}
+	if detection.Detection.ID != 5 || detection.Detection.Plate != "AB12CDE" || detection.Camera.ID != 1 {
+		t.Errorf("detection = %+v", detection)
+	}
+	lastPlateState := received.wait(t, "anpr/cameras/1/last_plate")
+	var state Detection
+	if err := json.Unmarshal(lastPlateState.Payload(), &state); err != nil {
+		t.Fatalf("error decoding last plate: %s", err)
+	}
+	if state.Plate != "AB12CDE" {
+		t.Errorf("last plate = %q, want AB12CDE", state.Plate)
+	}
+
+	// Low confidence reads don't update the last plate
+	publisher.PublishDetection(WebhookDetection{
+		Detection: Detection{ID: 6, CameraID: 1, Plate: "XX99XXX", Confidence: 20, LowConfidence: true},
+		Camera:    WebhookCamera{ID: 1, Name: "Gate"},
+	})
+	deadline := time.Now().Add(5 * time.Second)
+	for detection.Detection.ID != 6 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		json.Unmarshal(received.wait(t, "anpr/detections").Payload(), &detection)
+	}
+	if detection.Detection.ID != 6 {
+		t.Fatal("low confidence detection wasn't published")
+	}
+	if msg := received.wait(t, "anpr/cameras/1/last_plate"); msg != lastPlateState {
+		t.Errorf("low confidence read updated the last plate to %s", msg.Payload())
+	}
+
+	// The discovery config and state are retained for Home Assistant to pick up when it subscribes
+	retained := &mqttMessages{messages: map[string]mqtt.Message{}}
+	late := mqtt.NewClient(mqtt.NewClientOptions().AddBroker(brokerURL).SetClientID("late"))
+	if token := late.Connect(); !token.WaitTimeout(5*time.Second) || token.Error() != nil {
+		t.Fatalf("error connecting late subscriber: %v", token.Error())
+	}
+	defer late.Disconnect(250)
+	late.Subscribe("#", 1, func(_ mqtt.Client, msg mqtt.Message) {
+		retained.mu.Lock()
+		retained.messages[msg.Topic()] = msg
+		retained.mu.Unlock()
+	})
+	for _, topic := range []string{
+		"homeassistant/binary_sensor/anpr-test/camera_1_status/config",
+		"homeassistant/sensor/anpr-test/camera_1_last_plate/config",
+		"anpr/cameras/1/last_plate",
+	} {
+		if msg := retained.wait(t, topic); !msg.Retained() {
+			t.Errorf("%s isn't retained", topic)
+		}
+	}
+	if msg := retained.wait(t, "anpr/cameras/1/last_plate"); string(msg.Payload()) != string(lastPlateState.Payload()) {
+		t.Errorf("retained last plate = %s, want %s", msg.Payload(), lastPlateState.Payload())
+	}
+}
@@ -0,0 +1,325 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/matcornic/hermes/v2"
+	"strings"
+	"time"
+)
+
+// Notification struct
+type Notification struct {
+	Title      string
+	Message    string
+	Fields     []NotificationField
+	Link       string
+	Snapshot   []byte
+	ChannelIDs []int
+	// Subjects users can subscribe to
+	WatchlistID int
+	CameraID    int
+	RuleID      int
+	// The plate and read the alert is for, repeat alerts for reads of the same plate are cooled down
+	Plate         string
+	NumberPlateID int
+	DetectionID   int
+	// The alert record the notification is for, and whether it's an escalation of it as it hasn't been
+	// acknowledged, which is never cooled down or rate limited
+	AlertID    int
+	Escalation bool
+}
+
+// NotificationField struct
+type NotificationField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// AddField adds a field to the notification if its value isn't empty
+func (e *Notification) AddField(name, value string) {
+	if value != "" {
+		e.Fields = append(e.Fields, NotificationField{Name: name, Value: value})
+	}
+}
+
+// Text returns the notification's message followed by its fields and link as plain text
+func (e *Notification) Text() string {
+	lines := []string{e.Message}
+	for _, field := range e.Fields {
+		lines = append(lines, fmt.Sprintf("%s: %s", field.Name, field.Value))
+	}
+	if e.Link != "" {
+		lines = append(lines, e.Link)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Email returns the notification as an email to the address provided, with its fields, snapshot
+// and a button to view it
+func (e *Notification) Email(to string) Email {
+	var lines []string
+	for _, field := range e.Fields {
+		lines = append(lines, fmt.Sprintf("- **%s:** %s", escapeMarkdown(field.Name), escapeMarkdown(field.Value)))
+	}
+	if len(e.Snapshot) > 0 {
+		lines = append(lines, "", EmailSnapshot)
+	}
+	if e.Link != "" {
+		lines = append(lines, "", "To see more details, click here:", "", emailButton("View in Hikvision ANPR Alerts", e.Link))
+	}
+	return Email{
+		To:      to,
+		Subject: e.Title,
+		Body: hermes.Body{
+			Title:        e.Title,
+			Intros:       []string{e.Message},
+			FreeMarkdown: hermes.Markdown(strings.Join(lines, "\n")),
+			Signature:    "Thanks",
+		},
+		Snapshot: e.Snapshot,
+	}
+}
+
+// Dispatch queues the notification in the outbox for each of its recipients, returning an error for
+// each one it couldn't be queued for
+func (e *Notification) Dispatch(env *Env) error {
+	recipients, err := e.recipients(env)
+	if err != nil {
+		return err
+	}
+	if len(recipients) == 0 {
+		env.Logger.Printf("Not sending alert %q as it has no notification channels or subscribers\n", e.Title)
+		return nil
+	}
+	return e.queue(env, recipients)
+}
+
+// DispatchTo queues the notification for delivery to the email addresses and notification channels provided
+func (e *Notification) DispatchTo(env *Env, emails []string, channelIDs []int) error {
+	return e.queue(env, recipientsFor(emails, channelIDs))
+}
+
+// queue queues the notification in the outbox for each of the recipients provided that the alert
+// limiter allows, skipping emails if SMTP isn't configured and channels that are disabled
+func (e *Notification) queue(env *Env, recipients []notificationRecipient) error {
+	var queueErrors []error
+	now := time.Now()
+	var channelIDs []int
+	for _, recipient := range recipients {
+		if recipient.ChannelID != 0 {
+			channelIDs = append(channelIDs, recipient.ChannelID)
+		}
+	}
+	channels := map[int]NotificationChannel{}
+	if len(channelIDs) > 0 {
+		var notificationChannel NotificationChannel
+		notificationChannels, err := notificationChannel.FindEnabled(env, channelIDs)
+		if err != nil {
+			return err
+		}
+		for _, channel := range notificationChannels {
+			channels[channel.ID] = channel
+		}
+	}
+	cooldownMinutes, err := e.cooldownMinutes(env)
+	if err != nil {
+		return err
+	}
+	for _, recipient := range recipients {
+		if recipient.ChannelID == 0 {
+			if env.Config.SMTPHost == "" || env.Config.SMTPFrom == "" {
+				continue
+			}
+			notification, ok := e.limit(env, recipient.key(), cooldownMinutes, now)
+			if !ok {
+				continue
+			}
+			var outboxMessage OutboxMessage
+			if err := outboxMessage.Queue(env, notification, recipient.Email, 0, recipient.DeferUntil); err != nil {
+				queueErrors = append(queueErrors, fmt.Errorf("error queueing email to %s: %w", recipient.Email, err))
+			}
+			continue
+		}
+		channel, ok := channels[recipient.ChannelID]
+		if !ok {
+			continue
+		}
+		channelCooldownMinutes := cooldownMinutes
+		if channel.CooldownMinutes > 0 {
+			channelCooldownMinutes = channel.CooldownMinutes
+		}
+		notification, ok := e.limit(env, recipient.key(), channelCooldownMinutes, now)
+		if !ok {
+			continue
+		}
+		var outboxMessage OutboxMessage
+		if err := outboxMessage.Queue(env, notification, "", channel.ID, recipient.DeferUntil); err != nil {
+			queueErrors = append(queueErrors, fmt.Errorf("error queueing notification to channel %s: %w", channel.Name, err))
+		}
+	}
+	return errors.Join(queueErrors...)
+}
+
+// cooldownMinutes returns the cooldown of the notification's watchlist, or its camera's if the
+// watchlist doesn't have one, with 0 using the alert limiter's cooldown
+func (e *Notification) cooldownMinutes(env *Env) (int, error) {
+	if e.WatchlistID != 0 {
+		watchlist := Watchlist{ID: e.WatchlistID}
+		resWatchlist, err := watchlist.Get(env)
+		if err != nil && !errors.Is(err, env.DB.ErrRecordNotFound) {
+			return 0, err
+		}
+		if resWatchlist != nil && resWatchlist.CooldownMinutes > 0 {
+			return resWatchlist.CooldownMinutes, nil
+		}
+	}
+	if e.CameraID != 0 {
+		camera := Camera{ID: e.CameraID}
+		resCamera, err := camera.Get(env)
+		if err != nil && !errors.Is(err, env.DB.ErrRecordNotFound) {
+			return 0, err
+		}
+		if resCamera != nil {
+			return resCamera.CooldownMinutes, nil
+		}
+	}
+	return 0, nil
+}
+
+// limit checks the alert limiter allows the notification to be sent to the recipient provided with the
+// cooldown minutes provided, returning the notification with a summary of any alerts suppressed since
+// the last one sent to them. The notification is sent if the limiter can't be checked.
+func (e *Notification) limit(env *Env, recipient string, cooldownMinutes int, now time.Time) (Notification, bool) {
+	notification := *e
+	ok, suppressed, err := env.AlertLimiter.Allow(env, recipient, e, cooldownMinutes, now)
+	if err != nil {
+		env.Logger.Printf("Error checking alert limits for %q to %s: %s\n", e.Title, recipient, err)
+		return notification, true
+	}
+	if !ok {
+		env.Logger.Printf("Suppressed alert %q to %s\n", e.Title, recipient)
+		return notification, false
+	}
+	if suppressed != "" {
+		notification.Fields = append(append([]NotificationField{}, e.Fields...), NotificationField{Name: "Suppressed", Value: suppressed})
+	}
+	return notification, true
+}
+
+// notificationRecipient is an email address or notification channel a notification is sent to, and
+// when to send it if it's deferred by a quiet period
+type notificationRecipient struct {
+	Email      string
+	ChannelID  int
+	DeferUntil time.Time
+}
+
+// key returns the recipient's email address or notification channel as used by the alert limiter
+func (e *notificationRecipient) key() string {
+	if e.ChannelID != 0 {
+		return fmt.Sprintf("channel:%d", e.ChannelID)
+	}
+	return "email:" + e.Email
+}
+
+// recipients returns the email addresses and notification channels the notification is sent to: the
+// channels it targets plus the email address and channels of each user subscribed to it. If it
+// doesn't target any channels and no users are subscribed it isn't sent to anyone.
+//
+// Unless the notification is critical, a user's email address and channels are left out or deferred
+// while they're in a quiet period for them. A channel shared by several users is sent straight away
+// if any of them aren't in a quiet period.
+func (e *Notification) recipients(env *Env) ([]notificationRecipient, error) {
+	var subscription Subscription
+	subscriptions, err := subscription.FindForAlert(env, e.WatchlistID, e.CameraID, e.RuleID)
+	if err != nil {
+		return nil, err
+	}
+	channelIDs := append([]int{}, e.ChannelIDs...)
+	critical, err := e.critical(env)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var recipients []notificationRecipient
+	added := map[string]int{}
+	// add adds the recipient, sending it at the earliest time if it's already been added
+	add := func(recipient notificationRecipient) {
+		if i, ok := added[recipient.key()]; ok {
+			if recipients[i].DeferUntil.After(recipient.DeferUntil) {
+				recipients[i].DeferUntil = recipient.DeferUntil
+			}
+			return
+		}
+		added[recipient.key()] = len(recipients)
+		recipients = append(recipients, recipient)
+	}
+	for _, channelID := range channelIDs {
+		add(notificationRecipient{ChannelID: channelID})
+	}
+	for _, subscription := range subscriptions {
+		var targets []notificationRecipient
+		if subscription.Email {
+			user := User{ID: subscription.UserID}
+			resUser, err := user.Get(env)
+			if err != nil {
+				return nil, err
+			}
+			if resUser.Email != "" {
+				targets = append(targets, notificationRecipient{Email: resUser.Email})
+			}
+		}
+		var notificationTarget NotificationTarget
+		subscriptionChannelIDs, err := notificationTarget.Find(env, "subscription", subscription.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, channelID := range subscriptionChannelIDs {
+			targets = append(targets, notificationRecipient{ChannelID: channelID})
+		}
+		for _, target := range targets {
+			if !critical {
+				quiet, deferUntil, err := QuietUntil(env, subscription.UserID, target.ChannelID, now)
+				if err != nil {
+					return nil, err
+				}
+				if quiet && deferUntil.IsZero() {
+					env.Logger.Printf("Suppressed alert %q to user id %d during a quiet period\n", e.Title, subscription.UserID)
+					continue
+				}
+				target.DeferUntil = deferUntil
+			}
+			add(target)
+		}
+	}
+	return recipients, nil
+}
+
+// recipientsFor returns the email addresses and notification channels provided as recipients
+func recipientsFor(emails []string, channelIDs []int) []notificationRecipient {
+	var recipients []notificationRecipient
+	for _, email := range emails {
+		recipients = append(recipients, notificationRecipient{Email: email})
+	}
+	for _, channelID := range channelIDs {
+		recipients = append(recipients, notificationRecipient{ChannelID: channelID})
+	}
+	return recipients
+}
+
+// critical checks if the notification is for a critical watchlist, which bypasses quiet periods
+func (e *Notification) critical(env *Env) (bool, error) {
+	if e.WatchlistID == 0 {
+		return false, nil
+	}
+	watchlist := Watchlist{ID: e.WatchlistID}
+	resWatchlist, err := watchlist.Get(env)
+	if err != nil {
+		if errors.Is(err, env.DB.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return resWatchlist.Critical, nil
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+)
+
+func TestNotificationEmail(t *testing.T) {
+	notification := Notification{
+		Title:    "ANPR Alert: AB12CDE",
+		Message:  "Number plate AB12CDE was read at Front Gate.",
+		Link:     "https://anpr.example.com/detections/1?from=alert&camera=2",
+		Snapshot: []byte{0xff, 0xd8, 0xff, 0xe0},
+	}
+	notification.AddField("Registered To", "<b>Smith & Sons</b>")
+	email := notification.Email("a@example.com")
+	if email.To != "a@example.com" || email.Subject != notification.Title {
+		t.Errorf("email to %q with subject %q", email.To, email.Subject)
+	}
+	_, parts := readEmailMessage(t, email)
+	var htmlBody, textBody []byte
+	for _, part := range parts {
+		switch part.mediaType {
+		case "text/html":
+			htmlBody = part.body
+		case "text/plain":
+			textBody = part.body
+		}
+	}
+
+	// The button links to the detection after the snapshot
+	button := regexp.MustCompile(`<a href="([^"]*)" class="button"[^>]*>\s*View in Hikvision ANPR Alerts\s*</a>`).FindSubmatchIndex(htmlBody)
+	if button == nil {
+		t.Fatalf("HTML body = %s, want a button", htmlBody)
+	}
+	if link := string(htmlBody[button[2]:button[3]]); link != "https://anpr.example.com/detections/1?from=alert&amp;camera=2" {
+		t.Errorf("button links to %q, want the detection", link)
+	}
+	if snapshot := bytes.Index(htmlBody, []byte(`src="cid:snapshot"`)); snapshot == -1 || snapshot > button[0] {
+		t.Errorf("HTML body = %s, want the snapshot before the button", htmlBody)
+	}
+	if !bytes.Contains(htmlBody, []byte("&lt;b&gt;Smith &amp; Sons&lt;/b&gt;")) {
+		t.Errorf("HTML body = %s, want the field escaped", htmlBody)
+	}
+	if !bytes.Contains(textBody, []byte(notification.Link)) {
+		t.Errorf("plain text body = %s, want the link", textBody)
+	}
+
+	// There's no button without a link
+	notification.Link = ""
+	_, parts = readEmailMessage(t, notification.Email("a@example.com"))
+	for _, part := range parts {
+		if bytes.Contains(part.body, []byte("View in Hikvision ANPR Alerts")) {
+			t.Errorf("%s body = %s, want no button", part.mediaType, part.body)
+		}
+	}
+}
+
+func TestNotificationCooldownMinutes(t *testing.T) {
+	env := newTestEnv(t)
+	camera := Camera{Name: "Gate", IPAddress: "192.168.1.64", CooldownMinutes: 10}
+	if _, err := camera.Add(env); err != nil {
+		t.Fatal(err)
+	}
+	watchlist := Watchlist{Name: "Visitors", CooldownMinutes: 30}
+	if _, err := watchlist.Add(env); err != nil {
+		t.Fatal(err)
+	}
+	noCooldown := Watchlist{Name: "Staff"}
+	if _, err := noCooldown.Add(env); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		notification Notification
+		want         int
+	}{
+		{"watchlist", Notification{WatchlistID: watchlist.ID, CameraID: camera.ID}, 30},
+		{"watchlist without a cooldown uses the camera's", Notification{WatchlistID: noCooldown.ID, CameraID: camera.ID}, 10},
+		{"camera", Notification{CameraID: camera.ID}, 10},
+		{"deleted camera", Notification{CameraID: camera.ID + 1}, 0},
+		{"neither", Notification{}, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.notification.cooldownMinutes(env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("cooldownMinutes() = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
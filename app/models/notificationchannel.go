@@ -0,0 +1,196 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/containrrr/shoutrrr"
+	"github.com/containrrr/shoutrrr/pkg/types"
+)
+
+// NotificationChannel struct
+type NotificationChannel struct {
+	ID              int    `json:"id"`
+	Name            string `json:"name" validate:"required"`
+	URL             string `json:"url" validate:"required"`
+	Enabled         bool   `json:"enabled"`
+	TemplateID      int    `json:"templateID" db:"template_id"`
+	CooldownMinutes int    `json:"cooldownMinutes" db:"cooldown_minutes" validate:"min=0"`
+	CreatedAt       string `json:"createdAt" db:"created_at"`
+	UpdatedAt       string `json:"updatedAt" db:"updated_at"`
+}
+
+// Add notification channel
+func (e *NotificationChannel) Add(env *Env) (int64, error) {
+	// Add to database
+	res, err := env.DB.Exec(
+		"INSERT INTO notification_channels (name, url, enabled, template_id, cooldown_minutes, created_at, updated_at) VALUES (?, ?, ?, ?, ?, DATETIME('now', 'localtime'), DATETIME('now', 'localtime'))",
+		&e.Name, &e.URL, &e.Enabled, &e.TemplateID, &e.CooldownMinutes,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	e.ID = int(id)
+	return res.RowsAffected()
+}
+
+// Get notification channel by ID provided
+func (e *NotificationChannel) Get(env *Env) (*NotificationChannel, error) {
+	// Get from database
+	var notificationChannels []NotificationChannel
+	err := env.DB.Query(&notificationChannels, "SELECT * FROM notification_channels WHERE id = ? LIMIT 1", &e.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(notificationChannels) == 0 {
+		return nil, env.DB.ErrRecordNotFound
+	}
+	return &notificationChannels[0], nil
+}
+
+// Find notification channels by fields provided
+func (e *NotificationChannel) Find(env *Env, operator string, fields []WhereFields, perPage int, pageNumber int) (*[]NotificationChannel, int, error) {
+	resCount := 0
+	// Where
+	whereSQL, values := env.DB.WhereSQL(operator, fields)
+	// Limit
+	limitSQL := env.DB.LimitSQL(perPage, pageNumber)
+	if limitSQL != "" {
+		// Get count from database
+		var notificationChannels []NotificationChannel
+		err := env.DB.Query(&notificationChannels, "SELECT id FROM notification_channels"+whereSQL, values...)
+		if err != nil {
+			return nil, 0, err
+		}
+		resCount = len(notificationChannels)
+	}
+	// Get from database
+	var notificationChannels []NotificationChannel
+	err := env.DB.Query(&notificationChannels, "SELECT * FROM notification_channels"+whereSQL+" ORDER BY name"+limitSQL, values...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resCount == 0 {
+		resCount = len(notificationChannels)
+	}
+	return &notificationChannels, resCount, nil
+}
+
+// FindEnabled finds the enabled notification channels with the IDs provided, or all enabled
+// notification channels if no IDs are provided
+func (e *NotificationChannel) FindEnabled(env *Env, ids []int) ([]NotificationChannel, error) {
+	var notificationChannels []NotificationChannel
+	var err error
+	if len(ids) == 0 {
+		err = env.DB.Query(&notificationChannels, "SELECT * FROM notification_channels WHERE enabled = 1 ORDER BY name")
+	} else {
+		err = env.DB.Query(&notificationChannels, "SELECT * FROM notification_channels WHERE enabled = 1 AND id IN (?) ORDER BY name", ids)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return notificationChannels, nil
+}
+
+// Update notification channel
+func (e *NotificationChannel) Update(env *Env) (int64, error) {
+	// Update database
+	res, err := env.DB.Exec(
+		"UPDATE notification_channels SET name = ?, url = ?, enabled = ?, template_id = ?, cooldown_minutes = ?, updated_at = DATETIME('now', 'localtime') WHERE id = ?",
+		&e.Name, &e.URL, &e.Enabled, &e.TemplateID, &e.CooldownMinutes, &e.ID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Delete notification channel
+func (e *NotificationChannel) Delete(env *Env) (int64, error) {
+	// Delete from database
+	res, err := env.DB.Exec("DELETE FROM notification_channels WHERE id = ?", &e.ID)
+	if err != nil {
+		return 0, err
+	}
+	// Delete notification targets
+	_, err = env.DB.Exec("DELETE FROM notification_targets WHERE channel_id = ?", &e.ID)
+	if err != nil {
+		return 0, err
+	}
+	// Delete quiet periods for the notification channel
+	_, err = env.DB.Exec("DELETE FROM quiet_periods WHERE channel_type = 'channel' AND channel_id = ?", &e.ID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Check checks the notification channel's URL is a valid shoutrrr service URL
+func (e *NotificationChannel) Check() error {
+	_, err := shoutrrr.CreateSender(e.URL)
+	return err
+}
+
+// Send sends a message with the title provided to the notification channel
+func (e *NotificationChannel) Send(env *Env, title, message string) error {
+	sender, err := shoutrrr.NewSender(env.Logger, e.URL)
+	if err != nil {
+		return err
+	}
+	var sendErrors []error
+	for _, err := range sender.Send(message, (*types.Params)(&map[string]string{"title": title})) {
+		if err != nil {
+			sendErrors = append(sendErrors, err)
+		}
+	}
+	return errors.Join(sendErrors...)
+}
+
+// SendTest sends a sample alert to the notification channel using its message template, even if
+// it's disabled, returning the error from the service if it fails
+func (e *NotificationChannel) SendTest(env *Env) error {
+	notification := TestNotification(env)
+	messageTemplate, err := notification.messageTemplate(env, e.TemplateID)
+	if err != nil {
+		return err
+	}
+	if messageTemplate != nil {
+		title, body, err := messageTemplate.Render(notification)
+		if err != nil {
+			return err
+		}
+		return e.Send(env, title, body)
+	}
+	return e.Send(env, notification.Title, notification.Text())
+}
+
+// Migrate notification channels
+func (e *NotificationChannel) Migrate(env *Env) (sql.Result, error) {
+	// Create table if not exists
+	res, err := env.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS notification_channels (
+		id INTEGER NOT NULL PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		url TEXT NOT NULL,
+		enabled INTEGER NOT NULL DEFAULT 1,
+		template_id INTEGER NOT NULL DEFAULT 0,
+		cooldown_minutes INTEGER NOT NULL DEFAULT 0,
+		created_at TEXT NOT NULL DEFAULT 0,
+		updated_at TEXT NOT NULL DEFAULT 0
+	);
+	`)
+	if err != nil {
+		return nil, err
+	}
+	// Add columns missing from older databases
+	if err := env.DB.AddColumn("notification_channels", "template_id", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	if err := env.DB.AddColumn("notification_channels", "cooldown_minutes", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
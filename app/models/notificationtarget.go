@@ -0,0 +1,85 @@
+package models
+
+import (
+	"database/sql"
+	"strconv"
+)
+
+// NotificationTarget struct
+type NotificationTarget struct {
+	ID        int    `json:"id"`
+	OwnerType string `json:"ownerType" db:"owner_type"`
+	OwnerID   int    `json:"ownerID" db:"owner_id"`
+	ChannelID int    `json:"channelID" db:"channel_id"`
+}
+
+// Find notification channel IDs targeted by the owner type and ID provided
+func (e *NotificationTarget) Find(env *Env, ownerType string, ownerID int) ([]int, error) {
+	// Get from database
+	var notificationTargets []NotificationTarget
+	err := env.DB.Query(&notificationTargets, "SELECT * FROM notification_targets WHERE owner_type = ? AND owner_id = ?", ownerType, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	var channelIDs []int
+	for _, notificationTarget := range notificationTargets {
+		channelIDs = append(channelIDs, notificationTarget.ChannelID)
+	}
+	return channelIDs, nil
+}
+
+// Set replaces the notification channels targeted by the owner type and ID provided
+func (e *NotificationTarget) Set(env *Env, ownerType string, ownerID int, channelIDs []int) error {
+	// Delete existing targets from database
+	if err := e.Delete(env, ownerType, ownerID); err != nil {
+		return err
+	}
+	// Add to database
+	for _, channelID := range channelIDs {
+		_, err := env.DB.Exec(
+			"INSERT INTO notification_targets (owner_type, owner_id, channel_id) VALUES (?, ?, ?)",
+			ownerType, ownerID, channelID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete notification targets for the owner type and ID provided
+func (e *NotificationTarget) Delete(env *Env, ownerType string, ownerID int) error {
+	_, err := env.DB.Exec("DELETE FROM notification_targets WHERE owner_type = ? AND owner_id = ?", ownerType, ownerID)
+	return err
+}
+
+// ParseChannelIDs parses notification channel IDs posted by a form, ignoring any invalid IDs
+func ParseChannelIDs(values []string) []int {
+	var channelIDs []int
+	for _, value := range values {
+		id, err := strconv.Atoi(value)
+		if err != nil || id == 0 {
+			continue
+		}
+		channelIDs = append(channelIDs, id)
+	}
+	return channelIDs
+}
+
+// Migrate notification targets
+func (e *NotificationTarget) Migrate(env *Env) (sql.Result, error) {
+	// Create table if not exists
+	res, err := env.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS notification_targets (
+		id INTEGER NOT NULL PRIMARY KEY,
+		owner_type TEXT NOT NULL,
+		owner_id INTEGER NOT NULL,
+		channel_id INTEGER NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS notification_targets_owner ON notification_targets (owner_type, owner_id);
+	`)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -2,27 +2,41 @@ package models
 
 import (
 	"database/sql"
+	"time"
 )
 
 // NumberPlate struct
 type NumberPlate struct {
-	ID        int    `json:"id"`
-	Plate     string `json:"plate" validate:"required"`
-	Name      string `json:"name"`
-	CreatedAt string `json:"createdAt" db:"created_at"`
-	UpdatedAt string `json:"updatedAt" db:"updated_at"`
+	ID          int    `json:"id"`
+	Plate       string `json:"plate" validate:"required"`
+	Name        string `json:"name"`
+	WatchlistID int    `json:"watchlistID" db:"watchlist_id"`
+	ScheduleID  int    `json:"scheduleID" db:"schedule_id"`
+	ValidFrom   string `json:"validFrom" db:"valid_from" validate:"omitempty,datetime=2006-01-02"`
+	ValidUntil  string `json:"validUntil" db:"valid_until" validate:"omitempty,datetime=2006-01-02"`
+	Archived    bool   `json:"archived"`
+	VehicleAttributes
+	AlertOnMismatch bool   `json:"alertOnMismatch" db:"alert_on_mismatch"`
+	AlertDirection  string `json:"alertDirection" db:"alert_direction" validate:"omitempty,oneof=entry exit"`
+	CreatedAt       string `json:"createdAt" db:"created_at"`
+	UpdatedAt       string `json:"updatedAt" db:"updated_at"`
 }
 
 // Add number plate
 func (e *NumberPlate) Add(env *Env) (int64, error) {
 	// Add to database
 	res, err := env.DB.Exec(
-		"INSERT INTO number_plates (plate, name, created_at, updated_at) VALUES (?, ?, DATE(), DATE())",
-		&e.Plate, &e.Name,
+		"INSERT INTO number_plates (plate, name, watchlist_id, schedule_id, valid_from, valid_until, archived, vehicle_type, colour, brand, model, alert_on_mismatch, alert_direction, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, DATE(), DATE())",
+		&e.Plate, &e.Name, &e.WatchlistID, &e.ScheduleID, &e.ValidFrom, &e.ValidUntil, &e.Archived, &e.VehicleType, &e.Colour, &e.Brand, &e.Model, &e.AlertOnMismatch, &e.AlertDirection,
 	)
 	if err != nil {
 		return 0, err
 	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	e.ID = int(id)
 	return res.RowsAffected()
 }
 
@@ -34,6 +48,9 @@ func (e *NumberPlate) Get(env *Env) (*NumberPlate, error) {
 	if err != nil {
 		return nil, err
 	}
+	if len(numberPlates) == 0 {
+		return nil, env.DB.ErrRecordNotFound
+	}
 	return &numberPlates[0], nil
 }
 
@@ -69,8 +86,8 @@ func (e *NumberPlate) Find(env *Env, operator string, fields []WhereFields, perP
 func (e *NumberPlate) Update(env *Env) (int64, error) {
 	// Update database
 	res, err := env.DB.Exec(
-		"UPDATE number_plates SET plate = ?, name = ?, updated_at = DATE() WHERE id = ?",
-		&e.Plate, &e.Name, &e.ID,
+		"UPDATE number_plates SET plate = ?, name = ?, watchlist_id = ?, schedule_id = ?, valid_from = ?, valid_until = ?, archived = ?, vehicle_type = ?, colour = ?, brand = ?, model = ?, alert_on_mismatch = ?, alert_direction = ?, updated_at = DATE() WHERE id = ?",
+		&e.Plate, &e.Name, &e.WatchlistID, &e.ScheduleID, &e.ValidFrom, &e.ValidUntil, &e.Archived, &e.VehicleType, &e.Colour, &e.Brand, &e.Model, &e.AlertOnMismatch, &e.AlertDirection, &e.ID,
 	)
 	if err != nil {
 		return 0, err
@@ -85,6 +102,71 @@ func (e *NumberPlate) Delete(env *Env) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
+	// Delete camera scopes
+	var cameraScope CameraScope
+	if err := cameraScope.Delete(env, "numberplate", e.ID); err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// FindByPlate finds number plates matching the plate provided, ignoring case and spaces
+func (e *NumberPlate) FindByPlate(env *Env, plate string) (*[]NumberPlate, int, error) {
+	return e.Find(env, "AND", []WhereFields{{"REPLACE(UPPER(plate), ' ', '')", "=", NormalisePlate(plate)}}, 0, 1)
+}
+
+// ValidAt checks if the number plate is valid on the date of the time provided
+func (e *NumberPlate) ValidAt(t time.Time) bool {
+	date := t.Format("2006-01-02")
+	if e.Archived {
+		return false
+	}
+	if e.ValidFrom != "" && date < e.ValidFrom {
+		return false
+	}
+	if e.ValidUntil != "" && date > e.ValidUntil {
+		return false
+	}
+	return true
+}
+
+// ExpiredAt checks if the number plate's validity window has ended by the time provided
+func (e *NumberPlate) ExpiredAt(t time.Time) bool {
+	return e.ValidUntil != "" && t.Format("2006-01-02") > e.ValidUntil
+}
+
+// ExpiresWithin checks if the number plate's validity window ends within the number of days provided
+func (e *NumberPlate) ExpiresWithin(t time.Time, days int) bool {
+	return e.ValidUntil != "" && !e.ExpiredAt(t) && t.AddDate(0, 0, days).Format("2006-01-02") >= e.ValidUntil
+}
+
+// ArchiveExpired archives number plates whose validity window ended before the time provided
+func (e *NumberPlate) ArchiveExpired(env *Env, t time.Time) (int64, error) {
+	res, err := env.DB.Exec(
+		"UPDATE number_plates SET archived = 1, updated_at = DATE() WHERE archived = 0 AND valid_until != '' AND valid_until < ?",
+		t.Format("2006-01-02"),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteExpired deletes number plates whose validity window ended before the time provided
+func (e *NumberPlate) DeleteExpired(env *Env, t time.Time) (int64, error) {
+	date := t.Format("2006-01-02")
+	// Delete camera scopes first so they aren't left for a new number plate that reuses the ID
+	_, err := env.DB.Exec(
+		"DELETE FROM camera_scopes WHERE owner_type = 'numberplate' AND owner_id IN (SELECT id FROM number_plates WHERE valid_until != '' AND valid_until < ?)",
+		date,
+	)
+	if err != nil {
+		return 0, err
+	}
+	res, err := env.DB.Exec("DELETE FROM number_plates WHERE valid_until != '' AND valid_until < ?", date)
+	if err != nil {
+		return 0, err
+	}
 	return res.RowsAffected()
 }
 
@@ -96,6 +178,17 @@ func (e *NumberPlate) Migrate(env *Env) (sql.Result, error) {
 		id INTEGER NOT NULL PRIMARY KEY,
 		plate TEXT NOT NULL UNIQUE,
 		name TEXT NOT NULL,
+		watchlist_id INTEGER NOT NULL DEFAULT 0,
+		schedule_id INTEGER NOT NULL DEFAULT 0,
+		valid_from TEXT NOT NULL DEFAULT '',
+		valid_until TEXT NOT NULL DEFAULT '',
+		archived INTEGER NOT NULL DEFAULT 0,
+		vehicle_type TEXT NOT NULL DEFAULT '',
+		colour TEXT NOT NULL DEFAULT '',
+		brand TEXT NOT NULL DEFAULT '',
+		model TEXT NOT NULL DEFAULT '',
+		alert_on_mismatch INTEGER NOT NULL DEFAULT 0,
+		alert_direction TEXT NOT NULL DEFAULT '',
 		created_at TEXT NOT NULL DEFAULT 0,
 		updated_at TEXT NOT NULL DEFAULT 0
 	);
@@ -103,5 +196,32 @@ func (e *NumberPlate) Migrate(env *Env) (sql.Result, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Add columns missing from older databases
+	if err := env.DB.AddColumn("number_plates", "watchlist_id", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	if err := env.DB.AddColumn("number_plates", "schedule_id", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	if err := env.DB.AddColumn("number_plates", "valid_from", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return nil, err
+	}
+	if err := env.DB.AddColumn("number_plates", "valid_until", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return nil, err
+	}
+	if err := env.DB.AddColumn("number_plates", "archived", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	for _, column := range []string{"vehicle_type", "colour", "brand", "model"} {
+		if err := env.DB.AddColumn("number_plates", column, "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return nil, err
+		}
+	}
+	if err := env.DB.AddColumn("number_plates", "alert_on_mismatch", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	if err := env.DB.AddColumn("number_plates", "alert_direction", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return nil, err
+	}
 	return res, nil
 }
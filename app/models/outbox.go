@@ -0,0 +1,325 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// OutboxQueued is signalled whenever a message is added to the outbox so the worker can deliver it
+// straight away
+var OutboxQueued = make(chan struct{}, 1)
+
+// outboxRetries are how long to wait before each retry of a message that failed to deliver
+var outboxRetries = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute, time.Hour}
+
+// OutboxMessage is a notification queued to be delivered to an email address or notification channel
+type OutboxMessage struct {
+	ID            int    `json:"id"`
+	AlertID       int    `json:"alertID" db:"alert_id"`
+	DetectionID   int    `json:"detectionID" db:"detection_id"`
+	Title         string `json:"title"`
+	Email         string `json:"email"`
+	ChannelID     int    `json:"channelID" db:"channel_id"`
+	Notification  string `json:"notification"`
+	Status        string `json:"status"`
+	Attempts      int    `json:"attempts"`
+	Error         string `json:"error"`
+	NextAttemptAt string `json:"nextAttemptAt" db:"next_attempt_at"`
+	SentAt        string `json:"sentAt" db:"sent_at"`
+	CreatedAt     string `json:"createdAt" db:"created_at"`
+	UpdatedAt     string `json:"updatedAt" db:"updated_at"`
+}
+
+// Queue adds a notification to the outbox to be delivered to the email address or notification
+// channel provided, straight away or at the time provided if it's deferred
+func (e *OutboxMessage) Queue(env *Env, notification Notification, email string, channelID int, at time.Time) error {
+	// Store the detection's snapshot once rather than in every message queued for it
+	if len(notification.Snapshot) > 0 && notification.DetectionID != 0 {
+		snapshot := Snapshot{DetectionID: notification.DetectionID, Picture: notification.Snapshot}
+		if _, err := snapshot.Add(env); err != nil {
+			return err
+		}
+		notification.Snapshot = nil
+	}
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	e.AlertID = notification.AlertID
+	e.DetectionID = notification.DetectionID
+	e.Title = notification.Title
+	e.Email = email
+	e.ChannelID = channelID
+	e.Notification = string(body)
+	e.Status = "pending"
+	if at.IsZero() {
+		at = time.Now()
+	}
+	e.NextAttemptAt = at.Format(DateTimeFormat)
+	if _, err := e.Add(env); err != nil {
+		return err
+	}
+	select {
+	case OutboxQueued <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Add outbox message
+func (e *OutboxMessage) Add(env *Env) (int64, error) {
+	// Add to database
+	res, err := env.DB.Exec(
+		"INSERT INTO outbox (alert_id, detection_id, title, email, channel_id, notification, status, attempts, error, next_attempt_at, sent_at, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, DATETIME('now', 'localtime'), DATETIME('now', 'localtime'))",
+		&e.AlertID, &e.DetectionID, &e.Title, &e.Email, &e.ChannelID, &e.Notification, &e.Status, &e.Attempts, &e.Error, &e.NextAttemptAt, &e.SentAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	e.ID = int(id)
+	return res.RowsAffected()
+}
+
+// Get outbox message by ID provided
+func (e *OutboxMessage) Get(env *Env) (*OutboxMessage, error) {
+	// Get from database
+	var outboxMessages []OutboxMessage
+	err := env.DB.Query(&outboxMessages, "SELECT * FROM outbox WHERE id = ? LIMIT 1", &e.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(outboxMessages) == 0 {
+		return nil, env.DB.ErrRecordNotFound
+	}
+	return &outboxMessages[0], nil
+}
+
+// Find outbox messages by fields provided
+func (e *OutboxMessage) Find(env *Env, operator string, fields []WhereFields, perPage int, pageNumber int) (*[]OutboxMessage, int, error) {
+	resCount := 0
+	// Where
+	whereSQL, values := env.DB.WhereSQL(operator, fields)
+	// Limit
+	limitSQL := env.DB.LimitSQL(perPage, pageNumber)
+	if limitSQL != "" {
+		// Get count from database
+		var outboxMessages []OutboxMessage
+		err := env.DB.Query(&outboxMessages, "SELECT id FROM outbox"+whereSQL, values...)
+		if err != nil {
+			return nil, 0, err
+		}
+		resCount = len(outboxMessages)
+	}
+	// Get from database
+	var outboxMessages []OutboxMessage
+	err := env.DB.Query(&outboxMessages, "SELECT * FROM outbox"+whereSQL+" ORDER BY id DESC"+limitSQL, values...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resCount == 0 {
+		resCount = len(outboxMessages)
+	}
+	return &outboxMessages, resCount, nil
+}
+
+// Update outbox message delivery status
+func (e *OutboxMessage) Update(env *Env) (int64, error) {
+	// Update database
+	res, err := env.DB.Exec(
+		"UPDATE outbox SET status = ?, attempts = ?, error = ?, next_attempt_at = ?, sent_at = ?, updated_at = DATETIME('now', 'localtime') WHERE id = ?",
+		&e.Status, &e.Attempts, &e.Error, &e.NextAttemptAt, &e.SentAt, &e.ID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteFinished deletes messages that were sent or failed before the time provided
+func (e *OutboxMessage) DeleteFinished(env *Env, before time.Time) (int64, error) {
+	// Delete from database
+	res, err := env.DB.Exec(
+		"DELETE FROM outbox WHERE status IN ('sent', 'failed') AND updated_at < ?",
+		before.Format(DateTimeFormat),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Resend queues a failed outbox message to be delivered again
+func (e *OutboxMessage) Resend(env *Env) error {
+	e.Status = "pending"
+	e.Attempts = 0
+	e.Error = ""
+	e.NextAttemptAt = time.Now().Format(DateTimeFormat)
+	if _, err := e.Update(env); err != nil {
+		return err
+	}
+	select {
+	case OutboxQueued <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Deliver sends the outbox message, returning any error
+func (e *OutboxMessage) Deliver(env *Env) error {
+	var notification Notification
+	if err := json.Unmarshal([]byte(e.Notification), &notification); err != nil {
+		return err
+	}
+	if e.Email != "" {
+		if err := notification.loadSnapshot(env); err != nil {
+			return err
+		}
+		// Use the watchlist's message template if it has one
+		messageTemplate, err := notification.messageTemplate(env, 0)
+		if err != nil {
+			return err
+		}
+		email := notification.Email(e.Email)
+		if messageTemplate != nil {
+			templateEmail, err := messageTemplate.Email(notification, e.Email)
+			if err != nil {
+				// Send the default message rather than nothing
+				env.Logger.Printf("Error rendering message template %q for %q: %s\n", messageTemplate.Name, notification.Title, err)
+			} else {
+				email = templateEmail
+			}
+		}
+		return email.Send(env)
+	}
+	notificationChannel := NotificationChannel{ID: e.ChannelID}
+	channel, err := notificationChannel.Get(env)
+	if err != nil {
+		if errors.Is(err, env.DB.ErrRecordNotFound) {
+			return errors.New("notification channel has been deleted")
+		}
+		return err
+	}
+	if !channel.Enabled {
+		return fmt.Errorf("notification channel %s is disabled", channel.Name)
+	}
+	// Use the channel's message template, or the watchlist's if the channel doesn't have one
+	messageTemplate, err := notification.messageTemplate(env, channel.TemplateID)
+	if err != nil {
+		return err
+	}
+	if messageTemplate != nil {
+		title, body, err := messageTemplate.Render(notification)
+		if err == nil {
+			return channel.Send(env, title, body)
+		}
+		// Send the default message rather than nothing
+		env.Logger.Printf("Error rendering message template %q for %q: %s\n", messageTemplate.Name, notification.Title, err)
+	}
+	return channel.Send(env, notification.Title, notification.Text())
+}
+
+// loadSnapshot loads the snapshot of the notification's detection if it was stored when queued
+func (e *Notification) loadSnapshot(env *Env) error {
+	if len(e.Snapshot) > 0 || e.DetectionID == 0 {
+		return nil
+	}
+	snapshot := Snapshot{DetectionID: e.DetectionID}
+	resSnapshot, err := snapshot.Get(env)
+	if err != nil {
+		if errors.Is(err, env.DB.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	e.Snapshot = resSnapshot.Picture
+	return nil
+}
+
+// Attempt delivers the outbox message and records the result, scheduling a retry if it failed and
+// there are retries left
+func (e *OutboxMessage) Attempt(env *Env) error {
+	err := e.Deliver(env)
+	now := time.Now()
+	e.Attempts++
+	switch {
+	case err == nil:
+		e.Status = "sent"
+		e.Error = ""
+		e.SentAt = now.Format(DateTimeFormat)
+	case e.Attempts <= len(outboxRetries):
+		e.Error = err.Error()
+		e.NextAttemptAt = now.Add(outboxRetries[e.Attempts-1]).Format(DateTimeFormat)
+	default:
+		e.Status = "failed"
+		e.Error = err.Error()
+	}
+	if _, updateErr := e.Update(env); updateErr != nil {
+		return updateErr
+	}
+	return err
+}
+
+// DeliverOutbox attempts to deliver every pending outbox message that's due
+func DeliverOutbox(env *Env) error {
+	var outboxMessage OutboxMessage
+	resOutboxMessages, _, err := outboxMessage.Find(env, "AND", []WhereFields{
+		{"status", "=", "pending"},
+		{"next_attempt_at", "<=", time.Now().Format(DateTimeFormat)},
+	}, 0, 1)
+	if err != nil {
+		return err
+	}
+	// Deliver the oldest first
+	for i := len(*resOutboxMessages) - 1; i >= 0; i-- {
+		resOutboxMessage := (*resOutboxMessages)[i]
+		if err := resOutboxMessage.Attempt(env); err != nil {
+			env.Logger.Printf("Error delivering %q (attempt %d): %s\n", resOutboxMessage.Title, resOutboxMessage.Attempts, err)
+		}
+	}
+	return nil
+}
+
+// Migrate outbox
+func (e *OutboxMessage) Migrate(env *Env) (sql.Result, error) {
+	// Create table if not exists
+	res, err := env.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS outbox (
+		id INTEGER NOT NULL PRIMARY KEY,
+		alert_id INTEGER NOT NULL DEFAULT 0,
+		detection_id INTEGER NOT NULL DEFAULT 0,
+		title TEXT NOT NULL,
+		email TEXT NOT NULL DEFAULT '',
+		channel_id INTEGER NOT NULL DEFAULT 0,
+		notification TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		next_attempt_at TEXT NOT NULL DEFAULT '',
+		sent_at TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL DEFAULT 0,
+		updated_at TEXT NOT NULL DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS outbox_status ON outbox (status, next_attempt_at);
+	`)
+	if err != nil {
+		return nil, err
+	}
+	// Add columns missing from older databases
+	if err := env.DB.AddColumn("outbox", "alert_id", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	if err := env.DB.AddColumn("outbox", "detection_id", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	if _, err := env.DB.Exec("CREATE INDEX IF NOT EXISTS outbox_detection ON outbox (detection_id)"); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOutboxRetrySchedule(t *testing.T) {
+	env := newTestEnv(t)
+	// Messages to a deleted notification channel always fail to deliver
+	var outboxMessage OutboxMessage
+	if err := outboxMessage.Queue(env, Notification{Title: "ANPR Alert: AB12CDE"}, "", 999, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		attempts int
+		status   string
+		retryIn  time.Duration
+	}{
+		{1, "pending", time.Minute},
+		{2, "pending", 5 * time.Minute},
+		{3, "pending", 15 * time.Minute},
+		{4, "pending", time.Hour},
+		{5, "failed", 0},
+	}
+	for _, test := range tests {
+		before := time.Now().Truncate(time.Second)
+		if err := outboxMessage.Attempt(env); err == nil {
+			t.Fatal("Attempt didn't return an error")
+		}
+		resOutboxMessage, err := outboxMessage.Get(env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resOutboxMessage.Attempts != test.attempts || resOutboxMessage.Status != test.status {
+			t.Errorf("after attempt %d: attempts = %d, status = %q, want %d, %q", test.attempts, resOutboxMessage.Attempts, resOutboxMessage.Status, test.attempts, test.status)
+		}
+		if resOutboxMessage.Error != "notification channel has been deleted" {
+			t.Errorf("after attempt %d: error = %q", test.attempts, resOutboxMessage.Error)
+		}
+		if test.retryIn == 0 {
+			continue
+		}
+		nextAttemptAt, err := time.ParseInLocation(DateTimeFormat, resOutboxMessage.NextAttemptAt, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if retryIn := nextAttemptAt.Sub(before); retryIn < test.retryIn || retryIn > test.retryIn+2*time.Second {
+			t.Errorf("after attempt %d: retrying in %s, want %s", test.attempts, retryIn, test.retryIn)
+		}
+	}
+
+	// Resending a failed message starts the retries again
+	if err := outboxMessage.Resend(env); err != nil {
+		t.Fatal(err)
+	}
+	resOutboxMessage, err := outboxMessage.Get(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resOutboxMessage.Attempts != 0 || resOutboxMessage.Status != "pending" || resOutboxMessage.Error != "" {
+		t.Errorf("after resending: attempts = %d, status = %q, error = %q", resOutboxMessage.Attempts, resOutboxMessage.Status, resOutboxMessage.Error)
+	}
+}
+
+func TestDeliverOutboxWaitsForRetry(t *testing.T) {
+	env := newTestEnv(t)
+	var outboxMessage OutboxMessage
+	if err := outboxMessage.Queue(env, Notification{Title: "ANPR Alert: AB12CDE"}, "", 999, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	var deferred OutboxMessage
+	if err := deferred.Queue(env, Notification{Title: "ANPR Alert: XY34ZZZ"}, "", 999, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	// The first delivery fails and isn't retried until its retry is due, and deferred messages wait
+	for i := 0; i < 2; i++ {
+		if err := DeliverOutbox(env); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		outboxMessage OutboxMessage
+		attempts      int
+	}{
+		{outboxMessage, 1},
+		{deferred, 0},
+	} {
+		resOutboxMessage, err := test.outboxMessage.Get(env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resOutboxMessage.Attempts != test.attempts || resOutboxMessage.Status != "pending" {
+			t.Errorf("%q: attempts = %d, status = %q, want %d, pending", resOutboxMessage.Title, resOutboxMessage.Attempts, resOutboxMessage.Status, test.attempts)
+		}
+	}
+}
+
+func TestOutboxDeleteFinished(t *testing.T) {
+	env := newTestEnv(t)
+	now := time.Now()
+	messages := []struct {
+		detectionID int
+		status      string
+		updatedAt   time.Time
+		kept        bool
+	}{
+		{1, "sent", now.AddDate(0, 0, -31), false},
+		{2, "failed", now.AddDate(0, 0, -31), false},
+		{3, "sent", now.AddDate(0, 0, -29), true},
+		// Messages still to be sent are kept however old they are, as are their snapshots
+		{4, "pending", now.AddDate(0, 0, -31), true},
+		// as are snapshots still used by a later message, such as an escalation
+		{1, "pending", now, true},
+	}
+	for i, message := range messages {
+		notification := Notification{Title: "ANPR Alert: AB12CDE", DetectionID: message.detectionID, Snapshot: []byte{0xff, 0xd8}}
+		var outboxMessage OutboxMessage
+		if err := outboxMessage.Queue(env, notification, "a@example.com", 0, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := env.DB.Exec("UPDATE outbox SET status = ?, updated_at = ? WHERE id = ?", message.status, message.updatedAt.Format(DateTimeFormat), outboxMessage.ID); err != nil {
+			t.Fatal(err)
+		}
+		if outboxMessage.ID != i+1 {
+			t.Fatalf("message %d added with ID %d", i+1, outboxMessage.ID)
+		}
+	}
+	if _, err := env.DB.Exec("UPDATE snapshots SET created_at = ?", now.AddDate(0, 0, -31).Format(DateTimeFormat)); err != nil {
+		t.Fatal(err)
+	}
+	before := now.AddDate(0, 0, -30)
+
+	var outboxMessage OutboxMessage
+	count, err := outboxMessage.DeleteFinished(env, before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("DeleteFinished() deleted %d messages, want 2", count)
+	}
+	for i, message := range messages {
+		outboxMessage := OutboxMessage{ID: i + 1}
+		_, err := outboxMessage.Get(env)
+		if kept := err == nil; kept != message.kept {
+			t.Errorf("%s message %d kept = %v, want %v", message.status, i+1, kept, message.kept)
+		}
+	}
+
+	var snapshot Snapshot
+	count, err = snapshot.DeleteUnused(env, before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("DeleteUnused() deleted %d snapshots, want 1", count)
+	}
+	for detectionID, want := range map[int]bool{1: true, 2: false, 3: true, 4: true} {
+		snapshot := Snapshot{DetectionID: detectionID}
+		_, err := snapshot.Get(env)
+		if kept := err == nil; kept != want {
+			t.Errorf("snapshot for detection %d kept = %v, want %v", detectionID, kept, want)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// quietPeriodMaxDefer is the longest an alert is deferred by quiet periods before it's sent anyway
+const quietPeriodMaxDefer = 8 * 24 * time.Hour
+
+// QuietPeriod is when a user doesn't want non-critical alerts sent to their email address or a
+// notification channel, either recurring quiet hours or a do not disturb period between two times
+type QuietPeriod struct {
+	ID          int    `json:"id"`
+	UserID      int    `json:"userID" db:"user_id" validate:"required"`
+	ChannelType string `json:"channelType" db:"channel_type" validate:"oneof=all email channel"`
+	ChannelID   int    `json:"channelID" db:"channel_id"`
+	Windows     string `json:"windows"`
+	StartsAt    string `json:"startsAt" db:"starts_at" validate:"omitempty,datetime=2006-01-02 15:04:05"`
+	EndsAt      string `json:"endsAt" db:"ends_at" validate:"omitempty,datetime=2006-01-02 15:04:05"`
+	Action      string `json:"action" validate:"oneof=suppress defer"`
+	CreatedAt   string `json:"createdAt" db:"created_at"`
+	UpdatedAt   string `json:"updatedAt" db:"updated_at"`
+}
+
+// Add quiet period
+func (e *QuietPeriod) Add(env *Env) (int64, error) {
+	// Add to database
+	res, err := env.DB.Exec(
+		"INSERT INTO quiet_periods (user_id, channel_type, channel_id, windows, starts_at, ends_at, action, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, DATETIME('now', 'localtime'), DATETIME('now', 'localtime'))",
+		&e.UserID, &e.ChannelType, &e.ChannelID, &e.Windows, &e.StartsAt, &e.EndsAt, &e.Action,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	e.ID = int(id)
+	return res.RowsAffected()
+}
+
+// Get quiet period by ID provided
+func (e *QuietPeriod) Get(env *Env) (*QuietPeriod, error) {
+	// Get from database
+	var quietPeriods []QuietPeriod
+	err := env.DB.Query(&quietPeriods, "SELECT * FROM quiet_periods WHERE id = ? LIMIT 1", &e.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(quietPeriods) == 0 {
+		return nil, env.DB.ErrRecordNotFound
+	}
+	return &quietPeriods[0], nil
+}
+
+// Find quiet periods by fields provided
+func (e *QuietPeriod) Find(env *Env, operator string, fields []WhereFields, perPage int, pageNumber int) (*[]QuietPeriod, int, error) {
+	resCount := 0
+	// Where
+	whereSQL, values := env.DB.WhereSQL(operator, fields)
+	// Limit
+	limitSQL := env.DB.LimitSQL(perPage, pageNumber)
+	if limitSQL != "" {
+		// Get count from database
+		var quietPeriods []QuietPeriod
+		err := env.DB.Query(&quietPeriods, "SELECT id FROM quiet_periods"+whereSQL, values...)
+		if err != nil {
+			return nil, 0, err
+		}
+		resCount = len(quietPeriods)
+	}
+	// Get from database
+	var quietPeriods []QuietPeriod
+	err := env.DB.Query(&quietPeriods, "SELECT * FROM quiet_periods"+whereSQL+" ORDER BY channel_type, channel_id, id"+limitSQL, values...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resCount == 0 {
+		resCount = len(quietPeriods)
+	}
+	return &quietPeriods, resCount, nil
+}
+
+// Update quiet period
+func (e *QuietPeriod) Update(env *Env) (int64, error) {
+	// Update database
+	res, err := env.DB.Exec(
+		"UPDATE quiet_periods SET channel_type = ?, channel_id = ?, windows = ?, starts_at = ?, ends_at = ?, action = ?, updated_at = DATETIME('now', 'localtime') WHERE id = ?",
+		&e.ChannelType, &e.ChannelID, &e.Windows, &e.StartsAt, &e.EndsAt, &e.Action, &e.ID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Delete quiet period
+func (e *QuietPeriod) Delete(env *Env) (int64, error) {
+	// Delete from database
+	res, err := env.DB.Exec("DELETE FROM quiet_periods WHERE id = ?", &e.ID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Check checks the quiet period has quiet hours or a do not disturb period that can be parsed
+func (e *QuietPeriod) Check() error {
+	if e.Windows == "" && e.StartsAt == "" && e.EndsAt == "" {
+		return fmt.Errorf("quiet hours or a do not disturb period is required")
+	}
+	if e.Windows != "" {
+		if _, err := parseScheduleWindows(e.Windows); err != nil {
+			return err
+		}
+	}
+	if (e.StartsAt == "") != (e.EndsAt == "") {
+		return fmt.Errorf("do not disturb needs both a start and an end")
+	}
+	if e.StartsAt != "" && e.EndsAt <= e.StartsAt {
+		return fmt.Errorf("do not disturb must end after it starts")
+	}
+	return nil
+}
+
+// Covers checks if the quiet period applies to the email address (channel ID 0) or notification
+// channel provided
+func (e *QuietPeriod) Covers(channelID int) bool {
+	switch e.ChannelType {
+	case "all":
+		return true
+	case "email":
+		return channelID == 0
+	}
+	return channelID != 0 && channelID == e.ChannelID
+}
+
+// Active checks if the time provided falls inside the quiet hours or do not disturb period
+func (e *QuietPeriod) Active(t time.Time) bool {
+	if e.StartsAt != "" && e.EndsAt != "" {
+		current := t.Format(DateTimeFormat)
+		if current >= e.StartsAt && current < e.EndsAt {
+			return true
+		}
+	}
+	if e.Windows != "" {
+		quietHours := Schedule{Windows: e.Windows}
+		if active, err := quietHours.Active(t); err == nil && active {
+			return true
+		}
+	}
+	return false
+}
+
+// Until returns when the quiet period next ends after the time provided, jumping from the end of
+// each quiet hours window or do not disturb period to the next one it runs into
+func (e *QuietPeriod) Until(t time.Time) time.Time {
+	var windows []scheduleWindow
+	if e.Windows != "" {
+		windows, _ = parseScheduleWindows(e.Windows)
+	}
+	var startsAt, endsAt time.Time
+	if e.StartsAt != "" && e.EndsAt != "" {
+		startsAt, _ = time.ParseInLocation(DateTimeFormat, e.StartsAt, time.Local)
+		endsAt, _ = time.ParseInLocation(DateTimeFormat, e.EndsAt, time.Local)
+	}
+	until := t
+	for until.Sub(t) < quietPeriodMaxDefer {
+		if !until.Before(startsAt) && until.Before(endsAt) {
+			until = endsAt
+		} else if end, ok := scheduleWindowEnd(windows, until.In(time.Local)); ok {
+			until = end
+		} else {
+			break
+		}
+	}
+	return until
+}
+
+// QuietUntil returns whether the user provided is in a quiet period for their email address (channel
+// ID 0) or the notification channel provided at the time provided. If they are, it returns whether the
+// alert should be deferred and when to, or false if it should be suppressed.
+func QuietUntil(env *Env, userID int, channelID int, t time.Time) (bool, time.Time, error) {
+	var quietPeriod QuietPeriod
+	resQuietPeriods, _, err := quietPeriod.Find(env, "AND", []WhereFields{{"user_id", "=", userID}}, 0, 1)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	quiet := false
+	var deferUntil time.Time
+	for _, resQuietPeriod := range *resQuietPeriods {
+		if !resQuietPeriod.Covers(channelID) || !resQuietPeriod.Active(t) {
+			continue
+		}
+		if resQuietPeriod.Action == "suppress" {
+			// Suppressing takes precedence over deferring
+			return true, time.Time{}, nil
+		}
+		quiet = true
+		if until := resQuietPeriod.Until(t); until.After(deferUntil) {
+			deferUntil = until
+		}
+	}
+	return quiet, deferUntil, nil
+}
+
+// Migrate quiet periods
+func (e *QuietPeriod) Migrate(env *Env) (sql.Result, error) {
+	// Create table if not exists
+	res, err := env.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS quiet_periods (
+		id INTEGER NOT NULL PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		channel_type TEXT NOT NULL DEFAULT 'all',
+		channel_id INTEGER NOT NULL DEFAULT 0,
+		windows TEXT NOT NULL DEFAULT '',
+		starts_at TEXT NOT NULL DEFAULT '',
+		ends_at TEXT NOT NULL DEFAULT '',
+		action TEXT NOT NULL DEFAULT 'suppress',
+		created_at TEXT NOT NULL DEFAULT 0,
+		updated_at TEXT NOT NULL DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS quiet_periods_user_id ON quiet_periods (user_id);
+	`)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuietPeriodUntil(t *testing.T) {
+	at := func(s string) time.Time {
+		t.Helper()
+		parsed, err := time.ParseInLocation(DateTimeFormat, s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	// 2026-01-05 is a Monday
+	tests := []struct {
+		name        string
+		quietPeriod QuietPeriod
+		t           string
+		want        string
+	}{
+		{"outside quiet hours", QuietPeriod{Windows: "daily 22:00-07:00"}, "2026-01-05 12:00:00", "2026-01-05 12:00:00"},
+		{"overnight quiet hours", QuietPeriod{Windows: "daily 22:00-07:00"}, "2026-01-05 23:30:00", "2026-01-06 07:00:00"},
+		{"overnight quiet hours after midnight", QuietPeriod{Windows: "daily 22:00-07:00"}, "2026-01-06 06:59:30", "2026-01-06 07:00:00"},
+		{"quiet hours end is exclusive", QuietPeriod{Windows: "daily 22:00-07:00"}, "2026-01-06 07:00:00", "2026-01-06 07:00:00"},
+		{"day quiet hours", QuietPeriod{Windows: "mon-fri 09:00-17:00"}, "2026-01-05 09:00:00", "2026-01-05 17:00:00"},
+		{"quiet hours on other days", QuietPeriod{Windows: "sat-sun 09:00-17:00"}, "2026-01-05 10:00:00", "2026-01-05 10:00:00"},
+		{"overnight from the previous day only", QuietPeriod{Windows: "fri 22:00-07:00"}, "2026-01-10 06:00:00", "2026-01-10 07:00:00"},
+		{"adjacent windows", QuietPeriod{Windows: "mon-fri 18:00-24:00\ntue-sat 00:00-08:00"}, "2026-01-05 19:00:00", "2026-01-06 08:00:00"},
+		{"overlapping windows", QuietPeriod{Windows: "daily 12:00-14:00\ndaily 13:00-15:30"}, "2026-01-05 12:30:00", "2026-01-05 15:30:00"},
+		{"do not disturb", QuietPeriod{StartsAt: "2026-01-05 10:00:00", EndsAt: "2026-01-07 09:00:00"}, "2026-01-05 12:00:00", "2026-01-07 09:00:00"},
+		{"after do not disturb", QuietPeriod{StartsAt: "2026-01-05 10:00:00", EndsAt: "2026-01-07 09:00:00"}, "2026-01-07 09:00:00", "2026-01-07 09:00:00"},
+		{"do not disturb into quiet hours", QuietPeriod{Windows: "daily 22:00-07:00", StartsAt: "2026-01-05 10:00:00", EndsAt: "2026-01-06 23:00:00"}, "2026-01-05 12:00:00", "2026-01-07 07:00:00"},
+		{"quiet hours into do not disturb", QuietPeriod{Windows: "daily 22:00-07:00", StartsAt: "2026-01-06 06:00:00", EndsAt: "2026-01-06 09:00:00"}, "2026-01-05 23:00:00", "2026-01-06 09:00:00"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.quietPeriod.Until(at(test.t)); !got.Equal(at(test.want)) {
+				t.Errorf("Until(%s) = %s, want %s", test.t, got.Format(DateTimeFormat), test.want)
+			}
+		})
+	}
+
+	t.Run("always quiet", func(t *testing.T) {
+		// Alerts are sent anyway once they've been deferred for the longest time
+		from := at("2026-01-05 12:00:00")
+		until := (&QuietPeriod{Windows: "daily 00:00-24:00"}).Until(from)
+		if deferred := until.Sub(from); deferred < quietPeriodMaxDefer || deferred > quietPeriodMaxDefer+24*time.Hour {
+			t.Errorf("Until deferred for %s, want just over %s", deferred, quietPeriodMaxDefer)
+		}
+	})
+}
@@ -0,0 +1,305 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"path"
+	"time"
+)
+
+// Rule struct
+type Rule struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name" validate:"required"`
+	Enabled bool   `json:"enabled"`
+	// Conditions
+	PlatePattern  string `json:"platePattern" db:"plate_pattern"`
+	Registered    string `json:"registered" validate:"omitempty,oneof=registered unregistered"`
+	WatchlistID   int    `json:"watchlistID" db:"watchlist_id"`
+	Direction     string `json:"direction" validate:"omitempty,oneof=entry exit"`
+	ScheduleID    int    `json:"scheduleID" db:"schedule_id"`
+	MinConfidence int    `json:"minConfidence" db:"min_confidence" validate:"min=0,max=100"`
+	VehicleAttributes
+	CountThreshold int `json:"countThreshold" db:"count_threshold" validate:"min=0"`
+	CountMinutes   int `json:"countMinutes" db:"count_minutes" validate:"min=0"`
+	// Actions
+	Notify             bool   `json:"notify"`
+	Tag                string `json:"tag"`
+	OutputID           int    `json:"outputID" db:"output_id" validate:"min=0"`
+	EscalationPolicyID int    `json:"escalationPolicyID" db:"escalation_policy_id"`
+	CreatedAt          string `json:"createdAt" db:"created_at"`
+	UpdatedAt          string `json:"updatedAt" db:"updated_at"`
+}
+
+// RuleInput struct
+type RuleInput struct {
+	Camera      Camera
+	Detection   Detection
+	NumberPlate *NumberPlate
+	Time        time.Time
+}
+
+// Add rule
+func (e *Rule) Add(env *Env) (int64, error) {
+	// Add to database
+	res, err := env.DB.Exec(
+		"INSERT INTO rules (name, enabled, plate_pattern, registered, watchlist_id, direction, schedule_id, min_confidence, vehicle_type, colour, brand, model, count_threshold, count_minutes, notify, tag, output_id, escalation_policy_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, DATETIME('now', 'localtime'), DATETIME('now', 'localtime'))",
+		&e.Name, &e.Enabled, &e.PlatePattern, &e.Registered, &e.WatchlistID, &e.Direction, &e.ScheduleID, &e.MinConfidence, &e.VehicleType, &e.Colour, &e.Brand, &e.Model, &e.CountThreshold, &e.CountMinutes, &e.Notify, &e.Tag, &e.OutputID, &e.EscalationPolicyID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	e.ID = int(id)
+	return res.RowsAffected()
+}
+
+// Get rule by ID provided
+func (e *Rule) Get(env *Env) (*Rule, error) {
+	// Get from database
+	var rules []Rule
+	err := env.DB.Query(&rules, "SELECT * FROM rules WHERE id = ? LIMIT 1", &e.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, env.DB.ErrRecordNotFound
+	}
+	return &rules[0], nil
+}
+
+// Find rules by fields provided
+func (e *Rule) Find(env *Env, operator string, fields []WhereFields, perPage int, pageNumber int) (*[]Rule, int, error) {
+	resCount := 0
+	// Where
+	whereSQL, values := env.DB.WhereSQL(operator, fields)
+	// Limit
+	limitSQL := env.DB.LimitSQL(perPage, pageNumber)
+	if limitSQL != "" {
+		// Get count from database
+		var rules []Rule
+		err := env.DB.Query(&rules, "SELECT id FROM rules"+whereSQL, values...)
+		if err != nil {
+			return nil, 0, err
+		}
+		resCount = len(rules)
+	}
+	// Get from database
+	var rules []Rule
+	err := env.DB.Query(&rules, "SELECT * FROM rules"+whereSQL+" ORDER BY name"+limitSQL, values...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resCount == 0 {
+		resCount = len(rules)
+	}
+	return &rules, resCount, nil
+}
+
+// Update rule
+func (e *Rule) Update(env *Env) (int64, error) {
+	// Update database
+	res, err := env.DB.Exec(
+		"UPDATE rules SET name = ?, enabled = ?, plate_pattern = ?, registered = ?, watchlist_id = ?, direction = ?, schedule_id = ?, min_confidence = ?, vehicle_type = ?, colour = ?, brand = ?, model = ?, count_threshold = ?, count_minutes = ?, notify = ?, tag = ?, output_id = ?, escalation_policy_id = ?, updated_at = DATETIME('now', 'localtime') WHERE id = ?",
+		&e.Name, &e.Enabled, &e.PlatePattern, &e.Registered, &e.WatchlistID, &e.Direction, &e.ScheduleID, &e.MinConfidence, &e.VehicleType, &e.Colour, &e.Brand, &e.Model, &e.CountThreshold, &e.CountMinutes, &e.Notify, &e.Tag, &e.OutputID, &e.EscalationPolicyID, &e.ID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Delete rule
+func (e *Rule) Delete(env *Env) (int64, error) {
+	// Delete from database
+	res, err := env.DB.Exec("DELETE FROM rules WHERE id = ?", &e.ID)
+	if err != nil {
+		return 0, err
+	}
+	// Delete rule hits
+	_, err = env.DB.Exec("DELETE FROM rule_hits WHERE rule_id = ?", &e.ID)
+	if err != nil {
+		return 0, err
+	}
+	// Delete camera scopes
+	var cameraScope CameraScope
+	if err := cameraScope.Delete(env, "rule", e.ID); err != nil {
+		return 0, err
+	}
+	// Delete notification targets
+	var notificationTarget NotificationTarget
+	if err := notificationTarget.Delete(env, "rule", e.ID); err != nil {
+		return 0, err
+	}
+	// Delete subscriptions
+	var subscription Subscription
+	if err := subscription.DeleteBy(env, []WhereFields{{"subject_type", "=", "rule"}, {"subject_id", "=", e.ID}}); err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Check checks the rule's plate pattern and count condition are valid
+func (e *Rule) Check() error {
+	if _, err := path.Match(NormalisePlate(e.PlatePattern), ""); err != nil {
+		return fmt.Errorf("invalid plate pattern %q", e.PlatePattern)
+	}
+	if (e.CountThreshold > 0) != (e.CountMinutes > 0) {
+		return fmt.Errorf("both the count and the minutes are required for a count condition")
+	}
+	return nil
+}
+
+// Match checks the input against each of the rule's conditions, returning whether they all
+// matched and an explanation of each condition that did
+func (e *Rule) Match(env *Env, input RuleInput) (bool, []string, error) {
+	var explanation []string
+	plate := input.Detection.Plate
+
+	// Plate pattern
+	if e.PlatePattern != "" {
+		pattern := NormalisePlate(e.PlatePattern)
+		if ok, _ := path.Match(pattern, plate); !ok {
+			return false, nil, nil
+		}
+		explanation = append(explanation, fmt.Sprintf("Number plate %s matches %s", plate, pattern))
+	}
+
+	// Registered or unregistered
+	switch e.Registered {
+	case "registered":
+		if input.NumberPlate == nil {
+			return false, nil, nil
+		}
+		explanation = append(explanation, fmt.Sprintf("Number plate %s is registered", plate))
+	case "unregistered":
+		if input.NumberPlate != nil {
+			return false, nil, nil
+		}
+		explanation = append(explanation, fmt.Sprintf("Number plate %s is not registered", plate))
+	}
+
+	// Watchlist
+	if e.WatchlistID != 0 {
+		if input.NumberPlate == nil || input.NumberPlate.WatchlistID != e.WatchlistID {
+			return false, nil, nil
+		}
+		watchlistName := fmt.Sprintf("id %d", e.WatchlistID)
+		watchlist := Watchlist{ID: e.WatchlistID}
+		if resWatchlist, err := watchlist.Get(env); err == nil {
+			watchlistName = resWatchlist.Name
+		}
+		explanation = append(explanation, fmt.Sprintf("Number plate %s is on watchlist %s", plate, watchlistName))
+	}
+
+	// Camera or camera group
+	inScope, err := CameraInScope(env, "rule", e.ID, input.Camera)
+	if err != nil || !inScope {
+		return false, nil, err
+	}
+	var cameraScope CameraScope
+	cameraScopes, err := cameraScope.Find(env, "rule", e.ID)
+	if err != nil {
+		return false, nil, err
+	}
+	if len(cameraScopes) > 0 {
+		explanation = append(explanation, fmt.Sprintf("Camera %s is one of the rule's cameras", input.Camera.DisplayName()))
+	}
+
+	// Direction
+	if e.Direction != "" {
+		if e.Direction != input.Detection.Direction {
+			return false, nil, nil
+		}
+		explanation = append(explanation, fmt.Sprintf("Vehicle is making an %s", e.Direction))
+	}
+
+	// Time
+	if e.ScheduleID != 0 {
+		active, err := ScheduleActive(env, e.ScheduleID, input.Time)
+		if err != nil || !active {
+			return false, nil, err
+		}
+		explanation = append(explanation, fmt.Sprintf("Read at %s is inside the rule's schedule", input.Time.Format(DateTimeFormat)))
+	}
+
+	// Confidence
+	if e.MinConfidence > 0 {
+		if input.Detection.Confidence < e.MinConfidence {
+			return false, nil, nil
+		}
+		explanation = append(explanation, fmt.Sprintf("Confidence %d%% is at least %d%%", input.Detection.Confidence, e.MinConfidence))
+	} else if input.Detection.LowConfidence {
+		// Rules without their own minimum confidence use the camera or watchlist threshold
+		return false, nil, nil
+	}
+
+	// Vehicle attributes
+	if !e.VehicleAttributes.IsEmpty() {
+		if !e.VehicleAttributes.Matches(input.Detection.VehicleAttributes) {
+			return false, nil, nil
+		}
+		explanation = append(explanation, fmt.Sprintf("Vehicle %s matches %s", input.Detection.VehicleAttributes, e.VehicleAttributes))
+	}
+
+	// Count of reads in the last number of minutes, including this one
+	if e.CountThreshold > 0 && e.CountMinutes > 0 {
+		since := input.Time.Add(-time.Duration(e.CountMinutes) * time.Minute)
+		var detection Detection
+		count, err := detection.CountByPlate(env, plate, since)
+		if err != nil {
+			return false, nil, err
+		}
+		count++
+		if count < e.CountThreshold {
+			return false, nil, nil
+		}
+		explanation = append(explanation, fmt.Sprintf("Number plate %s read %d times in the last %d minutes", plate, count, e.CountMinutes))
+	}
+
+	if len(explanation) == 0 {
+		explanation = append(explanation, "Rule has no conditions so matches every read")
+	}
+	return true, explanation, nil
+}
+
+// Migrate rules
+func (e *Rule) Migrate(env *Env) (sql.Result, error) {
+	// Create table if not exists
+	res, err := env.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS rules (
+		id INTEGER NOT NULL PRIMARY KEY,
+		name TEXT NOT NULL,
+		enabled INTEGER NOT NULL DEFAULT 1,
+		plate_pattern TEXT NOT NULL DEFAULT '',
+		registered TEXT NOT NULL DEFAULT '',
+		watchlist_id INTEGER NOT NULL DEFAULT 0,
+		direction TEXT NOT NULL DEFAULT '',
+		schedule_id INTEGER NOT NULL DEFAULT 0,
+		min_confidence INTEGER NOT NULL DEFAULT 0,
+		vehicle_type TEXT NOT NULL DEFAULT '',
+		colour TEXT NOT NULL DEFAULT '',
+		brand TEXT NOT NULL DEFAULT '',
+		model TEXT NOT NULL DEFAULT '',
+		count_threshold INTEGER NOT NULL DEFAULT 0,
+		count_minutes INTEGER NOT NULL DEFAULT 0,
+		notify INTEGER NOT NULL DEFAULT 0,
+		tag TEXT NOT NULL DEFAULT '',
+		output_id INTEGER NOT NULL DEFAULT 0,
+		escalation_policy_id INTEGER NOT NULL DEFAULT 0,
+		created_at TEXT NOT NULL DEFAULT 0,
+		updated_at TEXT NOT NULL DEFAULT 0
+	);
+	`)
+	if err != nil {
+		return nil, err
+	}
+	// Add columns missing from older databases
+	if err := env.DB.AddColumn("rules", "escalation_policy_id", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRuleMatch(t *testing.T) {
+	env := newTestEnv(t)
+	watchlist := Watchlist{Name: "Visitors"}
+	if _, err := watchlist.Add(env); err != nil {
+		t.Fatal(err)
+	}
+	schedule := Schedule{Name: "Office Hours", Timezone: "UTC", Windows: "mon-fri 08:00-18:00", HolidayMode: "inactive"}
+	if _, err := schedule.Add(env); err != nil {
+		t.Fatal(err)
+	}
+	// Rule 100 is limited to camera 2 and rule 101 to camera group 5
+	var cameraScope CameraScope
+	if err := cameraScope.Set(env, "rule", 100, []CameraScope{{CameraID: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cameraScope.Set(env, "rule", 101, []CameraScope{{CameraGroupID: 5}}); err != nil {
+		t.Fatal(err)
+	}
+	// 2026-01-05 is a Monday
+	now := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+	for _, ago := range []time.Duration{5 * time.Minute, 30 * time.Minute} {
+		detection := Detection{CameraID: 1, Plate: "CNT1", EventTime: now.Add(-ago).In(time.Local).Format(DateTimeFormat)}
+		if _, err := detection.Add(env); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	camera := Camera{ID: 1, Name: "Gate"}
+	registered := &NumberPlate{ID: 1, Plate: "AB12CDE", WatchlistID: watchlist.ID}
+	read := Detection{Plate: "AB12CDE", Confidence: 90, Direction: "entry", VehicleAttributes: VehicleAttributes{Colour: "White", Brand: "Ford"}}
+	input := func(change func(*RuleInput)) RuleInput {
+		input := RuleInput{Camera: camera, Detection: read, NumberPlate: registered, Time: now}
+		if change != nil {
+			change(&input)
+		}
+		return input
+	}
+	tests := []struct {
+		name        string
+		rule        Rule
+		input       RuleInput
+		match       bool
+		explanation []string
+	}{
+		{"no conditions", Rule{}, input(nil), true, []string{"Rule has no conditions so matches every read"}},
+		{"plate pattern", Rule{PlatePattern: "ab 12*"}, input(nil), true, []string{"Number plate AB12CDE matches AB12*"}},
+		{"plate pattern mismatch", Rule{PlatePattern: "XY*"}, input(nil), false, nil},
+		{"registered", Rule{Registered: "registered"}, input(nil), true, []string{"Number plate AB12CDE is registered"}},
+		{"registered mismatch", Rule{Registered: "registered"}, input(func(i *RuleInput) { i.NumberPlate = nil }), false, nil},
+		{"unregistered", Rule{Registered: "unregistered"}, input(func(i *RuleInput) { i.NumberPlate = nil }), true, []string{"Number plate AB12CDE is not registered"}},
+		{"unregistered mismatch", Rule{Registered: "unregistered"}, input(nil), false, nil},
+		{"watchlist", Rule{WatchlistID: watchlist.ID}, input(nil), true, []string{"Number plate AB12CDE is on watchlist Visitors"}},
+		{"watchlist mismatch", Rule{WatchlistID: watchlist.ID + 1}, input(nil), false, nil},
+		{"watchlist unregistered", Rule{WatchlistID: watchlist.ID}, input(func(i *RuleInput) { i.NumberPlate = nil }), false, nil},
+		{"camera", Rule{ID: 100}, input(func(i *RuleInput) { i.Camera.ID = 2 }), true, []string{"Camera Gate is one of the rule's cameras"}},
+		{"camera mismatch", Rule{ID: 100}, input(nil), false, nil},
+		{"camera group", Rule{ID: 101}, input(func(i *RuleInput) { i.Camera.CameraGroupID = 5 }), true, []string{"Camera Gate is one of the rule's cameras"}},
+		{"camera group mismatch", Rule{ID: 101}, input(nil), false, nil},
+		{"direction", Rule{Direction: "entry"}, input(nil), true, []string{"Vehicle is making an entry"}},
+		{"direction mismatch", Rule{Direction: "exit"}, input(nil), false, nil},
+		{"schedule", Rule{ScheduleID: schedule.ID}, input(nil), true, []string{"Read at " + now.Format(DateTimeFormat) + " is inside the rule's schedule"}},
+		{"schedule mismatch", Rule{ScheduleID: schedule.ID}, input(func(i *RuleInput) { i.Time = now.Add(8 * time.Hour) }), false, nil},
+		{"min confidence", Rule{MinConfidence: 80}, input(nil), true, []string{"Confidence 90% is at least 80%"}},
+		{"min confidence mismatch", Rule{MinConfidence: 95}, input(nil), false, nil},
+		{"low confidence", Rule{}, input(func(i *RuleInput) { i.Detection.LowConfidence = true }), false, nil},
+		{"low confidence above the rule's minimum", Rule{MinConfidence: 80}, input(func(i *RuleInput) { i.Detection.LowConfidence = true }), true, []string{"Confidence 90% is at least 80%"}},
+		{"vehicle attributes", Rule{VehicleAttributes: VehicleAttributes{Colour: "white"}}, input(nil), true, []string{"Vehicle White Ford matches white"}},
+		{"vehicle attributes mismatch", Rule{VehicleAttributes: VehicleAttributes{Colour: "blue"}}, input(nil), false, nil},
+		{"count", Rule{CountThreshold: 3, CountMinutes: 60}, input(func(i *RuleInput) { i.Detection.Plate = "CNT1" }), true, []string{"Number plate CNT1 read 3 times in the last 60 minutes"}},
+		{"count under threshold", Rule{CountThreshold: 4, CountMinutes: 60}, input(func(i *RuleInput) { i.Detection.Plate = "CNT1" }), false, nil},
+		{"count outside minutes", Rule{CountThreshold: 3, CountMinutes: 10}, input(func(i *RuleInput) { i.Detection.Plate = "CNT1" }), false, nil},
+		{"all conditions", Rule{PlatePattern: "AB*", Registered: "registered", Direction: "entry", MinConfidence: 80}, input(nil), true, []string{
+			"Number plate AB12CDE matches AB*",
+			"Number plate AB12CDE is registered",
+			"Vehicle is making an entry",
+			"Confidence 90% is at least 80%",
+		}},
+		{"one condition mismatch", Rule{PlatePattern: "AB*", Registered: "registered", Direction: "exit"}, input(nil), false, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			match, explanation, err := test.rule.Match(env, test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if match != test.match {
+				t.Errorf("Match() = %v, want %v", match, test.match)
+			}
+			if !reflect.DeepEqual(explanation, test.explanation) {
+				t.Errorf("explanation = %q, want %q", explanation, test.explanation)
+			}
+		})
+	}
+}
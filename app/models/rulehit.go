@@ -0,0 +1,79 @@
+package models
+
+import (
+	"database/sql"
+)
+
+// RuleHit struct
+type RuleHit struct {
+	ID          int    `json:"id"`
+	RuleID      int    `json:"ruleID" db:"rule_id"`
+	DetectionID int    `json:"detectionID" db:"detection_id"`
+	Explanation string `json:"explanation"`
+	CreatedAt   string `json:"createdAt" db:"created_at"`
+}
+
+// Add rule hit
+func (e *RuleHit) Add(env *Env) (int64, error) {
+	// Add to database
+	res, err := env.DB.Exec(
+		"INSERT INTO rule_hits (rule_id, detection_id, explanation, created_at) VALUES (?, ?, ?, DATETIME('now', 'localtime'))",
+		&e.RuleID, &e.DetectionID, &e.Explanation,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	e.ID = int(id)
+	return res.RowsAffected()
+}
+
+// Find rule hits by fields provided
+func (e *RuleHit) Find(env *Env, operator string, fields []WhereFields, perPage int, pageNumber int) (*[]RuleHit, int, error) {
+	resCount := 0
+	// Where
+	whereSQL, values := env.DB.WhereSQL(operator, fields)
+	// Limit
+	limitSQL := env.DB.LimitSQL(perPage, pageNumber)
+	if limitSQL != "" {
+		// Get count from database
+		var ruleHits []RuleHit
+		err := env.DB.Query(&ruleHits, "SELECT id FROM rule_hits"+whereSQL, values...)
+		if err != nil {
+			return nil, 0, err
+		}
+		resCount = len(ruleHits)
+	}
+	// Get from database
+	var ruleHits []RuleHit
+	err := env.DB.Query(&ruleHits, "SELECT * FROM rule_hits"+whereSQL+" ORDER BY id DESC"+limitSQL, values...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resCount == 0 {
+		resCount = len(ruleHits)
+	}
+	return &ruleHits, resCount, nil
+}
+
+// Migrate rule hits
+func (e *RuleHit) Migrate(env *Env) (sql.Result, error) {
+	// Create table if not exists
+	res, err := env.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS rule_hits (
+		id INTEGER NOT NULL PRIMARY KEY,
+		rule_id INTEGER NOT NULL,
+		detection_id INTEGER NOT NULL,
+		explanation TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS rule_hits_rule_id ON rule_hits (rule_id);
+	`)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
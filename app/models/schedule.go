@@ -0,0 +1,328 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
+)
+
+// Schedule struct
+type Schedule struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name" validate:"required"`
+	Timezone    string `json:"timezone"`
+	Windows     string `json:"windows" validate:"required"`
+	Holidays    string `json:"holidays"`
+	HolidayMode string `json:"holidayMode" db:"holiday_mode" validate:"oneof=inactive active"`
+	CreatedAt   string `json:"createdAt" db:"created_at"`
+	UpdatedAt   string `json:"updatedAt" db:"updated_at"`
+}
+
+// scheduleWindow is a single parsed line of a schedule's windows
+type scheduleWindow struct {
+	days  [7]bool
+	start int
+	end   int
+}
+
+var scheduleDays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Add schedule
+func (e *Schedule) Add(env *Env) (int64, error) {
+	// Add to database
+	res, err := env.DB.Exec(
+		"INSERT INTO schedules (name, timezone, windows, holidays, holiday_mode, created_at, updated_at) VALUES (?, ?, ?, ?, ?, DATETIME('now', 'localtime'), DATETIME('now', 'localtime'))",
+		&e.Name, &e.Timezone, &e.Windows, &e.Holidays, &e.HolidayMode,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	e.ID = int(id)
+	return res.RowsAffected()
+}
+
+// Get schedule by ID provided
+func (e *Schedule) Get(env *Env) (*Schedule, error) {
+	// Get from database
+	var schedules []Schedule
+	err := env.DB.Query(&schedules, "SELECT * FROM schedules WHERE id = ? LIMIT 1", &e.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(schedules) == 0 {
+		return nil, env.DB.ErrRecordNotFound
+	}
+	return &schedules[0], nil
+}
+
+// Find schedules by fields provided
+func (e *Schedule) Find(env *Env, operator string, fields []WhereFields, perPage int, pageNumber int) (*[]Schedule, int, error) {
+	resCount := 0
+	// Where
+	whereSQL, values := env.DB.WhereSQL(operator, fields)
+	// Limit
+	limitSQL := env.DB.LimitSQL(perPage, pageNumber)
+	if limitSQL != "" {
+		// Get count from database
+		var schedules []Schedule
+		err := env.DB.Query(&schedules, "SELECT id FROM schedules"+whereSQL, values...)
+		if err != nil {
+			return nil, 0, err
+		}
+		resCount = len(schedules)
+	}
+	// Get from database
+	var schedules []Schedule
+	err := env.DB.Query(&schedules, "SELECT * FROM schedules"+whereSQL+" ORDER BY name"+limitSQL, values...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resCount == 0 {
+		resCount = len(schedules)
+	}
+	return &schedules, resCount, nil
+}
+
+// Update schedule
+func (e *Schedule) Update(env *Env) (int64, error) {
+	// Update database
+	res, err := env.DB.Exec(
+		"UPDATE schedules SET name = ?, timezone = ?, windows = ?, holidays = ?, holiday_mode = ?, updated_at = DATETIME('now', 'localtime') WHERE id = ?",
+		&e.Name, &e.Timezone, &e.Windows, &e.Holidays, &e.HolidayMode, &e.ID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Delete schedule
+func (e *Schedule) Delete(env *Env) (int64, error) {
+	// Delete from database
+	res, err := env.DB.Exec("DELETE FROM schedules WHERE id = ?", &e.ID)
+	if err != nil {
+		return 0, err
+	}
+	// Detach schedule from anything using it
+	for _, table := range []string{"number_plates", "watchlists", "cameras", "vehicle_watches"} {
+		_, err = env.DB.Exec("UPDATE "+table+" SET schedule_id = 0 WHERE schedule_id = ?", &e.ID)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return res.RowsAffected()
+}
+
+// Check checks the schedule's timezone, windows and holidays can be parsed
+func (e *Schedule) Check() error {
+	if _, err := e.location(); err != nil {
+		return fmt.Errorf("invalid timezone %q", e.Timezone)
+	}
+	if _, err := parseScheduleWindows(e.Windows); err != nil {
+		return err
+	}
+	if _, err := parseScheduleHolidays(e.Holidays); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Active checks if the time provided falls inside the schedule
+func (e *Schedule) Active(t time.Time) (bool, error) {
+	loc, err := e.location()
+	if err != nil {
+		return false, err
+	}
+	windows, err := parseScheduleWindows(e.Windows)
+	if err != nil {
+		return false, err
+	}
+	holidays, err := parseScheduleHolidays(e.Holidays)
+	if err != nil {
+		return false, err
+	}
+	t = t.In(loc)
+	// Holidays override the normal windows for the whole day
+	if holidays[t.Format("2006-01-02")] {
+		return e.HolidayMode == "active", nil
+	}
+	_, active := scheduleWindowEnd(windows, t)
+	return active, nil
+}
+
+// scheduleWindowEnd returns when the latest ending of the windows the time provided falls in ends, or
+// false if it doesn't fall in any of them
+func scheduleWindowEnd(windows []scheduleWindow, t time.Time) (time.Time, bool) {
+	day := int(t.Weekday())
+	previousDay := (day + 6) % 7
+	minute := t.Hour()*60 + t.Minute()
+	var end time.Time
+	active := false
+	for _, w := range windows {
+		// Days after the time's day the window ends on
+		endDay := 0
+		if w.start < w.end {
+			if !w.days[day] || minute < w.start || minute >= w.end {
+				continue
+			}
+		} else if w.days[day] && minute >= w.start {
+			// Window runs past midnight into the following day
+			endDay = 1
+		} else if !w.days[previousDay] || minute >= w.end {
+			continue
+		}
+		windowEnd := time.Date(t.Year(), t.Month(), t.Day()+endDay, 0, w.end, 0, 0, t.Location())
+		if windowEnd.After(end) {
+			end = windowEnd
+		}
+		active = true
+	}
+	return end, active
+}
+
+// location returns the schedule's timezone, defaulting to local time
+func (e *Schedule) location() (*time.Location, error) {
+	if e.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(e.Timezone)
+}
+
+// parseScheduleWindows parses one window per line in the format "mon-fri 08:00-18:00"
+func parseScheduleWindows(s string) ([]scheduleWindow, error) {
+	var windows []scheduleWindow
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.ToLower(strings.TrimSpace(line))
+		if line == "" {
+			continue
+		}
+		parts := strings.Fields(line)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid schedule window %q", line)
+		}
+		w := scheduleWindow{}
+		// Parse days
+		for _, dayRange := range strings.Split(parts[0], ",") {
+			if dayRange == "daily" || dayRange == "*" {
+				w.days = [7]bool{true, true, true, true, true, true, true}
+				continue
+			}
+			from, to, found := strings.Cut(dayRange, "-")
+			if !found {
+				to = from
+			}
+			fromDay, toDay := scheduleDay(from), scheduleDay(to)
+			if fromDay < 0 || toDay < 0 {
+				return nil, fmt.Errorf("invalid schedule days %q", dayRange)
+			}
+			for d := fromDay; ; d = (d + 1) % 7 {
+				w.days[d] = true
+				if d == toDay {
+					break
+				}
+			}
+		}
+		// Parse times
+		from, to, found := strings.Cut(parts[1], "-")
+		if !found {
+			return nil, fmt.Errorf("invalid schedule times %q", parts[1])
+		}
+		var err error
+		if w.start, err = scheduleMinute(from); err != nil {
+			return nil, err
+		}
+		if w.end, err = scheduleMinute(to); err != nil {
+			return nil, err
+		}
+		if w.start == w.end {
+			return nil, fmt.Errorf("invalid schedule times %q", parts[1])
+		}
+		windows = append(windows, w)
+	}
+	if len(windows) == 0 {
+		return nil, fmt.Errorf("schedule has no windows")
+	}
+	return windows, nil
+}
+
+// parseScheduleHolidays parses one date per line in the format "2006-01-02"
+func parseScheduleHolidays(s string) (map[string]bool, error) {
+	holidays := map[string]bool{}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", line); err != nil {
+			return nil, fmt.Errorf("invalid holiday date %q", line)
+		}
+		holidays[line] = true
+	}
+	return holidays, nil
+}
+
+// scheduleDay returns the weekday number of a day name or -1 if invalid
+func scheduleDay(s string) int {
+	for i, day := range scheduleDays {
+		if strings.HasPrefix(s, day) {
+			return i
+		}
+	}
+	return -1
+}
+
+// scheduleMinute returns the minute of the day of a time in the format "15:04", allowing "24:00"
+func scheduleMinute(s string) (int, error) {
+	hours, minutes, found := strings.Cut(s, ":")
+	h, err := strconv.Atoi(hours)
+	if err != nil || !found {
+		return 0, fmt.Errorf("invalid schedule time %q", s)
+	}
+	m, err := strconv.Atoi(minutes)
+	if err != nil || h < 0 || m < 0 || m > 59 || h*60+m > 24*60 {
+		return 0, fmt.Errorf("invalid schedule time %q", s)
+	}
+	return h*60 + m, nil
+}
+
+// ScheduleActive checks if the schedule with the ID provided is active at the time provided,
+// treating no schedule as always active
+func ScheduleActive(env *Env, scheduleID int, t time.Time) (bool, error) {
+	if scheduleID == 0 {
+		return true, nil
+	}
+	schedule := Schedule{ID: scheduleID}
+	res, err := schedule.Get(env)
+	if err != nil {
+		return false, err
+	}
+	return res.Active(t)
+}
+
+// Migrate schedules
+func (e *Schedule) Migrate(env *Env) (sql.Result, error) {
+	// Create table if not exists
+	res, err := env.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS schedules (
+		id INTEGER NOT NULL PRIMARY KEY,
+		name TEXT NOT NULL,
+		timezone TEXT NOT NULL DEFAULT '',
+		windows TEXT NOT NULL DEFAULT '',
+		holidays TEXT NOT NULL DEFAULT '',
+		holiday_mode TEXT NOT NULL DEFAULT 'inactive',
+		created_at TEXT NOT NULL DEFAULT 0,
+		updated_at TEXT NOT NULL DEFAULT 0
+	);
+	`)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
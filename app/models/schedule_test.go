@@ -0,0 +1,81 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleActive(t *testing.T) {
+	at := func(s string) time.Time {
+		t.Helper()
+		parsed, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	// 2026-01-05 is a Monday, when London is on UTC
+	weekdays := Schedule{Timezone: "Europe/London", Windows: "mon-fri 08:00-18:00"}
+	overnight := Schedule{Timezone: "Europe/London", Windows: "mon-fri 22:00-06:00"}
+	tests := []struct {
+		name     string
+		schedule Schedule
+		t        string
+		want     bool
+	}{
+		{"start of window", weekdays, "2026-01-05T08:00:00Z", true},
+		{"end of window is exclusive", weekdays, "2026-01-05T18:00:00Z", false},
+		{"last minute of window", weekdays, "2026-01-05T17:59:59Z", true},
+		{"before window", weekdays, "2026-01-05T07:59:00Z", false},
+		{"weekend", weekdays, "2026-01-10T12:00:00Z", false},
+		{"overnight before midnight", overnight, "2026-01-05T23:00:00Z", true},
+		{"overnight after midnight", overnight, "2026-01-06T05:59:00Z", true},
+		{"overnight end", overnight, "2026-01-06T06:00:00Z", false},
+		{"overnight daytime", overnight, "2026-01-06T12:00:00Z", false},
+		{"overnight from friday into saturday", overnight, "2026-01-10T03:00:00Z", true},
+		{"overnight not from sunday into monday", overnight, "2026-01-05T03:00:00Z", false},
+		{"overnight not starting on saturday", overnight, "2026-01-10T23:00:00Z", false},
+		{"day range wrapping the week", Schedule{Windows: "fri-mon 09:00-17:00", Timezone: "UTC"}, "2026-01-11T12:00:00Z", true},
+		{"day range wrapping the week excludes midweek", Schedule{Windows: "fri-mon 09:00-17:00", Timezone: "UTC"}, "2026-01-07T12:00:00Z", false},
+		{"daily all day", Schedule{Windows: "daily 00:00-24:00", Timezone: "UTC"}, "2026-01-07T23:59:59Z", true},
+		{"multiple windows", Schedule{Windows: "mon 08:00-09:00\nmon 17:00-18:00", Timezone: "UTC"}, "2026-01-05T17:30:00Z", true},
+		{"timezone", Schedule{Windows: "mon-fri 08:00-18:00", Timezone: "America/New_York"}, "2026-01-05T14:00:00Z", true},
+		{"timezone outside window", Schedule{Windows: "mon-fri 08:00-18:00", Timezone: "America/New_York"}, "2026-01-05T12:00:00Z", false},
+		{"timezone daylight saving", Schedule{Windows: "mon-fri 08:00-18:00", Timezone: "Europe/London"}, "2026-07-06T07:30:00Z", true},
+		{"holiday inactive", Schedule{Windows: "mon-fri 08:00-18:00", Timezone: "UTC", Holidays: "2026-01-05", HolidayMode: "inactive"}, "2026-01-05T12:00:00Z", false},
+		{"holiday active", Schedule{Windows: "mon-fri 08:00-18:00", Timezone: "UTC", Holidays: "2026-01-10", HolidayMode: "active"}, "2026-01-10T03:00:00Z", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.schedule.Active(at(test.t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("Active(%s) = %v, want %v", test.t, got, test.want)
+			}
+		})
+	}
+}
+
+func TestScheduleActiveInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+	}{
+		{"no windows", Schedule{}},
+		{"missing times", Schedule{Windows: "mon-fri"}},
+		{"invalid day", Schedule{Windows: "someday 08:00-18:00"}},
+		{"invalid time", Schedule{Windows: "mon 08:00-25:00"}},
+		{"same start and end", Schedule{Windows: "mon 08:00-08:00"}},
+		{"invalid timezone", Schedule{Windows: "mon 08:00-18:00", Timezone: "Nowhere/Special"}},
+		{"invalid holiday", Schedule{Windows: "mon 08:00-18:00", Holidays: "5th January"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := test.schedule.Active(time.Now()); err == nil {
+				t.Error("Active didn't return an error")
+			}
+		})
+	}
+}
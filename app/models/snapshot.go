@@ -0,0 +1,70 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Snapshot is the picture of a detection sent with its alerts, stored once for every outbox message
+// queued for the detection and loaded when they're delivered, including escalations queued later
+type Snapshot struct {
+	DetectionID int    `json:"detectionID" db:"detection_id"`
+	Picture     []byte `json:"picture"`
+	CreatedAt   string `json:"createdAt" db:"created_at"`
+}
+
+// Add snapshot unless the detection already has one
+func (e *Snapshot) Add(env *Env) (int64, error) {
+	// Add to database
+	res, err := env.DB.Exec(
+		"INSERT OR IGNORE INTO snapshots (detection_id, picture, created_at) VALUES (?, ?, DATETIME('now', 'localtime'))",
+		&e.DetectionID, &e.Picture,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Get snapshot by detection ID provided
+func (e *Snapshot) Get(env *Env) (*Snapshot, error) {
+	// Get from database
+	var snapshots []Snapshot
+	err := env.DB.Query(&snapshots, "SELECT * FROM snapshots WHERE detection_id = ? LIMIT 1", &e.DetectionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, env.DB.ErrRecordNotFound
+	}
+	return &snapshots[0], nil
+}
+
+// DeleteUnused deletes snapshots stored before the time provided that no outbox messages are for
+func (e *Snapshot) DeleteUnused(env *Env, before time.Time) (int64, error) {
+	// Delete from database
+	res, err := env.DB.Exec(
+		"DELETE FROM snapshots WHERE created_at < ? AND detection_id NOT IN (SELECT detection_id FROM outbox)",
+		before.Format(DateTimeFormat),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Migrate snapshots
+func (e *Snapshot) Migrate(env *Env) (sql.Result, error) {
+	// Create table if not exists
+	res, err := env.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS snapshots (
+		detection_id INTEGER NOT NULL PRIMARY KEY,
+		picture BLOB NOT NULL,
+		created_at TEXT NOT NULL DEFAULT 0
+	);
+	`)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
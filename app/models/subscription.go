@@ -0,0 +1,173 @@
+package models
+
+import (
+	"database/sql"
+)
+
+// SubscriptionFrequencies are the display names of how often subscriptions are sent, where digests
+// are emailed at the subscription's digest hour and weekly digests are sent on Mondays
+var SubscriptionFrequencies = map[string]string{"": "Instantly", "daily": "Daily digest", "weekly": "Weekly digest"}
+
+// Subscription struct
+type Subscription struct {
+	ID           int    `json:"id"`
+	UserID       int    `json:"userID" db:"user_id" validate:"required"`
+	SubjectType  string `json:"subjectType" db:"subject_type" validate:"oneof=all watchlist camera rule"`
+	SubjectID    int    `json:"subjectID" db:"subject_id"`
+	Email        bool   `json:"email"`
+	Frequency    string `json:"frequency" validate:"omitempty,oneof=daily weekly"`
+	DigestHour   int    `json:"digestHour" db:"digest_hour" validate:"min=0,max=23"`
+	LastDigestAt string `json:"lastDigestAt" db:"last_digest_at"`
+	CreatedAt    string `json:"createdAt" db:"created_at"`
+	UpdatedAt    string `json:"updatedAt" db:"updated_at"`
+}
+
+// Add subscription
+func (e *Subscription) Add(env *Env) (int64, error) {
+	// Add to database
+	res, err := env.DB.Exec(
+		"INSERT INTO subscriptions (user_id, subject_type, subject_id, email, frequency, digest_hour, last_digest_at, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, DATETIME('now', 'localtime'), DATETIME('now', 'localtime'))",
+		&e.UserID, &e.SubjectType, &e.SubjectID, &e.Email, &e.Frequency, &e.DigestHour, &e.LastDigestAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	e.ID = int(id)
+	return res.RowsAffected()
+}
+
+// Get subscription by ID provided
+func (e *Subscription) Get(env *Env) (*Subscription, error) {
+	// Get from database
+	var subscriptions []Subscription
+	err := env.DB.Query(&subscriptions, "SELECT * FROM subscriptions WHERE id = ? LIMIT 1", &e.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(subscriptions) == 0 {
+		return nil, env.DB.ErrRecordNotFound
+	}
+	return &subscriptions[0], nil
+}
+
+// Find subscriptions by fields provided
+func (e *Subscription) Find(env *Env, operator string, fields []WhereFields, perPage int, pageNumber int) (*[]Subscription, int, error) {
+	resCount := 0
+	// Where
+	whereSQL, values := env.DB.WhereSQL(operator, fields)
+	// Limit
+	limitSQL := env.DB.LimitSQL(perPage, pageNumber)
+	if limitSQL != "" {
+		// Get count from database
+		var subscriptions []Subscription
+		err := env.DB.Query(&subscriptions, "SELECT id FROM subscriptions"+whereSQL, values...)
+		if err != nil {
+			return nil, 0, err
+		}
+		resCount = len(subscriptions)
+	}
+	// Get from database
+	var subscriptions []Subscription
+	err := env.DB.Query(&subscriptions, "SELECT * FROM subscriptions"+whereSQL+" ORDER BY subject_type, subject_id"+limitSQL, values...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resCount == 0 {
+		resCount = len(subscriptions)
+	}
+	return &subscriptions, resCount, nil
+}
+
+// FindForAlert finds the instant subscriptions to all alerts and to the watchlist, camera and rule provided
+func (e *Subscription) FindForAlert(env *Env, watchlistID, cameraID, ruleID int) ([]Subscription, error) {
+	var subscriptions []Subscription
+	err := env.DB.Query(&subscriptions, `SELECT * FROM subscriptions WHERE frequency = '' AND (subject_type = 'all'
+		OR (subject_type = 'watchlist' AND subject_id = ?)
+		OR (subject_type = 'camera' AND subject_id = ?)
+		OR (subject_type = 'rule' AND subject_id = ?))`, watchlistID, cameraID, ruleID)
+	if err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// Update subscription
+func (e *Subscription) Update(env *Env) (int64, error) {
+	// Update database
+	res, err := env.DB.Exec(
+		"UPDATE subscriptions SET subject_type = ?, subject_id = ?, email = ?, frequency = ?, digest_hour = ?, last_digest_at = ?, updated_at = DATETIME('now', 'localtime') WHERE id = ?",
+		&e.SubjectType, &e.SubjectID, &e.Email, &e.Frequency, &e.DigestHour, &e.LastDigestAt, &e.ID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Delete subscription
+func (e *Subscription) Delete(env *Env) (int64, error) {
+	// Delete from database
+	res, err := env.DB.Exec("DELETE FROM subscriptions WHERE id = ?", &e.ID)
+	if err != nil {
+		return 0, err
+	}
+	// Delete notification targets
+	var notificationTarget NotificationTarget
+	if err := notificationTarget.Delete(env, "subscription", e.ID); err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteBy deletes the subscriptions matching the fields provided, such as a user's subscriptions
+// or the subscriptions to a deleted watchlist, camera or rule
+func (e *Subscription) DeleteBy(env *Env, fields []WhereFields) error {
+	resSubscriptions, _, err := e.Find(env, "AND", fields, 0, 1)
+	if err != nil {
+		return err
+	}
+	for _, resSubscription := range *resSubscriptions {
+		if _, err := resSubscription.Delete(env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Migrate subscriptions
+func (e *Subscription) Migrate(env *Env) (sql.Result, error) {
+	// Create table if not exists
+	res, err := env.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS subscriptions (
+		id INTEGER NOT NULL PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		subject_type TEXT NOT NULL,
+		subject_id INTEGER NOT NULL DEFAULT 0,
+		email INTEGER NOT NULL DEFAULT 0,
+		frequency TEXT NOT NULL DEFAULT '',
+		digest_hour INTEGER NOT NULL DEFAULT 0,
+		last_digest_at TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL DEFAULT 0,
+		updated_at TEXT NOT NULL DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS subscriptions_subject ON subscriptions (subject_type, subject_id);
+	`)
+	if err != nil {
+		return nil, err
+	}
+	// Add columns missing from older databases
+	if err := env.DB.AddColumn("subscriptions", "frequency", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return nil, err
+	}
+	if err := env.DB.AddColumn("subscriptions", "digest_hour", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	if err := env.DB.AddColumn("subscriptions", "last_digest_at", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -53,6 +53,9 @@ func (s *User) Get(env *Env) (*User, error) {
 	if err != nil {
 		return nil, err
 	}
+	if len(user) == 0 {
+		return nil, env.DB.ErrRecordNotFound
+	}
 	return &user[0], nil
 }
 
@@ -134,6 +137,21 @@ func (s *User) Delete(env *Env) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
+	// Delete subscriptions
+	var subscription Subscription
+	if err := subscription.DeleteBy(env, []WhereFields{{"user_id", "=", s.ID}}); err != nil {
+		return 0, err
+	}
+	// Delete quiet periods
+	_, err = env.DB.Exec("DELETE FROM quiet_periods WHERE user_id = ?", &s.ID)
+	if err != nil {
+		return 0, err
+	}
+	// Remove from escalation steps
+	_, err = env.DB.Exec("DELETE FROM escalation_step_users WHERE user_id = ?", &s.ID)
+	if err != nil {
+		return 0, err
+	}
 	return res.RowsAffected()
 }
 
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"database/sql"
+)
+
+// VehicleWatch struct
+type VehicleWatch struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name" validate:"required"`
+	WatchlistID int    `json:"watchlistID" db:"watchlist_id"`
+	ScheduleID  int    `json:"scheduleID" db:"schedule_id"`
+	VehicleAttributes
+	CreatedAt string `json:"createdAt" db:"created_at"`
+	UpdatedAt string `json:"updatedAt" db:"updated_at"`
+}
+
+// Add vehicle watch
+func (e *VehicleWatch) Add(env *Env) (int64, error) {
+	// Add to database
+	res, err := env.DB.Exec(
+		"INSERT INTO vehicle_watches (name, watchlist_id, schedule_id, vehicle_type, colour, brand, model, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, DATETIME('now', 'localtime'), DATETIME('now', 'localtime'))",
+		&e.Name, &e.WatchlistID, &e.ScheduleID, &e.VehicleType, &e.Colour, &e.Brand, &e.Model,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	e.ID = int(id)
+	return res.RowsAffected()
+}
+
+// Get vehicle watch by ID provided
+func (e *VehicleWatch) Get(env *Env) (*VehicleWatch, error) {
+	// Get from database
+	var vehicleWatches []VehicleWatch
+	err := env.DB.Query(&vehicleWatches, "SELECT * FROM vehicle_watches WHERE id = ? LIMIT 1", &e.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(vehicleWatches) == 0 {
+		return nil, env.DB.ErrRecordNotFound
+	}
+	return &vehicleWatches[0], nil
+}
+
+// Find vehicle watches by fields provided
+func (e *VehicleWatch) Find(env *Env, operator string, fields []WhereFields, perPage int, pageNumber int) (*[]VehicleWatch, int, error) {
+	resCount := 0
+	// Where
+	whereSQL, values := env.DB.WhereSQL(operator, fields)
+	// Limit
+	limitSQL := env.DB.LimitSQL(perPage, pageNumber)
+	if limitSQL != "" {
+		// Get count from database
+		var vehicleWatches []VehicleWatch
+		err := env.DB.Query(&vehicleWatches, "SELECT id FROM vehicle_watches"+whereSQL, values...)
+		if err != nil {
+			return nil, 0, err
+		}
+		resCount = len(vehicleWatches)
+	}
+	// Get from database
+	var vehicleWatches []VehicleWatch
+	err := env.DB.Query(&vehicleWatches, "SELECT * FROM vehicle_watches"+whereSQL+" ORDER BY name"+limitSQL, values...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resCount == 0 {
+		resCount = len(vehicleWatches)
+	}
+	return &vehicleWatches, resCount, nil
+}
+
+// Update vehicle watch
+func (e *VehicleWatch) Update(env *Env) (int64, error) {
+	// Update database
+	res, err := env.DB.Exec(
+		"UPDATE vehicle_watches SET name = ?, watchlist_id = ?, schedule_id = ?, vehicle_type = ?, colour = ?, brand = ?, model = ?, updated_at = DATETIME('now', 'localtime') WHERE id = ?",
+		&e.Name, &e.WatchlistID, &e.ScheduleID, &e.VehicleType, &e.Colour, &e.Brand, &e.Model, &e.ID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Delete vehicle watch
+func (e *VehicleWatch) Delete(env *Env) (int64, error) {
+	// Delete from database
+	res, err := env.DB.Exec("DELETE FROM vehicle_watches WHERE id = ?", &e.ID)
+	if err != nil {
+		return 0, err
+	}
+	// Delete camera scopes
+	var cameraScope CameraScope
+	if err := cameraScope.Delete(env, "vehiclewatch", e.ID); err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Migrate vehicle watches
+func (e *VehicleWatch) Migrate(env *Env) (sql.Result, error) {
+	// Create table if not exists
+	res, err := env.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS vehicle_watches (
+		id INTEGER NOT NULL PRIMARY KEY,
+		name TEXT NOT NULL,
+		watchlist_id INTEGER NOT NULL DEFAULT 0,
+		schedule_id INTEGER NOT NULL DEFAULT 0,
+		vehicle_type TEXT NOT NULL DEFAULT '',
+		colour TEXT NOT NULL DEFAULT '',
+		brand TEXT NOT NULL DEFAULT '',
+		model TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL DEFAULT 0,
+		updated_at TEXT NOT NULL DEFAULT 0
+	);
+	`)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
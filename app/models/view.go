@@ -1,5 +1,9 @@
 package models
 
+import (
+	"html/template"
+)
+
 type Page struct {
 	Type          string
 	FormType      string
@@ -35,6 +39,7 @@ type ListPagination struct {
 	Previous int
 	Next     int
 	Pages    []int
+	Query    template.URL
 }
 
 // List struct
@@ -52,13 +57,24 @@ type FormField struct {
 	Placeholder string
 	Value       string
 	Values      []string
+	Options     []FormFieldOption
+	Multiple    bool
 	Checked     bool
 	Required    bool
 }
 
+// FormFieldOption struct
+type FormFieldOption struct {
+	Value    string
+	Title    string
+	Selected bool
+}
+
 // Form struct
 type Form struct {
 	Fields     []FormField
 	CancelLink string
 	SubmitName string
+	// URL the form is posted to for a live preview, if it has one
+	Preview string
 }
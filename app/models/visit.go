@@ -0,0 +1,141 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Visit struct
+type Visit struct {
+	ID               int    `json:"id"`
+	Plate            string `json:"plate"`
+	NumberPlateID    int    `json:"numberPlateID" db:"number_plate_id"`
+	EntryDetectionID int    `json:"entryDetectionID" db:"entry_detection_id"`
+	EntryCameraID    int    `json:"entryCameraID" db:"entry_camera_id"`
+	EntryTime        string `json:"entryTime" db:"entry_time"`
+	ExitDetectionID  int    `json:"exitDetectionID" db:"exit_detection_id"`
+	ExitCameraID     int    `json:"exitCameraID" db:"exit_camera_id"`
+	ExitTime         string `json:"exitTime" db:"exit_time"`
+	ClosedReason     string `json:"closedReason" db:"closed_reason"` // ClosedReason is empty while on site, exit or timeout
+	DwellAlerted     bool   `json:"dwellAlerted" db:"dwell_alerted"`
+}
+
+// Add visit
+func (e *Visit) Add(env *Env) (int64, error) {
+	// Add to database
+	res, err := env.DB.Exec(
+		"INSERT INTO visits (plate, number_plate_id, entry_detection_id, entry_camera_id, entry_time, exit_detection_id, exit_camera_id, exit_time, closed_reason) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		&e.Plate, &e.NumberPlateID, &e.EntryDetectionID, &e.EntryCameraID, &e.EntryTime, &e.ExitDetectionID, &e.ExitCameraID, &e.ExitTime, &e.ClosedReason,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	e.ID = int(id)
+	return res.RowsAffected()
+}
+
+// Find visits by fields provided
+func (e *Visit) Find(env *Env, operator string, fields []WhereFields, perPage int, pageNumber int) (*[]Visit, int, error) {
+	resCount := 0
+	// Where
+	whereSQL, values := env.DB.WhereSQL(operator, fields)
+	// Limit
+	limitSQL := env.DB.LimitSQL(perPage, pageNumber)
+	if limitSQL != "" {
+		// Get count from database
+		var visits []Visit
+		err := env.DB.Query(&visits, "SELECT id FROM visits"+whereSQL, values...)
+		if err != nil {
+			return nil, 0, err
+		}
+		resCount = len(visits)
+	}
+	// Get from database
+	var visits []Visit
+	err := env.DB.Query(&visits, "SELECT * FROM visits"+whereSQL+" ORDER BY entry_time DESC, id DESC"+limitSQL, values...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resCount == 0 {
+		resCount = len(visits)
+	}
+	return &visits, resCount, nil
+}
+
+// FindOnSite finds the open visit for the number plate provided, returning nil if the vehicle isn't on site
+func (e *Visit) FindOnSite(env *Env, plate string) (*Visit, error) {
+	resVisits, _, err := e.Find(env, "AND", []WhereFields{{"plate", "=", plate}, {"closed_reason", "=", ""}}, 1, 1)
+	if err != nil || len(*resVisits) == 0 {
+		return nil, err
+	}
+	return &(*resVisits)[0], nil
+}
+
+// Close closes the visit with the exit details and reason provided
+func (e *Visit) Close(env *Env) (int64, error) {
+	// Update database
+	res, err := env.DB.Exec(
+		"UPDATE visits SET exit_detection_id = ?, exit_camera_id = ?, exit_time = ?, closed_reason = ? WHERE id = ?",
+		&e.ExitDetectionID, &e.ExitCameraID, &e.ExitTime, &e.ClosedReason, &e.ID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// SetDwellAlerted marks the visit as having sent its dwell time alert
+func (e *Visit) SetDwellAlerted(env *Env) (int64, error) {
+	// Update database
+	res, err := env.DB.Exec("UPDATE visits SET dwell_alerted = 1 WHERE id = ?", &e.ID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// TimeoutStale closes visits that have been open since before the time provided
+func (e *Visit) TimeoutStale(env *Env, before time.Time) (int64, error) {
+	// Update database
+	res, err := env.DB.Exec(
+		"UPDATE visits SET exit_time = ?, closed_reason = 'timeout' WHERE closed_reason = '' AND entry_time < ?",
+		time.Now().Format(DateTimeFormat), before.Format(DateTimeFormat),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Migrate visits
+func (e *Visit) Migrate(env *Env) (sql.Result, error) {
+	// Create table if not exists
+	res, err := env.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS visits (
+		id INTEGER NOT NULL PRIMARY KEY,
+		plate TEXT NOT NULL,
+		number_plate_id INTEGER NOT NULL DEFAULT 0,
+		entry_detection_id INTEGER NOT NULL DEFAULT 0,
+		entry_camera_id INTEGER NOT NULL DEFAULT 0,
+		entry_time TEXT NOT NULL DEFAULT '',
+		exit_detection_id INTEGER NOT NULL DEFAULT 0,
+		exit_camera_id INTEGER NOT NULL DEFAULT 0,
+		exit_time TEXT NOT NULL DEFAULT '',
+		closed_reason TEXT NOT NULL DEFAULT '',
+		dwell_alerted INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS visits_plate ON visits (plate, closed_reason);
+	`)
+	if err != nil {
+		return nil, err
+	}
+	// Add columns missing from older databases
+	if err := env.DB.AddColumn("visits", "dwell_alerted", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,176 @@
+package models
+
+import (
+	"database/sql"
+)
+
+// Watchlist struct
+type Watchlist struct {
+	ID                 int    `json:"id"`
+	Name               string `json:"name" validate:"required"`
+	ScheduleID         int    `json:"scheduleID" db:"schedule_id"`
+	MinConfidence      int    `json:"minConfidence" db:"min_confidence" validate:"min=0,max=100"`
+	AlertDirection     string `json:"alertDirection" db:"alert_direction" validate:"omitempty,oneof=entry exit"`
+	DwellMinutes       int    `json:"dwellMinutes" db:"dwell_minutes" validate:"min=0"`
+	EscalationPolicyID int    `json:"escalationPolicyID" db:"escalation_policy_id"`
+	Critical           bool   `json:"critical"`
+	TemplateID         int    `json:"templateID" db:"template_id"`
+	CooldownMinutes    int    `json:"cooldownMinutes" db:"cooldown_minutes" validate:"min=0"`
+	CreatedAt          string `json:"createdAt" db:"created_at"`
+	UpdatedAt          string `json:"updatedAt" db:"updated_at"`
+}
+
+// Add watchlist
+func (e *Watchlist) Add(env *Env) (int64, error) {
+	// Add to database
+	res, err := env.DB.Exec(
+		"INSERT INTO watchlists (name, schedule_id, min_confidence, alert_direction, dwell_minutes, escalation_policy_id, critical, template_id, cooldown_minutes, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, DATETIME('now', 'localtime'), DATETIME('now', 'localtime'))",
+		&e.Name, &e.ScheduleID, &e.MinConfidence, &e.AlertDirection, &e.DwellMinutes, &e.EscalationPolicyID, &e.Critical, &e.TemplateID, &e.CooldownMinutes,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	e.ID = int(id)
+	return res.RowsAffected()
+}
+
+// Get watchlist by ID provided
+func (e *Watchlist) Get(env *Env) (*Watchlist, error) {
+	// Get from database
+	var watchlists []Watchlist
+	err := env.DB.Query(&watchlists, "SELECT * FROM watchlists WHERE id = ? LIMIT 1", &e.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(watchlists) == 0 {
+		return nil, env.DB.ErrRecordNotFound
+	}
+	return &watchlists[0], nil
+}
+
+// Find watchlists by fields provided
+func (e *Watchlist) Find(env *Env, operator string, fields []WhereFields, perPage int, pageNumber int) (*[]Watchlist, int, error) {
+	resCount := 0
+	// Where
+	whereSQL, values := env.DB.WhereSQL(operator, fields)
+	// Limit
+	limitSQL := env.DB.LimitSQL(perPage, pageNumber)
+	if limitSQL != "" {
+		// Get count from database
+		var watchlists []Watchlist
+		err := env.DB.Query(&watchlists, "SELECT id FROM watchlists"+whereSQL, values...)
+		if err != nil {
+			return nil, 0, err
+		}
+		resCount = len(watchlists)
+	}
+	// Get from database
+	var watchlists []Watchlist
+	err := env.DB.Query(&watchlists, "SELECT * FROM watchlists"+whereSQL+" ORDER BY name"+limitSQL, values...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resCount == 0 {
+		resCount = len(watchlists)
+	}
+	return &watchlists, resCount, nil
+}
+
+// Update watchlist
+func (e *Watchlist) Update(env *Env) (int64, error) {
+	// Update database
+	res, err := env.DB.Exec(
+		"UPDATE watchlists SET name = ?, schedule_id = ?, min_confidence = ?, alert_direction = ?, dwell_minutes = ?, escalation_policy_id = ?, critical = ?, template_id = ?, cooldown_minutes = ?, updated_at = DATETIME('now', 'localtime') WHERE id = ?",
+		&e.Name, &e.ScheduleID, &e.MinConfidence, &e.AlertDirection, &e.DwellMinutes, &e.EscalationPolicyID, &e.Critical, &e.TemplateID, &e.CooldownMinutes, &e.ID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Delete watchlist
+func (e *Watchlist) Delete(env *Env) (int64, error) {
+	// Delete from database
+	res, err := env.DB.Exec("DELETE FROM watchlists WHERE id = ?", &e.ID)
+	if err != nil {
+		return 0, err
+	}
+	// Remove number plates from the watchlist
+	_, err = env.DB.Exec("UPDATE number_plates SET watchlist_id = 0 WHERE watchlist_id = ?", &e.ID)
+	if err != nil {
+		return 0, err
+	}
+	// Remove vehicle watches from the watchlist
+	_, err = env.DB.Exec("UPDATE vehicle_watches SET watchlist_id = 0 WHERE watchlist_id = ?", &e.ID)
+	if err != nil {
+		return 0, err
+	}
+	// Delete camera scopes
+	var cameraScope CameraScope
+	if err := cameraScope.Delete(env, "watchlist", e.ID); err != nil {
+		return 0, err
+	}
+	// Delete notification targets
+	var notificationTarget NotificationTarget
+	if err := notificationTarget.Delete(env, "watchlist", e.ID); err != nil {
+		return 0, err
+	}
+	// Delete subscriptions
+	var subscription Subscription
+	if err := subscription.DeleteBy(env, []WhereFields{{"subject_type", "=", "watchlist"}, {"subject_id", "=", e.ID}}); err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Migrate watchlists
+func (e *Watchlist) Migrate(env *Env) (sql.Result, error) {
+	// Create table if not exists
+	res, err := env.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS watchlists (
+		id INTEGER NOT NULL PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		schedule_id INTEGER NOT NULL DEFAULT 0,
+		min_confidence INTEGER NOT NULL DEFAULT 0,
+		alert_direction TEXT NOT NULL DEFAULT '',
+		dwell_minutes INTEGER NOT NULL DEFAULT 0,
+		escalation_policy_id INTEGER NOT NULL DEFAULT 0,
+		critical INTEGER NOT NULL DEFAULT 0,
+		template_id INTEGER NOT NULL DEFAULT 0,
+		cooldown_minutes INTEGER NOT NULL DEFAULT 0,
+		created_at TEXT NOT NULL DEFAULT 0,
+		updated_at TEXT NOT NULL DEFAULT 0
+	);
+	`)
+	if err != nil {
+		return nil, err
+	}
+	// Add columns missing from older databases
+	if err := env.DB.AddColumn("watchlists", "min_confidence", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	if err := env.DB.AddColumn("watchlists", "alert_direction", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return nil, err
+	}
+	if err := env.DB.AddColumn("watchlists", "dwell_minutes", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	if err := env.DB.AddColumn("watchlists", "escalation_policy_id", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	if err := env.DB.AddColumn("watchlists", "critical", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	if err := env.DB.AddColumn("watchlists", "template_id", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	if err := env.DB.AddColumn("watchlists", "cooldown_minutes", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,272 @@
+package models
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WebhookEvents are the events webhooks can be sent for
+var WebhookEvents = map[string]string{
+	"detection": "All detections",
+	"alert":     "Matches only (alerts)",
+	"camera":    "Camera health",
+}
+
+// WebhookSignatureHeader is the header containing the HMAC-SHA256 signature of a webhook's body
+const WebhookSignatureHeader = "X-ANPR-Signature-256"
+
+// webhookRetries are how long to wait before each retry of a failed webhook delivery
+var webhookRetries = []time.Duration{10 * time.Second, 30 * time.Second, 2 * time.Minute, 10 * time.Minute}
+
+// Webhook struct
+type Webhook struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name" validate:"required"`
+	URL       string `json:"url" validate:"required,url"`
+	Secret    string `json:"-" validate:"required"`
+	Events    string `json:"events" validate:"required"`
+	Enabled   bool   `json:"enabled"`
+	CreatedAt string `json:"createdAt" db:"created_at"`
+	UpdatedAt string `json:"updatedAt" db:"updated_at"`
+}
+
+// WebhookPayload struct
+type WebhookPayload struct {
+	Event     string      `json:"event"`
+	Timestamp string      `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// WebhookCamera is the camera included in webhook payloads
+type WebhookCamera struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	IPAddress string `json:"ipAddress"`
+}
+
+// WebhookDetection is the data of a detection webhook
+type WebhookDetection struct {
+	Detection Detection     `json:"detection"`
+	Camera    WebhookCamera `json:"camera"`
+	Link      string        `json:"link"`
+}
+
+// WebhookAlert is the data of an alert webhook
+type WebhookAlert struct {
+	ID          int                 `json:"id"`
+	Title       string              `json:"title"`
+	Message     string              `json:"message"`
+	Fields      []NotificationField `json:"fields"`
+	Link        string              `json:"link"`
+	Plate       string              `json:"plate"`
+	DetectionID int                 `json:"detectionID"`
+	CameraID    int                 `json:"cameraID"`
+	WatchlistID int                 `json:"watchlistID"`
+	RuleID      int                 `json:"ruleID"`
+}
+
+// WebhookCameraHealth is the data of a camera health webhook
+type WebhookCameraHealth struct {
+	Camera WebhookCamera `json:"camera"`
+	Status string        `json:"status"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// Add webhook
+func (e *Webhook) Add(env *Env) (int64, error) {
+	// Add to database
+	res, err := env.DB.Exec(
+		"INSERT INTO webhooks (name, url, secret, events, enabled, created_at, updated_at) VALUES (?, ?, ?, ?, ?, DATETIME('now', 'localtime'), DATETIME('now', 'localtime'))",
+		&e.Name, &e.URL, &e.Secret, &e.Events, &e.Enabled,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	e.ID = int(id)
+	return res.RowsAffected()
+}
+
+// Get webhook by ID provided
+func (e *Webhook) Get(env *Env) (*Webhook, error) {
+	// Get from database
+	var webhooks []Webhook
+	err := env.DB.Query(&webhooks, "SELECT * FROM webhooks WHERE id = ? LIMIT 1", &e.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(webhooks) == 0 {
+		return nil, env.DB.ErrRecordNotFound
+	}
+	return &webhooks[0], nil
+}
+
+// Find webhooks by fields provided
+func (e *Webhook) Find(env *Env, operator string, fields []WhereFields, perPage int, pageNumber int) (*[]Webhook, int, error) {
+	resCount := 0
+	// Where
+	whereSQL, values := env.DB.WhereSQL(operator, fields)
+	// Limit
+	limitSQL := env.DB.LimitSQL(perPage, pageNumber)
+	if limitSQL != "" {
+		// Get count from database
+		var webhooks []Webhook
+		err := env.DB.Query(&webhooks, "SELECT id FROM webhooks"+whereSQL, values...)
+		if err != nil {
+			return nil, 0, err
+		}
+		resCount = len(webhooks)
+	}
+	// Get from database
+	var webhooks []Webhook
+	err := env.DB.Query(&webhooks, "SELECT * FROM webhooks"+whereSQL+" ORDER BY name"+limitSQL, values...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resCount == 0 {
+		resCount = len(webhooks)
+	}
+	return &webhooks, resCount, nil
+}
+
+// Update webhook
+func (e *Webhook) Update(env *Env) (int64, error) {
+	// Update database
+	res, err := env.DB.Exec(
+		"UPDATE webhooks SET name = ?, url = ?, secret = ?, events = ?, enabled = ?, updated_at = DATETIME('now', 'localtime') WHERE id = ?",
+		&e.Name, &e.URL, &e.Secret, &e.Events, &e.Enabled, &e.ID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Delete webhook
+func (e *Webhook) Delete(env *Env) (int64, error) {
+	// Delete from database
+	res, err := env.DB.Exec("DELETE FROM webhooks WHERE id = ?", &e.ID)
+	if err != nil {
+		return 0, err
+	}
+	// Delete deliveries
+	_, err = env.DB.Exec("DELETE FROM webhook_deliveries WHERE webhook_id = ?", &e.ID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// EventList returns the events the webhook is sent for
+func (e *Webhook) EventList() []string {
+	if e.Events == "" {
+		return nil
+	}
+	return strings.Split(e.Events, ",")
+}
+
+// HasEvent checks if the webhook is sent for the event provided
+func (e *Webhook) HasEvent(event string) bool {
+	for _, webhookEvent := range e.EventList() {
+		if webhookEvent == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign returns the signature of a webhook body, the hex encoded HMAC-SHA256 of the body using the
+// webhook's secret prefixed with "sha256="
+func (e *Webhook) Sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(e.Secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send posts a webhook body to the webhook's URL, returning the response status code
+func (e *Webhook) Send(event string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Hikvision-ANPR-Alerts-Webhook")
+	req.Header.Set("X-ANPR-Event", event)
+	req.Header.Set(WebhookSignatureHeader, e.Sign(body))
+	client := http.Client{Timeout: 10 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 1<<20))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("webhook returned %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+// TriggerWebhooks logs a delivery of the event and data provided for each enabled webhook that's sent
+// for the event, which are sent and retried by the webhook worker
+func TriggerWebhooks(env *Env, event string, data interface{}) {
+	var webhook Webhook
+	resWebhooks, _, err := webhook.Find(env, "AND", []WhereFields{{"enabled", "=", 1}}, 0, 1)
+	if err != nil {
+		env.Logger.Printf("Error finding webhooks: %s\n", err)
+		return
+	}
+	var body []byte
+	for _, resWebhook := range *resWebhooks {
+		if !resWebhook.HasEvent(event) {
+			continue
+		}
+		if body == nil {
+			body, err = json.Marshal(WebhookPayload{Event: event, Timestamp: time.Now().Format(time.RFC3339), Data: data})
+			if err != nil {
+				env.Logger.Printf("Error encoding webhook payload: %s\n", err)
+				return
+			}
+		}
+		delivery := WebhookDelivery{WebhookID: resWebhook.ID, Event: event, Payload: string(body), Status: "pending", NextAttemptAt: time.Now().Format(DateTimeFormat)}
+		if _, err := delivery.Add(env); err != nil {
+			env.Logger.Printf("Error logging webhook delivery: %s\n", err)
+		}
+	}
+	select {
+	case WebhooksQueued <- struct{}{}:
+	default:
+	}
+}
+
+// Migrate webhooks
+func (e *Webhook) Migrate(env *Env) (sql.Result, error) {
+	// Create table if not exists
+	res, err := env.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id INTEGER NOT NULL PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		events TEXT NOT NULL DEFAULT '',
+		enabled INTEGER NOT NULL DEFAULT 1,
+		created_at TEXT NOT NULL DEFAULT 0,
+		updated_at TEXT NOT NULL DEFAULT 0
+	);
+	`)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
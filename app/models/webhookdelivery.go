@@ -0,0 +1,188 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// WebhooksQueued is signalled whenever a webhook delivery is logged so the worker can send it
+// straight away
+var WebhooksQueued = make(chan struct{}, 1)
+
+// WebhookDelivery struct
+type WebhookDelivery struct {
+	ID         int    `json:"id"`
+	WebhookID  int    `json:"webhookID" db:"webhook_id"`
+	Event      string `json:"event"`
+	Payload    string `json:"payload"`
+	Status     string `json:"status"`
+	Attempts   int    `json:"attempts"`
+	StatusCode int    `json:"statusCode" db:"status_code"`
+	DurationMS int    `json:"durationMS" db:"duration_ms"`
+	Error      string `json:"error"`
+	// NextAttemptAt is when the delivery is next sent while it's pending or retrying
+	NextAttemptAt string `json:"nextAttemptAt" db:"next_attempt_at"`
+	CreatedAt     string `json:"createdAt" db:"created_at"`
+	UpdatedAt     string `json:"updatedAt" db:"updated_at"`
+}
+
+// Add webhook delivery
+func (e *WebhookDelivery) Add(env *Env) (int64, error) {
+	// Add to database
+	res, err := env.DB.Exec(
+		"INSERT INTO webhook_deliveries (webhook_id, event, payload, status, attempts, status_code, duration_ms, error, next_attempt_at, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, DATETIME('now', 'localtime'), DATETIME('now', 'localtime'))",
+		&e.WebhookID, &e.Event, &e.Payload, &e.Status, &e.Attempts, &e.StatusCode, &e.DurationMS, &e.Error, &e.NextAttemptAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	e.ID = int(id)
+	return res.RowsAffected()
+}
+
+// Find webhook deliveries by fields provided
+func (e *WebhookDelivery) Find(env *Env, operator string, fields []WhereFields, perPage int, pageNumber int) (*[]WebhookDelivery, int, error) {
+	resCount := 0
+	// Where
+	whereSQL, values := env.DB.WhereSQL(operator, fields)
+	// Limit
+	limitSQL := env.DB.LimitSQL(perPage, pageNumber)
+	if limitSQL != "" {
+		// Get count from database
+		var webhookDeliveries []WebhookDelivery
+		err := env.DB.Query(&webhookDeliveries, "SELECT id FROM webhook_deliveries"+whereSQL, values...)
+		if err != nil {
+			return nil, 0, err
+		}
+		resCount = len(webhookDeliveries)
+	}
+	// Get from database
+	var webhookDeliveries []WebhookDelivery
+	err := env.DB.Query(&webhookDeliveries, "SELECT * FROM webhook_deliveries"+whereSQL+" ORDER BY id DESC"+limitSQL, values...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resCount == 0 {
+		resCount = len(webhookDeliveries)
+	}
+	return &webhookDeliveries, resCount, nil
+}
+
+// Update webhook delivery
+func (e *WebhookDelivery) Update(env *Env) (int64, error) {
+	// Update database
+	res, err := env.DB.Exec(
+		"UPDATE webhook_deliveries SET status = ?, attempts = ?, status_code = ?, duration_ms = ?, error = ?, next_attempt_at = ?, updated_at = DATETIME('now', 'localtime') WHERE id = ?",
+		&e.Status, &e.Attempts, &e.StatusCode, &e.DurationMS, &e.Error, &e.NextAttemptAt, &e.ID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteFinished deletes deliveries that were delivered or failed before the time provided
+func (e *WebhookDelivery) DeleteFinished(env *Env, before time.Time) (int64, error) {
+	// Delete from database
+	res, err := env.DB.Exec(
+		"DELETE FROM webhook_deliveries WHERE status IN ('delivered', 'failed') AND updated_at < ?",
+		before.Format(DateTimeFormat),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Attempt sends the delivery to its webhook and records the result, scheduling a retry if it failed
+// and there are retries left. Deliveries for webhooks that have been deleted or disabled fail.
+func (e *WebhookDelivery) Attempt(env *Env) error {
+	webhook := Webhook{ID: e.WebhookID}
+	resWebhook, err := webhook.Get(env)
+	if err != nil && !errors.Is(err, env.DB.ErrRecordNotFound) {
+		return err
+	}
+	now := time.Now()
+	switch {
+	case resWebhook == nil:
+		err = errors.New("webhook has been deleted")
+	case !resWebhook.Enabled:
+		err = errors.New("webhook is disabled")
+	default:
+		e.Attempts++
+		e.StatusCode, err = resWebhook.Send(e.Event, []byte(e.Payload))
+		e.DurationMS = int(time.Since(now).Milliseconds())
+	}
+	e.Error = ""
+	switch {
+	case err == nil:
+		e.Status = "delivered"
+	case resWebhook != nil && resWebhook.Enabled && e.Attempts <= len(webhookRetries):
+		e.Status = "retrying"
+		e.Error = err.Error()
+		e.NextAttemptAt = now.Add(webhookRetries[e.Attempts-1]).Format(DateTimeFormat)
+	default:
+		e.Status = "failed"
+		e.Error = err.Error()
+	}
+	if _, updateErr := e.Update(env); updateErr != nil {
+		return updateErr
+	}
+	return err
+}
+
+// DeliverWebhooks sends every pending or retrying webhook delivery that's due
+func DeliverWebhooks(env *Env) error {
+	var webhookDelivery WebhookDelivery
+	resWebhookDeliveries, _, err := webhookDelivery.Find(env, "AND", []WhereFields{
+		{"status", "!=", "delivered"},
+		{"status", "!=", "failed"},
+		{"next_attempt_at", "<=", time.Now().Format(DateTimeFormat)},
+	}, 0, 1)
+	if err != nil {
+		return err
+	}
+	// Deliver the oldest first
+	for i := len(*resWebhookDeliveries) - 1; i >= 0; i-- {
+		resWebhookDelivery := (*resWebhookDeliveries)[i]
+		if err := resWebhookDelivery.Attempt(env); err != nil && resWebhookDelivery.Status == "failed" {
+			env.Logger.Printf("Webhook delivery %d failed: %s\n", resWebhookDelivery.ID, err)
+		}
+	}
+	return nil
+}
+
+// Migrate webhook deliveries
+func (e *WebhookDelivery) Migrate(env *Env) (sql.Result, error) {
+	// Create table if not exists
+	res, err := env.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER NOT NULL PRIMARY KEY,
+		webhook_id INTEGER NOT NULL,
+		event TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		status_code INTEGER NOT NULL DEFAULT 0,
+		duration_ms INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		next_attempt_at TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL DEFAULT 0,
+		updated_at TEXT NOT NULL DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook ON webhook_deliveries (webhook_id);
+	`)
+	if err != nil {
+		return nil, err
+	}
+	// Add columns missing from older databases
+	if err := env.DB.AddColumn("webhook_deliveries", "next_attempt_at", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
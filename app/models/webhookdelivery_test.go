@@ -0,0 +1,54 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWebhookDeliveryDeleteFinished(t *testing.T) {
+	env := newTestEnv(t)
+	now := time.Now()
+	deliveries := []struct {
+		status    string
+		updatedAt time.Time
+		kept      bool
+	}{
+		{"delivered", now.AddDate(0, 0, -31), false},
+		{"failed", now.AddDate(0, 0, -31), false},
+		{"delivered", now.AddDate(0, 0, -29), true},
+		// Deliveries still being sent are kept however old they are
+		{"pending", now.AddDate(0, 0, -31), true},
+		{"retrying", now.AddDate(0, 0, -31), true},
+	}
+	for i := range deliveries {
+		webhookDelivery := WebhookDelivery{WebhookID: 1, Event: "detection", Payload: "{}", Status: deliveries[i].status}
+		if _, err := webhookDelivery.Add(env); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := env.DB.Exec("UPDATE webhook_deliveries SET updated_at = ? WHERE id = ?", deliveries[i].updatedAt.Format(DateTimeFormat), webhookDelivery.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var webhookDelivery WebhookDelivery
+	count, err := webhookDelivery.DeleteFinished(env, now.AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("DeleteFinished() deleted %d deliveries, want 2", count)
+	}
+	resWebhookDeliveries, _, err := webhookDelivery.Find(env, "AND", []WhereFields{}, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept := map[int]bool{}
+	for _, resWebhookDelivery := range *resWebhookDeliveries {
+		kept[resWebhookDelivery.ID] = true
+	}
+	for i, delivery := range deliveries {
+		if kept[i+1] != delivery.kept {
+			t.Errorf("%s delivery %d kept = %v, want %v", delivery.status, i+1, kept[i+1], delivery.kept)
+		}
+	}
+}
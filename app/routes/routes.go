@@ -33,14 +33,82 @@ func Initialize(r *mux.Router, env *models.Env) {
 	r.Handle("/add", &middleware.AppHandler{env, controllers.AdminAddNumberPlate})
 	r.Handle("/{id:[0-9]+}", &middleware.AppHandler{env, controllers.AdminEditNumberPlate})
 	r.Handle("/{id:[0-9]+}/delete", &middleware.AppHandler{env, controllers.AdminDeleteNumberPlate})
+	r.Handle("/detections", &middleware.AppHandler{env, controllers.AdminDetections})
+	r.Handle("/detections/{id:[0-9]+}", &middleware.AppHandler{env, controllers.AdminDetection})
+	r.Handle("/detections/{id:[0-9]+}/review", &middleware.AppHandler{env, controllers.AdminReviewDetection})
 	r.Handle("/cameras", &middleware.AppHandler{env, controllers.AdminCameras})
 	r.Handle("/cameras/add", &middleware.AppHandler{env, controllers.AdminAddCamera})
 	r.Handle("/cameras/{id:[0-9]+}", &middleware.AppHandler{env, controllers.AdminEditCamera})
 	r.Handle("/cameras/{id:[0-9]+}/delete", &middleware.AppHandler{env, controllers.AdminDeleteCamera})
+	r.Handle("/cameras/groups", &middleware.AppHandler{env, controllers.AdminCameraGroups})
+	r.Handle("/cameras/groups/add", &middleware.AppHandler{env, controllers.AdminAddCameraGroup})
+	r.Handle("/cameras/groups/{id:[0-9]+}", &middleware.AppHandler{env, controllers.AdminEditCameraGroup})
+	r.Handle("/cameras/groups/{id:[0-9]+}/delete", &middleware.AppHandler{env, controllers.AdminDeleteCameraGroup})
+	r.Handle("/watchlists", &middleware.AppHandler{env, controllers.AdminWatchlists})
+	r.Handle("/watchlists/add", &middleware.AppHandler{env, controllers.AdminAddWatchlist})
+	r.Handle("/watchlists/{id:[0-9]+}", &middleware.AppHandler{env, controllers.AdminEditWatchlist})
+	r.Handle("/watchlists/{id:[0-9]+}/delete", &middleware.AppHandler{env, controllers.AdminDeleteWatchlist})
+	r.Handle("/onsite", &middleware.AppHandler{env, controllers.AdminOnSite})
+	r.Handle("/arrivals", &middleware.AppHandler{env, controllers.AdminExpectedArrivals})
+	r.Handle("/arrivals/add", &middleware.AppHandler{env, controllers.AdminAddExpectedArrival})
+	r.Handle("/arrivals/{id:[0-9]+}", &middleware.AppHandler{env, controllers.AdminEditExpectedArrival})
+	r.Handle("/arrivals/{id:[0-9]+}/delete", &middleware.AppHandler{env, controllers.AdminDeleteExpectedArrival})
+	r.Handle("/rules", &middleware.AppHandler{env, controllers.AdminRules})
+	r.Handle("/rules/add", &middleware.AppHandler{env, controllers.AdminAddRule})
+	r.Handle("/rules/{id:[0-9]+}", &middleware.AppHandler{env, controllers.AdminEditRule})
+	r.Handle("/rules/{id:[0-9]+}/hits", &middleware.AppHandler{env, controllers.AdminRuleHits})
+	r.Handle("/rules/{id:[0-9]+}/delete", &middleware.AppHandler{env, controllers.AdminDeleteRule})
+	r.Handle("/vehicles", &middleware.AppHandler{env, controllers.AdminVehicleWatches})
+	r.Handle("/vehicles/add", &middleware.AppHandler{env, controllers.AdminAddVehicleWatch})
+	r.Handle("/vehicles/{id:[0-9]+}", &middleware.AppHandler{env, controllers.AdminEditVehicleWatch})
+	r.Handle("/vehicles/{id:[0-9]+}/delete", &middleware.AppHandler{env, controllers.AdminDeleteVehicleWatch})
+	r.Handle("/channels", &middleware.AppHandler{env, controllers.AdminNotificationChannels})
+	r.Handle("/channels/add", &middleware.AppHandler{env, controllers.AdminAddNotificationChannel})
+	r.Handle("/channels/{id:[0-9]+}", &middleware.AppHandler{env, controllers.AdminEditNotificationChannel})
+	r.Handle("/channels/{id:[0-9]+}/delete", &middleware.AppHandler{env, controllers.AdminDeleteNotificationChannel})
+	r.Handle("/channels/{id:[0-9]+}/test", &middleware.AppHandler{env, controllers.AdminTestNotificationChannel})
+	r.Handle("/channels/email/test", &middleware.AppHandler{env, controllers.AdminTestEmail})
+	r.Handle("/templates", &middleware.AppHandler{env, controllers.AdminMessageTemplates})
+	r.Handle("/templates/add", &middleware.AppHandler{env, controllers.AdminAddMessageTemplate})
+	r.Handle("/templates/preview", &middleware.AppHandler{env, controllers.AdminPreviewMessageTemplate})
+	r.Handle("/templates/{id:[0-9]+}", &middleware.AppHandler{env, controllers.AdminEditMessageTemplate})
+	r.Handle("/templates/{id:[0-9]+}/delete", &middleware.AppHandler{env, controllers.AdminDeleteMessageTemplate})
+	r.Handle("/webhooks", &middleware.AppHandler{env, controllers.AdminWebhooks})
+	r.Handle("/webhooks/add", &middleware.AppHandler{env, controllers.AdminAddWebhook})
+	r.Handle("/webhooks/{id:[0-9]+}", &middleware.AppHandler{env, controllers.AdminEditWebhook})
+	r.Handle("/webhooks/{id:[0-9]+}/delete", &middleware.AppHandler{env, controllers.AdminDeleteWebhook})
+	r.Handle("/webhooks/{id:[0-9]+}/deliveries", &middleware.AppHandler{env, controllers.AdminWebhookDeliveries})
+	r.Handle("/escalations", &middleware.AppHandler{env, controllers.AdminEscalationPolicies})
+	r.Handle("/escalations/add", &middleware.AppHandler{env, controllers.AdminAddEscalationPolicy})
+	r.Handle("/escalations/{id:[0-9]+}", &middleware.AppHandler{env, controllers.AdminEditEscalationPolicy})
+	r.Handle("/escalations/{id:[0-9]+}/delete", &middleware.AppHandler{env, controllers.AdminDeleteEscalationPolicy})
+	r.Handle("/escalations/{id:[0-9]+}/steps", &middleware.AppHandler{env, controllers.AdminEscalationSteps})
+	r.Handle("/escalations/{id:[0-9]+}/steps/add", &middleware.AppHandler{env, controllers.AdminAddEscalationStep})
+	r.Handle("/escalations/{id:[0-9]+}/steps/{stepid:[0-9]+}", &middleware.AppHandler{env, controllers.AdminEditEscalationStep})
+	r.Handle("/escalations/{id:[0-9]+}/steps/{stepid:[0-9]+}/delete", &middleware.AppHandler{env, controllers.AdminDeleteEscalationStep})
+	r.Handle("/alerts", &middleware.AppHandler{env, controllers.AdminAlerts})
+	r.Handle("/alerts/{id:[0-9]+}", &middleware.AppHandler{env, controllers.AdminAlert})
+	r.Handle("/alerts/{id:[0-9]+}/acknowledge", &middleware.AppHandler{env, controllers.AdminAcknowledgeAlert})
+	r.Handle("/alerts/{id:[0-9]+}/resolve", &middleware.AppHandler{env, controllers.AdminResolveAlert})
+	r.Handle("/alerts/deliveries", &middleware.AppHandler{env, controllers.AdminAlertDeliveries})
+	r.Handle("/alerts/deliveries/{id:[0-9]+}/resend", &middleware.AppHandler{env, controllers.AdminResendAlertDelivery})
+	r.Handle("/schedules", &middleware.AppHandler{env, controllers.AdminSchedules})
+	r.Handle("/schedules/add", &middleware.AppHandler{env, controllers.AdminAddSchedule})
+	r.Handle("/schedules/{id:[0-9]+}", &middleware.AppHandler{env, controllers.AdminEditSchedule})
+	r.Handle("/schedules/{id:[0-9]+}/delete", &middleware.AppHandler{env, controllers.AdminDeleteSchedule})
 	r.Handle("/users", &middleware.AppHandler{env, controllers.AdminUsers})
 	r.Handle("/users/add", &middleware.AppHandler{env, controllers.AdminAddUser})
 	r.Handle("/users/{id:[0-9]+}", &middleware.AppHandler{env, controllers.AdminEditUser})
 	r.Handle("/users/{id:[0-9]+}/delete", &middleware.AppHandler{env, controllers.AdminDeleteUser})
+	r.Handle("/users/{id:[0-9]+}/subscriptions", &middleware.AppHandler{env, controllers.AdminSubscriptions})
+	r.Handle("/users/{id:[0-9]+}/subscriptions/add", &middleware.AppHandler{env, controllers.AdminAddSubscription})
+	r.Handle("/users/{id:[0-9]+}/subscriptions/{subscriptionid:[0-9]+}", &middleware.AppHandler{env, controllers.AdminEditSubscription})
+	r.Handle("/users/{id:[0-9]+}/subscriptions/{subscriptionid:[0-9]+}/delete", &middleware.AppHandler{env, controllers.AdminDeleteSubscription})
+	r.Handle("/users/{id:[0-9]+}/quiet", &middleware.AppHandler{env, controllers.AdminQuietPeriods})
+	r.Handle("/users/{id:[0-9]+}/quiet/add", &middleware.AppHandler{env, controllers.AdminAddQuietPeriod})
+	r.Handle("/users/{id:[0-9]+}/quiet/{quietid:[0-9]+}", &middleware.AppHandler{env, controllers.AdminEditQuietPeriod})
+	r.Handle("/users/{id:[0-9]+}/quiet/{quietid:[0-9]+}/delete", &middleware.AppHandler{env, controllers.AdminDeleteQuietPeriod})
+	r.Handle("/quiet", &middleware.AppHandler{env, controllers.AdminMyQuietPeriods})
 	r.Handle("/login", &middleware.AppHandler{env, controllers.Login}).Methods(http.MethodGet, http.MethodPost)
 	r.Handle("/logout", &middleware.AppHandler{env, controllers.Logout}).Methods(http.MethodGet)
 
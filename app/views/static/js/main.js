@@ -37,4 +37,37 @@ theme.update();
 window.addEventListener("focus", theme.update());
 document.getElementById('theme-toggle').addEventListener('click', function() {
     theme.toggle();
-});
\ No newline at end of file
+});
+
+/* Form preview */
+const preview = {
+    form: document.querySelector('form[data-preview]'),
+    timeout: null,
+    update: function() {
+        fetch(this.form.dataset.preview, {method: 'POST', body: new URLSearchParams(new FormData(this.form))})
+            .then(response => response.json())
+            .then(result => {
+                const error = document.getElementById('preview-error');
+                const text = document.getElementById('preview-text');
+                const html = document.getElementById('preview-html');
+                error.textContent = result.error;
+                error.hidden = !result.error;
+                document.getElementById('preview-title').textContent = result.title;
+                text.hidden = result.format === 'html';
+                html.hidden = result.format !== 'html';
+                if (result.format === 'html') {
+                    html.srcdoc = result.body;
+                } else {
+                    text.textContent = result.body;
+                }
+            });
+    },
+    schedule: function() {
+        clearTimeout(this.timeout);
+        this.timeout = setTimeout(() => this.update(), 300);
+    }
+};
+if (preview.form) {
+    preview.form.addEventListener('input', () => preview.schedule());
+    preview.update();
+}